@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+
+	"github.com/manifold/tractor/pkg/agent/rpc"
+	"github.com/manifold/tractor/pkg/misc/logging"
+)
+
+// dashboardService serves the web dashboard of the RPC service over HTTP.
+type dashboardService struct {
+	ListenAddr string
+
+	Log logging.Logger
+	RPC *rpc.Service
+
+	l   net.Listener
+	srv *http.Server
+}
+
+func (s *dashboardService) InitializeDaemon() (err error) {
+	if s.l, err = net.Listen("tcp", s.ListenAddr); err != nil {
+		return err
+	}
+	s.srv = &http.Server{Handler: s.RPC.Dashboard()}
+	return nil
+}
+
+func (s *dashboardService) Serve(ctx context.Context) {
+	s.Log.Infof("[dashboard] http://%s/", s.ListenAddr)
+	if err := s.srv.Serve(s.l); err != nil && err != http.ErrServerClosed {
+		s.Log.Info("[dashboard]", err)
+	}
+}
+
+func (s *dashboardService) TerminateDaemon() error {
+	return s.srv.Shutdown(context.Background())
+}
@@ -0,0 +1,22 @@
+//go:build headless
+// +build headless
+
+package main
+
+import (
+	"log"
+
+	"github.com/manifold/tractor/pkg/misc/daemon"
+)
+
+// Built with the headless tag, the agent doesn't link the systray, which needs
+// GTK and appindicator, and always runs headless.
+const systrayAvailable = false
+
+func runSystray() {
+	log.Fatal("tractor-agent was built without the systray")
+}
+
+func systrayService() daemon.Service {
+	return nil
+}
@@ -11,8 +11,6 @@ import (
 	"github.com/manifold/tractor/pkg/agent/console"
 	"github.com/manifold/tractor/pkg/agent/rpc"
 	"github.com/manifold/tractor/pkg/agent/selfdev"
-	"github.com/manifold/tractor/pkg/agent/systray"
-	"github.com/manifold/tractor/pkg/agent/systray/subprocess"
 	"github.com/manifold/tractor/pkg/misc/daemon"
 	"github.com/spf13/cobra"
 )
@@ -27,11 +25,15 @@ var (
 
 	tractorUserPath string
 	devMode         bool
+	headless        bool
+	httpAddr        string
 )
 
 func init() {
 	rootCmd.PersistentFlags().BoolVarP(&devMode, "dev", "d", false, "run in debug mode")
-	rootCmd.PersistentFlags().StringVarP(&tractorUserPath, "path", "p", "", "path to the user tractor directory (default is ~/.tractor)")
+	rootCmd.PersistentFlags().StringVarP(&tractorUserPath, "path", "p", "", "path to the user tractor directory (default is $TRACTOR_PATH or ~/.tractor)")
+	rootCmd.PersistentFlags().BoolVar(&headless, "headless", !systrayAvailable, "run without the systray, serving the web dashboard instead")
+	rootCmd.PersistentFlags().StringVar(&httpAddr, "http", "localhost:3002", "web dashboard listener address in headless mode, disabled if empty")
 }
 
 func main() {
@@ -40,7 +42,7 @@ func main() {
 
 func runAgent(cmd *cobra.Command, args []string) {
 	if os.Getenv("SYSTRAY_SUBPROCESS") != "" {
-		subprocess.Run()
+		runSystray()
 		return
 	}
 
@@ -60,9 +62,15 @@ func services(a *agent.Agent) []daemon.Service {
 	services := []daemon.Service{
 		a,
 		a.Console,
-		&systray.Service{},
 		&rpc.Service{},
 	}
+	if headless || !systrayAvailable {
+		if httpAddr != "" {
+			services = append(services, &dashboardService{ListenAddr: httpAddr})
+		}
+	} else {
+		services = append(services, systrayService())
+	}
 	if devMode {
 		services = append(services, []daemon.Service{
 			&selfdev.Service{},
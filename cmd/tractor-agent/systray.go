@@ -0,0 +1,20 @@
+//go:build !headless
+// +build !headless
+
+package main
+
+import (
+	"github.com/manifold/tractor/pkg/agent/systray"
+	"github.com/manifold/tractor/pkg/agent/systray/subprocess"
+	"github.com/manifold/tractor/pkg/misc/daemon"
+)
+
+const systrayAvailable = true
+
+func runSystray() {
+	subprocess.Run()
+}
+
+func systrayService() daemon.Service {
+	return &systray.Service{}
+}
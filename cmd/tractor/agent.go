@@ -3,18 +3,25 @@ package main
 import (
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
 	"time"
 
 	"github.com/manifold/qtalk/golang/mux"
 	qrpc "github.com/manifold/qtalk/golang/rpc"
 	"github.com/manifold/tractor/pkg/agent"
+	"github.com/manifold/tractor/pkg/misc/auth"
 	"github.com/spf13/cobra"
 )
 
 var (
 	tractorUserPath string
 	devMode         bool
+	agentJSON       bool
 )
 
 // `tractor agent` command
@@ -25,11 +32,307 @@ func agentCmd() *cobra.Command {
 		Long:  "Starts the agent systray app.",
 	}
 	cmd.PersistentFlags().BoolVarP(&devMode, "dev", "d", false, "run in debug mode")
-	cmd.PersistentFlags().StringVarP(&tractorUserPath, "path", "p", "", "path to the user tractor directory (default is ~/.tractor)")
+	cmd.PersistentFlags().StringVarP(&tractorUserPath, "path", "p", "", "path to the user tractor directory (default is $TRACTOR_PATH or ~/.tractor)")
 	cmd.AddCommand(agentCallCmd())
+	cmd.AddCommand(agentTokenCmd())
+	cmd.AddCommand(agentLsCmd())
+	cmd.AddCommand(agentStatusCmd())
+	cmd.AddCommand(agentDiagnosticsCmd())
+	cmd.AddCommand(agentCreateCmd())
+	cmd.AddCommand(agentRegisterCmd())
+	cmd.AddCommand(agentUnregisterCmd())
+	cmd.AddCommand(agentRenameCmd())
+	cmd.AddCommand(agentSecretsCmd())
 	return cmd
 }
 
+// `tractor agent create` command
+func agentCreateCmd() *cobra.Command {
+	var params struct {
+		template string
+		module   string
+	}
+	cmd := &cobra.Command{
+		Use:   "create <name> [dir]",
+		Short: "Creates a workspace from a template",
+		Long:  "Creates a workspace in a new directory (default is ./<name>) from a template and registers it with the agent.",
+		Args:  cobra.RangeArgs(1, 2),
+		Run: func(cmd *cobra.Command, args []string) {
+			dir := args[0]
+			if len(args) > 1 {
+				dir = args[1]
+			}
+			template := params.template
+			if template != "" {
+				template = absPath(template)
+			}
+			client, err := dialAgent()
+			fatal(err)
+			var info agent.WorkspaceInfo
+			_, err = client.Call("create", map[string]interface{}{
+				"Name":     args[0],
+				"Path":     absPath(dir),
+				"Template": template,
+				"Module":   params.module,
+			}, &info)
+			fatal(err)
+			fmt.Printf("workspace %q created in %s\n", info.Name, info.TargetPath)
+		},
+	}
+	cmd.Flags().StringVar(&params.template, "template", "", "directory to copy the workspace from (default is the agent template)")
+	cmd.Flags().StringVar(&params.module, "module", "", "module path of the workspace (default is the name)")
+	return cmd
+}
+
+// `tractor agent register` command
+func agentRegisterCmd() *cobra.Command {
+	var name string
+	cmd := &cobra.Command{
+		Use:   "register <dir>",
+		Short: "Registers an existing workspace with the agent",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			client, err := dialAgent()
+			fatal(err)
+			var info agent.WorkspaceInfo
+			_, err = client.Call("register", map[string]interface{}{
+				"Name": name,
+				"Path": absPath(args[0]),
+			}, &info)
+			fatal(err)
+			fmt.Printf("workspace %q registered for %s\n", info.Name, info.TargetPath)
+		},
+	}
+	cmd.Flags().StringVar(&name, "name", "", "name of the workspace (default is the directory name)")
+	return cmd
+}
+
+// `tractor agent unregister` command
+func agentUnregisterCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "unregister <name>",
+		Short: "Stops a workspace and removes it from the agent",
+		Long:  "Stops a workspace and removes it from the agent. The workspace directory is kept.",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			client, err := dialAgent()
+			fatal(err)
+			var msg string
+			_, err = client.Call("unregister", args[0], &msg)
+			fatal(err)
+			fmt.Println(msg)
+		},
+	}
+}
+
+// `tractor agent rename` command
+func agentRenameCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "rename <name> <new-name>",
+		Short: "Renames a workspace",
+		Args:  cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			client, err := dialAgent()
+			fatal(err)
+			var info agent.WorkspaceInfo
+			_, err = client.Call("rename", map[string]interface{}{
+				"Name":    args[0],
+				"NewName": args[1],
+			}, &info)
+			fatal(err)
+			fmt.Printf("workspace %q renamed to %q\n", args[0], info.Name)
+		},
+	}
+}
+
+func absPath(path string) string {
+	abs, err := filepath.Abs(path)
+	fatal(err)
+	return abs
+}
+
+// `tractor agent ls` command
+func agentLsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "ls",
+		Short: "Lists the workspaces of the agent",
+		Long:  "Lists the workspaces of the agent with their status.",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			client, err := dialAgent()
+			fatal(err)
+			var infos []agent.WorkspaceInfo
+			_, err = client.Call("list", nil, &infos)
+			fatal(err)
+			if agentJSON {
+				printJSON(infos)
+				return
+			}
+			w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "NAME\tSTATUS\tPID\tUPTIME\tRESTARTS\tBUILD\tPATH")
+			for _, info := range infos {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n",
+					info.Name, info.Status, pidString(info), uptimeString(info),
+					info.Restarts, buildString(info), info.TargetPath)
+			}
+			w.Flush()
+		},
+	}
+	cmd.Flags().BoolVar(&agentJSON, "json", false, "print workspaces as JSON")
+	return cmd
+}
+
+// `tractor agent status` command
+func agentStatusCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "status [workspace]",
+		Short: "Shows the status of a workspace",
+		Long:  "Shows the status of a workspace, given by its name or path (default is the working directory).",
+		Args:  cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			var name string
+			if len(args) > 0 {
+				name = args[0]
+			} else {
+				wd, err := os.Getwd()
+				fatal(err)
+				name = wd
+			}
+			client, err := dialAgent()
+			fatal(err)
+			var info agent.WorkspaceInfo
+			_, err = client.Call("info", name, &info)
+			fatal(err)
+			if agentJSON {
+				printJSON(info)
+				return
+			}
+			w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			fmt.Fprintf(w, "Name:\t%s\n", info.Name)
+			fmt.Fprintf(w, "Path:\t%s\n", info.TargetPath)
+			fmt.Fprintf(w, "Status:\t%s\n", info.Status)
+			if h := info.Health; h != nil {
+				fmt.Fprintf(w, "Health:\t%s\n", healthString(h))
+				for _, e := range h.Errors {
+					fmt.Fprintf(w, "Component error:\t%s %s: %s\n", e.Node, e.Component, e.Error)
+				}
+			}
+			fmt.Fprintf(w, "PID:\t%s\n", pidString(info))
+			fmt.Fprintf(w, "Uptime:\t%s\n", uptimeString(info))
+			fmt.Fprintf(w, "Restarts:\t%d\n", info.Restarts)
+			fmt.Fprintf(w, "Build:\t%s\n", buildString(info))
+			if b := info.LastBuild; b != nil {
+				fmt.Fprintf(w, "Built:\t%s (%s)\n", time.Unix(b.Time, 0).Format(time.RFC3339), b.Duration.Round(time.Millisecond))
+				if b.Error != "" {
+					fmt.Fprintf(w, "Build error:\t%s\n", b.Error)
+				}
+			}
+			if r := info.Rollback; r != nil {
+				fmt.Fprintf(w, "Rolled back:\t%s, %s\n", time.Unix(r.Time, 0).Format(time.RFC3339), r.Error)
+			}
+			fmt.Fprintf(w, "Console:\t%d pipe(s), %d written\n", info.Console.Pipes, info.Console.Written)
+			if info.CrashLooping {
+				fmt.Fprintf(w, "Crash looping:\tnot restarting until the workspace changes\n")
+			}
+			if n := len(info.Exits); n > 0 {
+				e := info.Exits[n-1]
+				fmt.Fprintf(w, "Last exit:\tcode %d at %s after %s\n", e.Code, time.Unix(e.Time, 0).Format(time.RFC3339), e.Uptime.Round(time.Millisecond))
+			}
+			w.Flush()
+			if n := len(info.Exits); n > 0 && info.Exits[n-1].Stderr != "" {
+				fmt.Println("Last exit stderr:")
+				for _, line := range strings.Split(strings.TrimRight(info.Exits[n-1].Stderr, "\n"), "\n") {
+					fmt.Println("  " + line)
+				}
+			}
+		},
+	}
+	cmd.Flags().BoolVar(&agentJSON, "json", false, "print the status as JSON")
+	return cmd
+}
+
+// `tractor agent diagnostics` command
+func agentDiagnosticsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "diagnostics [workspace]",
+		Short: "Shows the build diagnostics of a workspace",
+		Long:  "Shows the compiler errors and go vet warnings of the last build of a workspace, given by its name or path (default is the working directory).",
+		Args:  cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			var name string
+			if len(args) > 0 {
+				name = args[0]
+			} else {
+				wd, err := os.Getwd()
+				fatal(err)
+				name = wd
+			}
+			client, err := dialAgent()
+			fatal(err)
+			var diags []agent.Diagnostic
+			_, err = client.Call("diagnostics", name, &diags)
+			fatal(err)
+			if agentJSON {
+				printJSON(diags)
+				return
+			}
+			for _, d := range diags {
+				pos := fmt.Sprintf("%s:%d", d.File, d.Line)
+				if d.Column > 0 {
+					pos += fmt.Sprintf(":%d", d.Column)
+				}
+				fmt.Printf("%s: %s: %s\n", pos, d.Severity, strings.Replace(d.Message, "\n", "\n\t", -1))
+			}
+		},
+	}
+	cmd.Flags().BoolVar(&agentJSON, "json", false, "print the diagnostics as JSON")
+	return cmd
+}
+
+func pidString(info agent.WorkspaceInfo) string {
+	if info.PID == 0 {
+		return "-"
+	}
+	return strconv.Itoa(info.PID)
+}
+
+func uptimeString(info agent.WorkspaceInfo) string {
+	if info.Started == 0 {
+		return "-"
+	}
+	return info.Uptime.Round(time.Second).String()
+}
+
+func healthString(h *agent.Health) string {
+	switch {
+	case h.Error != "":
+		return "not answering: " + h.Error
+	case !h.ImageLoaded:
+		return "loading image"
+	case !h.Initialized:
+		return "initializing components"
+	case len(h.Errors) > 0:
+		return fmt.Sprintf("ready with %d component errors", len(h.Errors))
+	default:
+		return "ready"
+	}
+}
+
+func buildString(info agent.WorkspaceInfo) string {
+	switch b := info.LastBuild; {
+	case b == nil:
+		return "-"
+	case b.Error != "":
+		return fmt.Sprintf("failed (%d errors)", b.Errors)
+	case info.Rollback != nil:
+		return "rolled back"
+	case b.Warnings > 0:
+		return fmt.Sprintf("ok (%d warnings)", b.Warnings)
+	default:
+		return "ok"
+	}
+}
+
 // `tractor agent call` command
 func agentCallCmd() *cobra.Command {
 	cmd := &cobra.Command{
@@ -79,20 +382,74 @@ func runAgentCall() func(cmd *cobra.Command, args []string) {
 	}
 }
 
-func agentQRPCCall(w io.Writer, cmd, arg string) (string, error) {
+// `tractor agent token` command
+func agentTokenCmd() *cobra.Command {
+	var params struct {
+		role      string
+		workspace string
+		ttl       time.Duration
+	}
+	cmd := &cobra.Command{
+		Use:   "token",
+		Short: "Issues a token to access the agent and workspaces",
+		Long:  "Issues a token to access the agent and workspaces. Roles are viewer, invoker and editor.",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			client, err := dialAgent()
+			fatal(err)
+			var token string
+			_, err = client.Call("issueToken", map[string]interface{}{
+				"Role":      params.role,
+				"Workspace": params.workspace,
+				"TTL":       int(params.ttl / time.Second),
+			}, &token)
+			fatal(err)
+			fmt.Println(token)
+		},
+	}
+	cmd.Flags().StringVar(&params.role, "role", string(auth.Viewer), "role granted by the token")
+	cmd.Flags().StringVar(&params.workspace, "workspace", "", "limits the token to a workspace")
+	cmd.Flags().DurationVar(&params.ttl, "ttl", 24*time.Hour, "time until the token expires, 0 for no expiry")
+	return cmd
+}
+
+// dialAgent connects to the agent and authenticates with the token in
+// TRACTOR_TOKEN, or the token the agent wrote to its path.
+func dialAgent() (*qrpc.Client, error) {
 	var sess mux.Session
 	var err error
+	token := os.Getenv("TRACTOR_TOKEN")
 	if os.Getenv("QRPC_HOST") != "" {
 		sess, err = mux.DialWebsocket(os.Getenv("QRPC_HOST"))
 	} else {
 		ag := openAgent()
+		if token == "" {
+			b, err := ioutil.ReadFile(ag.TokenPath)
+			if err != nil {
+				return nil, err
+			}
+			token = string(b)
+		}
 		sess, err = mux.DialUnix(ag.SocketPath)
 	}
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	client := &qrpc.Client{Session: sess}
+	if _, err := client.Call("authenticate", token, nil); err != nil {
+		sess.Close()
+		return nil, err
+	}
+	return client, nil
+}
+
+func agentQRPCCall(w io.Writer, cmd, arg string) (string, error) {
+	client, err := dialAgent()
+	if err != nil {
+		return "", err
+	}
+
 	var msg string
 	resp, err := client.Call(cmd, arg, &msg)
 	if err != nil {
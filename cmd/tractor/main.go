@@ -30,6 +30,7 @@ func main() {
 
 func init() {
 	rootCmd.AddCommand(agentCmd())
+	rootCmd.AddCommand(wsCmd())
 
 	ct, cancelFunc := context.WithCancel(context.Background())
 	sigQuit = ct
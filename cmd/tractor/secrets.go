@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// `tractor agent secrets` command
+func agentSecretsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "secrets",
+		Short: "Manages the secrets of workspace profiles",
+		Long:  "Manages the secrets of workspace profiles in the encrypted secrets file of the agent.",
+	}
+	cmd.AddCommand(&cobra.Command{
+		Use:   "ls",
+		Short: "Lists the names of the secrets",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			names, err := openAgent().Secrets.Names()
+			fatal(err)
+			for _, name := range names {
+				fmt.Println(name)
+			}
+		},
+	})
+	cmd.AddCommand(&cobra.Command{
+		Use:   "set <name> [value]",
+		Short: "Sets a secret",
+		Long:  "Sets a secret to the value, or to the standard input without its trailing newline if no value is given.",
+		Args:  cobra.RangeArgs(1, 2),
+		Run: func(cmd *cobra.Command, args []string) {
+			var value string
+			if len(args) > 1 {
+				value = args[1]
+			} else {
+				b, err := ioutil.ReadAll(os.Stdin)
+				fatal(err)
+				value = strings.TrimRight(string(b), "\r\n")
+			}
+			fatal(openAgent().Secrets.Set(args[0], value))
+		},
+	})
+	cmd.AddCommand(&cobra.Command{
+		Use:   "rm <name>",
+		Short: "Removes a secret",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			fatal(openAgent().Secrets.Delete(args[0]))
+		},
+	})
+	return cmd
+}
@@ -0,0 +1,516 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/chzyer/readline"
+	"github.com/manifold/qtalk/golang/mux"
+	qrpc "github.com/manifold/qtalk/golang/rpc"
+	"github.com/manifold/tractor/pkg/manifold"
+	"github.com/manifold/tractor/pkg/workspace/rpc"
+	"github.com/manifold/tractor/pkg/workspace/view"
+	"github.com/spf13/cobra"
+)
+
+var wsFlags struct {
+	workspace string
+	addr      string
+	json      bool
+}
+
+// `tractor ws` command
+func wsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "ws",
+		Short: "Inspects and changes a running workspace",
+		Long: `Inspects and changes a running workspace.
+
+Nodes are addressed by their path, like /Parent/Node. Fields and methods are
+addressed by the node path followed by the component name and the field or
+method name, like /Parent/Node/Component/Field.`,
+	}
+	cmd.PersistentFlags().StringVarP(&wsFlags.workspace, "workspace", "w", "", "name or path of an agent workspace (default is the working directory)")
+	cmd.PersistentFlags().StringVar(&wsFlags.addr, "addr", "", "address of the workspace, a unix socket path or websocket host:port")
+	cmd.PersistentFlags().BoolVar(&wsFlags.json, "json", false, "print results as JSON")
+	cmd.PersistentFlags().StringVarP(&tractorUserPath, "path", "p", "", "path to the user tractor directory (default is $TRACTOR_PATH or ~/.tractor)")
+	cmd.AddCommand(
+		wsLsCmd(),
+		wsTreeCmd(),
+		wsGetCmd(),
+		wsSetCmd(),
+		wsAddNodeCmd(),
+		wsRmCmd(),
+		wsMvCmd(),
+		wsAddComponentCmd(),
+		wsRmComponentCmd(),
+		wsCallCmd(),
+		wsWatchCmd(),
+		wsReplCmd(),
+	)
+	return cmd
+}
+
+// `tractor ws ls` command
+func wsLsCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "ls [path]",
+		Short: "Lists the children of a node",
+		Args:  cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			client := dialWorkspace(nil)
+			node := resolveNode(client, pathArg(args))
+			var children []rpc.NodeInfo
+			output(client, "listChildren", node.ID, &children, func() {
+				w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+				for _, child := range children {
+					name := child.Name
+					if child.Children > 0 {
+						name += "/"
+					}
+					fmt.Fprintf(w, "%s\t%s\n", name, child.ID)
+				}
+				w.Flush()
+			})
+		},
+	}
+}
+
+// `tractor ws tree` command
+func wsTreeCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "tree [path]",
+		Short: "Prints a node and its descendants with their components",
+		Args:  cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			client := dialWorkspace(nil)
+			node := resolveNode(client, pathArg(args))
+			var snapshots []manifold.ObjectSnapshot
+			output(client, "getSnapshot", node.ID, &snapshots, func() {
+				objects := make(map[string]manifold.ObjectSnapshot)
+				for _, obj := range snapshots {
+					objects[obj.ID] = obj
+				}
+				var print func(obj manifold.ObjectSnapshot, name, indent string)
+				print = func(obj manifold.ObjectSnapshot, name, indent string) {
+					var coms []string
+					for _, com := range obj.Components {
+						coms = append(coms, com.Name)
+					}
+					if len(coms) > 0 {
+						name += " [" + strings.Join(coms, ", ") + "]"
+					}
+					fmt.Println(indent + name)
+					for _, child := range obj.Children {
+						print(objects[child[0]], child[1], indent+"  ")
+					}
+				}
+				if len(snapshots) > 0 {
+					print(snapshots[0], node.NodePath, "")
+				}
+			})
+		},
+	}
+}
+
+// `tractor ws get` command
+func wsGetCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "get <path>",
+		Short: "Prints a node, a component or the value of a field",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			client := dialWorkspace(nil)
+			resolved := resolve(client, args[0])
+			parts := strings.SplitN(resolved.LocalPath, "/", 2)
+			if len(parts) == 2 {
+				var field rpc.FieldValue
+				output(client, "getField", args[0], &field, func() {
+					printJSON(jsonValue(field.Value))
+				})
+				return
+			}
+			var node view.Node
+			output(client, "getNode", nodeID(resolved), &node, func() {
+				fmt.Printf("%s (%s)\n", node.Path, node.ID)
+				for _, com := range node.Components {
+					if parts[0] != "" && com.Name != parts[0] {
+						continue
+					}
+					fmt.Printf("  %s\n", com.Name)
+					printFields(com.Fields, "    ")
+				}
+			})
+		},
+	}
+}
+
+// `tractor ws set` command
+func wsSetCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "set <path> <json>",
+		Short: "Sets the value of a field to a JSON value",
+		Long:  "Sets the value of a field to a JSON value, which is decoded into the type of the field. Strings have to be quoted.",
+		Args:  cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			if !json.Valid([]byte(args[1])) {
+				fatal(fmt.Errorf("invalid JSON value: %s", args[1]))
+			}
+			client := dialWorkspace(nil)
+			call(client, "setValue", rpc.SetValueParams{Path: args[0], JSONValue: &args[1]}, nil)
+		},
+	}
+}
+
+// `tractor ws add-node` command
+func wsAddNodeCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "add-node <parent path> <name>",
+		Short: "Appends a new node to a node",
+		Args:  cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			client := dialWorkspace(nil)
+			parent := resolveNode(client, args[0])
+			call(client, "appendNode", rpc.AppendNodeParams{ID: parent.ID, Name: args[1]}, nil)
+		},
+	}
+}
+
+// `tractor ws rm` command
+func wsRmCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "rm <path>",
+		Short: "Deletes a node",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			client := dialWorkspace(nil)
+			node := resolveNode(client, args[0])
+			call(client, "deleteNode", node.ID, nil)
+		},
+	}
+}
+
+// `tractor ws mv` command
+func wsMvCmd() *cobra.Command {
+	var parentPath string
+	cmd := &cobra.Command{
+		Use:   "mv <path> <index>",
+		Short: "Moves a node to an index among its siblings, or the children of another node",
+		Args:  cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			index, err := strconv.Atoi(args[1])
+			if err != nil {
+				fatal(fmt.Errorf("invalid index: %s", args[1]))
+			}
+			client := dialWorkspace(nil)
+			node := resolveNode(client, args[0])
+			params := rpc.MoveNodeParams{ID: node.ID, Index: index}
+			if parentPath != "" {
+				parent := resolveNode(client, parentPath)
+				params.Parent = &parent.ID
+			}
+			call(client, "moveNode", params, nil)
+		},
+	}
+	cmd.Flags().StringVar(&parentPath, "parent", "", "path of the node to move the node into")
+	return cmd
+}
+
+// `tractor ws add-component` command
+func wsAddComponentCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "add-component <path> <component>",
+		Short: "Adds a registered component to a node",
+		Args:  cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			client := dialWorkspace(nil)
+			node := resolveNode(client, args[0])
+			call(client, "appendComponent", rpc.AppendNodeParams{ID: node.ID, Name: args[1]}, nil)
+		},
+	}
+}
+
+// `tractor ws rm-component` command
+func wsRmComponentCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "rm-component <path> <component>",
+		Short: "Removes a component from a node",
+		Args:  cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			client := dialWorkspace(nil)
+			node := resolveNode(client, args[0])
+			call(client, "removeComponent", rpc.RemoveComponentParams{ID: node.ID, Component: args[1]}, nil)
+		},
+	}
+}
+
+// `tractor ws call` command
+func wsCallCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "call <path>",
+		Short: "Calls a method of a component",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			client := dialWorkspace(nil)
+			call(client, "callMethod", args[0], nil)
+		},
+	}
+}
+
+// `tractor ws watch` command
+func wsWatchCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "watch",
+		Short: "Prints changes to the workspace as they happen",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			api := qrpc.NewAPI()
+			api.HandleFunc("state", func(r qrpc.Responder, c *qrpc.Call) {
+				var state view.ClientState
+				if err := c.Decode(&state); err != nil {
+					fmt.Fprintln(os.Stderr, err)
+				} else if wsFlags.json {
+					printJSON(map[string]interface{}{"version": state.Version, "nodes": len(state.Nodes)})
+				} else {
+					fmt.Printf("state version %d, %d nodes\n", state.Version, len(state.Nodes))
+				}
+				r.Return(nil)
+			})
+			api.HandleFunc("patch", func(r qrpc.Responder, c *qrpc.Call) {
+				var update view.Update
+				if err := c.Decode(&update); err != nil {
+					fmt.Fprintln(os.Stderr, err)
+				}
+				for _, patch := range update.Patches {
+					if wsFlags.json {
+						printJSON(map[string]interface{}{
+							"version": update.Version,
+							"op":      patch.Op,
+							"path":    patch.Path,
+							"value":   jsonValue(patch.Value),
+						})
+						continue
+					}
+					fmt.Printf("%d %s %s\n", update.Version, patch.Op, patch.Path)
+				}
+				r.Return(nil)
+			})
+			api.HandleFunc("shutdown", func(r qrpc.Responder, c *qrpc.Call) {
+				r.Return(nil)
+				fatal(errors.New("workspace shut down"))
+			})
+			client := dialWorkspace(api)
+			go client.ServeAPI()
+			call(client, "subscribe", rpc.SubscribeParams{Patches: true, Name: "tractor ws watch"}, nil)
+			<-sigQuit.Done()
+		},
+	}
+}
+
+// `tractor ws repl` command
+func wsReplCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "repl",
+		Short: "Starts a JavaScript REPL in the workspace",
+		Long: `Starts a JavaScript REPL in the workspace with access to the live object tree.
+
+Globals:
+  root               the root object
+  walk(obj, fn)      calls fn for the descendants of obj
+  find(path)         the object at a path
+  findID(id)         the object with an ID
+  component(path)    the value of the component at a path
+  get(path)          the value of the field at a path
+  set(path, value)   sets the field at a path
+  call(path)         calls the method at a path
+  components()       the registered component types
+  print(...values)   prints values`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			client := dialWorkspace(nil)
+			resp, err := client.Call("repl", nil, nil)
+			fatal(err)
+			if !resp.Hijacked {
+				fatal(errors.New("workspace did not start a REPL"))
+			}
+			ch := resp.Channel
+			defer ch.Close()
+
+			rl, err := readline.NewEx(&readline.Config{
+				Prompt:      "> ",
+				HistoryFile: filepath.Join(openAgent().Path, "repl_history"),
+			})
+			fatal(err)
+			defer rl.Close()
+
+			go func() {
+				io.Copy(rl.Stdout(), ch)
+				rl.Close()
+			}()
+			for {
+				line, err := rl.Readline()
+				if err == readline.ErrInterrupt {
+					continue
+				}
+				if err != nil {
+					return
+				}
+				if _, err := io.WriteString(ch, line+"\n"); err != nil {
+					return
+				}
+			}
+		},
+	}
+}
+
+// dialWorkspace connects to the workspace at the address flag, or the agent
+// workspace of the workspace flag or working directory. It authenticates
+// with the token in TRACTOR_TOKEN, or the token written by the agent.
+func dialWorkspace(api qrpc.API) *qrpc.Client {
+	addr := wsFlags.addr
+	token := os.Getenv("TRACTOR_TOKEN")
+	if addr == "" || token == "" {
+		ag := openAgent()
+		if addr == "" {
+			name := wsFlags.workspace
+			if name == "" {
+				wd, err := os.Getwd()
+				fatal(err)
+				name = wd
+			}
+			ws := ag.Workspace(name)
+			if ws == nil {
+				fatal(fmt.Errorf("no workspace found for %q", name))
+			}
+			addr = ws.SocketPath
+		}
+		if token == "" {
+			b, err := ioutil.ReadFile(ag.TokenPath)
+			if err != nil && !os.IsNotExist(err) {
+				fatal(err)
+			}
+			token = string(b)
+		}
+	}
+
+	var sess mux.Session
+	var err error
+	if strings.HasPrefix(addr, "/") || strings.HasSuffix(addr, ".sock") {
+		sess, err = mux.DialUnix(addr)
+	} else {
+		sess, err = mux.DialWebsocket(addr)
+	}
+	fatal(err)
+
+	client := &qrpc.Client{Session: sess, API: api}
+	if token != "" {
+		call(client, "authenticate", token, nil)
+	}
+	return client
+}
+
+func call(client *qrpc.Client, method string, args, reply interface{}) {
+	_, err := client.Call(method, args, reply)
+	fatal(err)
+}
+
+// output calls a method and prints its reply as JSON with the json flag, or
+// with the human readable print func otherwise.
+func output(client *qrpc.Client, method string, args, reply interface{}, print func()) {
+	if wsFlags.json {
+		var v interface{}
+		call(client, method, args, &v)
+		printJSON(jsonValue(v))
+		return
+	}
+	call(client, method, args, reply)
+	print()
+}
+
+func resolve(client *qrpc.Client, path string) rpc.ResolvedPath {
+	var resolved rpc.ResolvedPath
+	call(client, "resolvePath", path, &resolved)
+	return resolved
+}
+
+// resolveNode resolves a path that has to refer to a node.
+func resolveNode(client *qrpc.Client, path string) rpc.ResolvedPath {
+	resolved := resolve(client, path)
+	if resolved.LocalPath != "" {
+		fatal(fmt.Errorf("not a node path: %s", path))
+	}
+	resolved.ID = nodeID(resolved)
+	return resolved
+}
+
+// nodeID returns the ID of a resolved node, which is empty for the root
+// object as handlers use it for empty IDs.
+func nodeID(resolved rpc.ResolvedPath) string {
+	if resolved.NodePath == "/" {
+		return ""
+	}
+	return resolved.ID
+}
+
+func pathArg(args []string) string {
+	if len(args) == 0 {
+		return "/"
+	}
+	return args[0]
+}
+
+func printFields(fields []view.Field, indent string) {
+	for _, f := range fields {
+		name := f.Name
+		if name == "" {
+			name = f.Path[strings.LastIndex(f.Path, "/")+1:]
+		}
+		if f.Fields != nil {
+			fmt.Printf("%s%s:\n", indent, name)
+			printFields(f.Fields, indent+"  ")
+			continue
+		}
+		b, _ := json.Marshal(jsonValue(f.Value))
+		fmt.Printf("%s%s = %s\n", indent, name, b)
+	}
+}
+
+func printJSON(v interface{}) {
+	b, err := json.MarshalIndent(v, "", "  ")
+	fatal(err)
+	fmt.Println(string(b))
+}
+
+// jsonValue converts maps decoded from msgpack into maps with string keys.
+func jsonValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			m[fmt.Sprint(k)] = jsonValue(e)
+		}
+		return m
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			m[k] = jsonValue(e)
+		}
+		return m
+	case []interface{}:
+		s := make([]interface{}, len(v))
+		for i, e := range v {
+			s[i] = jsonValue(e)
+		}
+		return s
+	default:
+		return v
+	}
+}
@@ -3,10 +3,13 @@ module github.com/manifold/tractor
 go 1.13
 
 require (
+	github.com/BurntSushi/toml v0.3.1
 	github.com/armon/circbuf v0.0.0-20190214190532-5111143e8da2
+	github.com/chzyer/readline v1.5.1
 	github.com/d5/tengo v1.24.3
 	github.com/davecgh/go-spew v1.1.1
 	github.com/daviddengcn/go-colortext v0.0.0-20180409174941-186a3d44e920
+	github.com/dop251/goja v0.0.0-20230806174421-c933cf95e127
 	github.com/dustin/go-jsonpointer v0.0.0
 	github.com/fsnotify/fsnotify v1.4.7
 	github.com/getlantern/systray v0.0.0-20191210013027-82c477f5e254
@@ -39,10 +42,11 @@ require (
 	go.uber.org/atomic v1.5.1 // indirect
 	go.uber.org/multierr v1.4.0 // indirect
 	go.uber.org/zap v1.13.0
-	golang.org/x/crypto v0.0.0-20191117063200-497ca9f6d64f
+	golang.org/x/crypto v0.0.0-20210921155107-089bfa567519
 	golang.org/x/lint v0.0.0-20191125180803-fdd1cda4f05f // indirect
 	golang.org/x/net v0.0.0-20191118183410-d06c31c94cae // indirect
-	golang.org/x/sys v0.0.0-20191210023423-ac6580df4449 // indirect
+	golang.org/x/sys v0.7.0 // indirect
+	golang.org/x/text v0.13.0 // indirect
 	golang.org/x/tools v0.0.0-20191205215504-7b8c8591a921 // indirect
 	google.golang.org/appengine v1.6.5 // indirect
 	gopkg.in/sorcix/irc.v2 v2.0.0-20190306112350-8d7a73540b90
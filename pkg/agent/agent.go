@@ -14,9 +14,13 @@ import (
 
 	"github.com/fsnotify/fsnotify"
 	"github.com/manifold/tractor/pkg/agent/console"
+	"github.com/manifold/tractor/pkg/misc/auth"
+	"github.com/manifold/tractor/pkg/misc/config"
 	"github.com/manifold/tractor/pkg/misc/daemon"
 	"github.com/manifold/tractor/pkg/misc/logging"
 	"github.com/manifold/tractor/pkg/misc/logging/null"
+	"github.com/manifold/tractor/pkg/misc/secrets"
+	"github.com/manifold/tractor/pkg/misc/subcmd"
 )
 
 // Agent manages multiple workspaces in a directory (default: ~/.tractor).
@@ -26,20 +30,35 @@ type Agent struct {
 	WorkspacesPath       string // ~/.tractor/workspaces
 	WorkspaceSocketsPath string // ~/.tractor/sockets
 	WorkspaceBinPath     string // ~/.tractor/bin
+	AuthKeyPath          string // ~/.tractor/auth.key
+	TokenPath            string // ~/.tractor/token
+	ConfigPath           string // ~/.tractor/config.toml
+	SecretsPath          string // ~/.tractor/secrets
+	SecretsKeyPath       string // ~/.tractor/secrets.key
+	TemplatePath         string // ~/.tractor/template, or ./data/workspace in dev mode
 	GoBin                string
 	DevMode              bool
+	RestartPolicy        subcmd.RestartPolicy // of workspace daemons
+	RollbackGrace        time.Duration        // time a new workspace binary must run to be kept
+	HealthInterval       time.Duration        // between health checks of workspace daemons
+	ReadyTimeout         time.Duration        // time workspace daemons have to become ready
 
 	Daemon  *daemon.Daemon
 	Console *console.Service
 	Logger  logging.Logger
+	Secrets *secrets.Store // of workspace profiles
 
-	WorkspacesChanged chan struct{}
+	WorkspacesChanged chan struct{} // buffered, changes are coalesced
 	workspaces        map[string]*Workspace
 	mu                sync.RWMutex
+
+	config Config
+	cfgMu  sync.Mutex
 }
 
-// Open returns a new agent for the given path. If the given path is empty, a
-// default of ~/.tractor will be used.
+// Open returns a new agent for the given path. If the given path is empty,
+// the path in TRACTOR_PATH or a default of ~/.tractor will be used. The agent
+// is configured by the config.toml in its path.
 func Open(path string, console *console.Service, devMode bool) (*Agent, error) {
 	bin, err := exec.LookPath("go")
 	if err != nil {
@@ -52,10 +71,17 @@ func Open(path string, console *console.Service, devMode bool) (*Agent, error) {
 		Logger:            console,
 		Path:              path,
 		GoBin:             bin,
+		RestartPolicy:     DefaultRestartPolicy,
+		RollbackGrace:     DefaultRollbackGrace,
+		HealthInterval:    DefaultHealthInterval,
+		ReadyTimeout:      DefaultReadyTimeout,
 		workspaces:        make(map[string]*Workspace),
-		WorkspacesChanged: make(chan struct{}),
+		WorkspacesChanged: make(chan struct{}, 1),
 	}
 
+	if len(a.Path) == 0 {
+		a.Path = os.Getenv(config.PathEnv)
+	}
 	if len(a.Path) == 0 {
 		p, err := defaultPath()
 		if err != nil {
@@ -64,10 +90,26 @@ func Open(path string, console *console.Service, devMode bool) (*Agent, error) {
 		a.Path = p
 	}
 
+	a.ConfigPath = filepath.Join(a.Path, ConfigFile)
+	if a.config, err = LoadConfig(a.ConfigPath); err != nil {
+		return nil, err
+	}
 	a.SocketPath = filepath.Join(a.Path, "agent.sock")
-	a.WorkspacesPath = filepath.Join(a.Path, "workspaces")
-	a.WorkspaceBinPath = filepath.Join(a.Path, "bin")
-	a.WorkspaceSocketsPath = filepath.Join(a.Path, "sockets")
+	a.WorkspacesPath = a.path(a.config.Paths.Workspaces)
+	a.WorkspaceBinPath = a.path(a.config.Paths.Bin)
+	a.WorkspaceSocketsPath = a.path(a.config.Paths.Sockets)
+	a.AuthKeyPath = filepath.Join(a.Path, "auth.key")
+	a.TokenPath = filepath.Join(a.Path, "token")
+	a.SecretsPath = filepath.Join(a.Path, "secrets")
+	a.SecretsKeyPath = filepath.Join(a.Path, "secrets.key")
+	a.Secrets = secrets.Open(a.SecretsPath, a.SecretsKeyPath)
+	a.TemplatePath = a.path(a.config.Paths.Template)
+	if devMode {
+		// the dev agent runs from the tractor source
+		if p, err := filepath.Abs(filepath.Join("data", "workspace")); err == nil {
+			a.TemplatePath = p
+		}
+	}
 	if a.Logger == nil {
 		a.Logger = &null.Logger{}
 	}
@@ -80,6 +122,10 @@ func Open(path string, console *console.Service, devMode bool) (*Agent, error) {
 }
 
 func (a *Agent) InitializeDaemon() (err error) {
+	if err := a.writeToken(); err != nil {
+		return err
+	}
+
 	spaces, err := a.Workspaces()
 	if err != nil {
 		return err
@@ -94,6 +140,45 @@ func (a *Agent) Serve(ctx context.Context) {
 	a.Watch(ctx)
 }
 
+// IssueToken returns a token for the claims, which the agent and its
+// workspaces accept.
+func (a *Agent) IssueToken(c auth.Claims) (string, error) {
+	key, err := auth.LoadKey(a.AuthKeyPath)
+	if err != nil {
+		return "", err
+	}
+	return auth.Issue(key, c)
+}
+
+// VerifyToken returns the claims of a token issued by the agent.
+func (a *Agent) VerifyToken(token string) (auth.Claims, error) {
+	key, err := auth.LoadKey(a.AuthKeyPath)
+	if err != nil {
+		return auth.Claims{}, err
+	}
+	return auth.Verify(key, token)
+}
+
+// VerifyExchangeToken returns the claims of an exchange token issued by the
+// agent.
+func (a *Agent) VerifyExchangeToken(token string) (auth.Claims, error) {
+	key, err := auth.LoadKey(a.AuthKeyPath)
+	if err != nil {
+		return auth.Claims{}, err
+	}
+	return auth.VerifyExchange(key, token)
+}
+
+// writeToken writes an editor token to TokenPath, readable only by the user,
+// for local clients like the CLI and studio to authenticate with.
+func (a *Agent) writeToken() error {
+	token, err := a.IssueToken(auth.Claims{Role: auth.Editor})
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(a.TokenPath, []byte(token), 0600)
+}
+
 // Workspace returns a Workspace for the given path. The path must match
 // either:
 //   * the workspace symlink's basename in the agent's WorkspacesPath.
@@ -204,7 +289,9 @@ func (a *Agent) Watch(ctx context.Context) {
 		return
 	}
 	watcher.Add(a.WorkspacesPath)
+	watcher.Add(a.Path)
 	debounce := Debounce(20 * time.Millisecond)
+	debounceConfig := Debounce(20 * time.Millisecond)
 	for {
 		select {
 		case <-ctx.Done():
@@ -217,9 +304,21 @@ func (a *Agent) Watch(ctx context.Context) {
 			if event.Op&fsnotify.Chmod == fsnotify.Chmod {
 				continue
 			}
+			if event.Name == a.ConfigPath {
+				debounceConfig(a.reloadConfig)
+				continue
+			}
+			if filepath.Dir(event.Name) != a.WorkspacesPath {
+				continue
+			}
 
 			debounce(func() {
-				a.WorkspacesChanged <- struct{}{}
+				// not blocking without a reader, like in headless mode; a
+				// change already pending covers this one
+				select {
+				case a.WorkspacesChanged <- struct{}{}:
+				default:
+				}
 			})
 
 		case err, ok := <-watcher.Errors:
@@ -1,12 +1,14 @@
 package agent
 
 import (
+	"context"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"runtime"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -99,6 +101,31 @@ func wsNames(wss []*Workspace) []string {
 	return names
 }
 
+func TestAgentWatchWithoutReader(t *testing.T) {
+	ag, teardown := setup(t)
+	defer teardown()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go ag.Watch(ctx)
+	time.Sleep(50 * time.Millisecond)
+
+	// nothing reads the changes, like in headless mode
+	wspath := filepath.Join(pkgpath, "testworkspace")
+	for _, name := range []string{"a", "b", "c"} {
+		require.NoError(t, os.Symlink(wspath, filepath.Join(ag.WorkspacesPath, name)))
+		time.Sleep(100 * time.Millisecond)
+	}
+	assert.Len(t, ag.WorkspacesChanged, 1)
+	<-ag.WorkspacesChanged
+
+	require.NoError(t, os.Remove(filepath.Join(ag.WorkspacesPath, "a")))
+	select {
+	case <-ag.WorkspacesChanged:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no change after the pending one was read")
+	}
+}
+
 func setup(t *testing.T, extradirs ...string) (*Agent, func()) {
 	return setupAgent(t, func(ag *Agent) {
 		err := os.Symlink(filepath.Join(pkgpath, "errworkspace"), filepath.Join(ag.WorkspacesPath, "err"))
@@ -135,5 +162,7 @@ func setupAgent(t *testing.T, setupFn func(*Agent)) (*Agent, func()) {
 func newAgent(t *testing.T, path string) *Agent {
 	ag, err := Open(path, nil, false)
 	assert.Nil(t, err)
+	// the test workspaces don't serve the health RPC
+	ag.HealthInterval = 0
 	return ag
 }
@@ -0,0 +1,145 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"path/filepath"
+
+	"github.com/manifold/tractor/pkg/misc/config"
+)
+
+// ConfigFile is the name of the configuration file in the agent path.
+const ConfigFile = "config.toml"
+
+// Config is the configuration of the agent. The paths apply after restarting
+// the agent, the watch interval and the console buffer to workspaces opened
+// after a change, and the other settings right away.
+type Config struct {
+	StudioURL     string       `toml:"studio_url" env:"TRACTOR_STUDIO_URL"`
+	ConsoleBuffer int          `toml:"console_buffer" env:"TRACTOR_CONSOLE_BUFFER"` // bytes of recent output kept per workspace
+	Watch         config.Watch `toml:"watch"`                                       // unless set by the tractor.toml of a workspace
+	Paths         ConfigPaths  `toml:"paths"`
+}
+
+// ConfigPaths are the directories of the agent, relative to its path unless
+// they are absolute.
+type ConfigPaths struct {
+	Workspaces string `toml:"workspaces" env:"TRACTOR_WORKSPACES_PATH"`
+	Sockets    string `toml:"sockets" env:"TRACTOR_SOCKETS_PATH"`
+	Bin        string `toml:"bin" env:"TRACTOR_BIN_PATH"`
+	Template   string `toml:"template" env:"TRACTOR_TEMPLATE_PATH"`
+}
+
+// DefaultConfig returns the configuration of an agent without a config.toml.
+func DefaultConfig() Config {
+	return Config{
+		StudioURL:     "http://localhost:3000",
+		ConsoleBuffer: 1024 * 1024,
+		Watch: config.Watch{
+			Extensions: []string{".go", ".ts", ".tsx", ".js", ".jsx", ".html"},
+			Ignore:     []string{"node_modules"},
+			Interval:   config.Duration(WatchInterval),
+		},
+		Paths: ConfigPaths{
+			Workspaces: "workspaces",
+			Sockets:    "sockets",
+			Bin:        "bin",
+			Template:   "template",
+		},
+	}
+}
+
+// LoadConfig loads the configuration file at path over the defaults.
+func LoadConfig(path string) (Config, error) {
+	c := DefaultConfig()
+	err := config.Load(path, &c)
+	return c, err
+}
+
+func (c *Config) Validate() error {
+	u, err := url.Parse(c.StudioURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("studio_url must be an http or https URL: %q", c.StudioURL)
+	}
+	if c.ConsoleBuffer <= 0 {
+		return errors.New("console_buffer must be positive")
+	}
+	if c.Watch.Interval <= 0 {
+		return errors.New("watch.interval must be positive")
+	}
+	for name, p := range map[string]string{
+		"workspaces": c.Paths.Workspaces,
+		"sockets":    c.Paths.Sockets,
+		"bin":        c.Paths.Bin,
+		"template":   c.Paths.Template,
+	} {
+		if p == "" {
+			return fmt.Errorf("paths.%s must not be empty", name)
+		}
+	}
+	return c.Watch.Validate()
+}
+
+// Config returns the current configuration of the agent.
+func (a *Agent) Config() Config {
+	a.cfgMu.Lock()
+	defer a.cfgMu.Unlock()
+	return a.config
+}
+
+// path returns p relative to the agent path unless it is absolute.
+func (a *Agent) path(p string) string {
+	if filepath.IsAbs(p) {
+		return p
+	}
+	return filepath.Join(a.Path, p)
+}
+
+// reloadConfig loads the changed configuration file and passes the watch
+// settings to the workspaces. An invalid file is logged and ignored.
+func (a *Agent) reloadConfig() {
+	c, err := LoadConfig(a.ConfigPath)
+	if err != nil {
+		logErr(a.Logger, err)
+		return
+	}
+	a.cfgMu.Lock()
+	a.config = c
+	a.cfgMu.Unlock()
+	info(a.Logger, "[agent] reloaded", a.ConfigPath)
+
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	for _, ws := range a.workspaces {
+		ws.setWatchDefaults(c.Watch)
+	}
+}
+
+// loadConfig loads the tractor.toml of the workspace. The environment of the
+// agent doesn't override it. An invalid file is logged and the previous
+// configuration is kept, which is the default one at first.
+func (w *Workspace) loadConfig() {
+	c, err := config.LoadWorkspaceFile(w.TargetPath)
+	if err != nil {
+		logErr(w.log, "[workspace]", w.Name, err)
+		return
+	}
+	w.cfgMu.Lock()
+	w.config = c
+	w.cfgMu.Unlock()
+}
+
+func (w *Workspace) setWatchDefaults(defaults config.Watch) {
+	w.cfgMu.Lock()
+	w.watchDefaults = defaults
+	w.cfgMu.Unlock()
+}
+
+// watchConfig returns the watch settings of the workspace, which are those
+// of the agent unless its tractor.toml sets them.
+func (w *Workspace) watchConfig() config.Watch {
+	w.cfgMu.Lock()
+	defer w.cfgMu.Unlock()
+	return w.config.Watch.Merge(w.watchDefaults)
+}
@@ -0,0 +1,88 @@
+package agent
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/manifold/tractor/pkg/misc/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAgentConfig(t *testing.T) {
+	dirname, err := ioutil.TempDir("", "tractor-pkg-agent-config")
+	require.NoError(t, err)
+	defer os.RemoveAll(dirname)
+	configPath := filepath.Join(dirname, ConfigFile)
+	binPath := filepath.Join(dirname, "elsewhere", "bin")
+	require.NoError(t, ioutil.WriteFile(configPath, []byte(`studio_url = "https://studio.example.com"
+console_buffer = 4096
+
+[watch]
+extensions = [".go"]
+
+[paths]
+workspaces = "spaces"
+bin = "`+binPath+`"
+`), 0644))
+
+	os.Setenv("TRACTOR_STUDIO_URL", "http://localhost:3100")
+	defer os.Unsetenv("TRACTOR_STUDIO_URL")
+	ag := newAgent(t, dirname)
+	defer ag.Shutdown()
+	assert.Equal(t, configPath, ag.ConfigPath)
+	assert.Equal(t, filepath.Join(dirname, "spaces"), ag.WorkspacesPath)
+	assert.Equal(t, binPath, ag.WorkspaceBinPath)
+	assert.Equal(t, filepath.Join(dirname, "sockets"), ag.WorkspaceSocketsPath)
+	c := ag.Config()
+	assert.Equal(t, "http://localhost:3100", c.StudioURL)
+	assert.Equal(t, 4096, c.ConsoleBuffer)
+	assert.Equal(t, []string{".go"}, c.Watch.Extensions)
+	assert.Equal(t, []string{"node_modules"}, c.Watch.Ignore)
+	assert.Equal(t, config.Duration(WatchInterval), c.Watch.Interval)
+
+	dir := filepath.Join(dirname, "src", "cfg")
+	require.NoError(t, os.MkdirAll(dir, 0700))
+	require.NoError(t, os.Symlink(dir, filepath.Join(ag.WorkspacesPath, "cfg")))
+	wsConfig := filepath.Join(dir, config.WorkspaceFile)
+	require.NoError(t, ioutil.WriteFile(wsConfig, []byte("[watch]\nignore = [\"vendor\"]\n"), 0644))
+	ws := ag.Workspace("cfg")
+	require.NotNil(t, ws)
+	assert.Equal(t, config.Watch{
+		Extensions: []string{".go"},
+		Ignore:     []string{"vendor"},
+		Interval:   config.Duration(WatchInterval),
+	}, ws.watchConfig())
+
+	t.Run("reload", func(t *testing.T) {
+		require.NoError(t, ioutil.WriteFile(configPath, []byte("[watch]\ninterval = \"1s\"\n"), 0644))
+		ag.reloadConfig()
+		assert.Equal(t, "http://localhost:3100", ag.Config().StudioURL)
+		assert.Equal(t, config.Watch{
+			Extensions: DefaultConfig().Watch.Extensions,
+			Ignore:     []string{"vendor"},
+			Interval:   config.Duration(time.Second),
+		}, ws.watchConfig())
+
+		require.NoError(t, ioutil.WriteFile(wsConfig, []byte("[watch]\nextensions = [\".go\", \".tmpl\"]\n"), 0644))
+		ws.loadConfig()
+		assert.Equal(t, []string{".go", ".tmpl"}, ws.watchConfig().Extensions)
+		assert.Equal(t, []string{"node_modules"}, ws.watchConfig().Ignore)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		require.NoError(t, ioutil.WriteFile(configPath, []byte("[watch]\nextensions = [\"go\"]\n"), 0644))
+		ag.reloadConfig()
+		assert.Equal(t, config.Duration(time.Second), ag.Config().Watch.Interval)
+
+		require.NoError(t, ioutil.WriteFile(wsConfig, []byte("[daemon]\nproto = \"tcp\"\n"), 0644))
+		ws.loadConfig()
+		assert.Equal(t, []string{".go", ".tmpl"}, ws.watchConfig().Extensions)
+
+		_, err := Open(dirname, nil, false)
+		assert.EqualError(t, err, "config: "+configPath+": watch.extensions must start with a dot: \"go\"")
+	})
+}
@@ -0,0 +1,158 @@
+package agent
+
+import (
+	"bytes"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+// Diagnostic is a problem reported by the compiler or go vet at a position in
+// a workspace source file.
+type Diagnostic struct {
+	File     string // absolute path of the source file
+	Line     int
+	Column   int // zero if the tool gave no column
+	Message  string
+	Severity Severity
+	Source   string // "build" or "vet"
+}
+
+// DiagnosticsObserver is called with the latest diagnostics of a workspace.
+type DiagnosticsObserver func(*Workspace, []Diagnostic)
+
+var diagnosticPattern = regexp.MustCompile(`^(?:vet: )?(.+\.go):(\d+)(?::(\d+))?: (.*)$`)
+
+// parseDiagnostics parses the output of go build or go vet run in dir. Lines
+// indented with a tab continue the message of the previous diagnostic, other
+// lines like package headers are skipped.
+func parseDiagnostics(out []byte, dir string, severity Severity, source string) []Diagnostic {
+	diags := []Diagnostic{}
+	for _, line := range strings.Split(string(out), "\n") {
+		if strings.HasPrefix(line, "\t") && len(diags) > 0 {
+			diags[len(diags)-1].Message += "\n" + strings.TrimSpace(line)
+			continue
+		}
+		m := diagnosticPattern.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		file := m[1]
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(dir, file)
+		}
+		d := Diagnostic{
+			File:     file,
+			Message:  m[4],
+			Severity: severity,
+			Source:   source,
+		}
+		d.Line, _ = strconv.Atoi(m[2])
+		d.Column, _ = strconv.Atoi(m[3])
+		diags = append(diags, d)
+	}
+	return diags
+}
+
+// Diagnostics returns the diagnostics of the last build of the workspace and
+// of go vet if the build succeeded.
+func (w *Workspace) Diagnostics() []Diagnostic {
+	w.infoMu.Lock()
+	defer w.infoMu.Unlock()
+	return append([]Diagnostic{}, w.diagnostics...)
+}
+
+// ObserveDiagnostics calls cb with the diagnostics of the workspace whenever
+// they change. The calls are made in their own goroutine, so a slow observer
+// doesn't hold up builds; it only gets the latest diagnostics when it's
+// done. The returned function stops the calls.
+func (w *Workspace) ObserveDiagnostics(cb DiagnosticsObserver) func() {
+	// holds the latest diagnostics not passed to cb yet
+	updates := make(chan []Diagnostic, 1)
+	stopped := make(chan struct{})
+	w.obsMu.Lock()
+	w.diagObservers++
+	id := w.diagObservers
+	if w.diagWatchers == nil {
+		w.diagWatchers = make(map[int]chan []Diagnostic)
+	}
+	w.diagWatchers[id] = updates
+	w.obsMu.Unlock()
+	go func() {
+		for {
+			select {
+			case diags := <-updates:
+				cb(w, diags)
+			case <-stopped:
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			w.obsMu.Lock()
+			delete(w.diagWatchers, id)
+			w.obsMu.Unlock()
+			close(stopped)
+		})
+	}
+}
+
+// setDiagnostics keeps the diagnostics of the given build and counts them in
+// its result. Diagnostics of an older build are dropped.
+func (w *Workspace) setDiagnostics(build int, diags []Diagnostic) {
+	// held until observers got the diagnostics, so the diagnostics of an older
+	// build checked concurrently can't be sent after these
+	w.obsMu.Lock()
+	defer w.obsMu.Unlock()
+	w.infoMu.Lock()
+	if build != w.builds {
+		w.infoMu.Unlock()
+		return
+	}
+	w.diagnostics = diags
+	if w.lastBuild != nil {
+		w.lastBuild.Errors, w.lastBuild.Warnings = 0, 0
+		for _, d := range diags {
+			if d.Severity == SeverityError {
+				w.lastBuild.Errors++
+			} else {
+				w.lastBuild.Warnings++
+			}
+		}
+	}
+	w.infoMu.Unlock()
+
+	for _, updates := range w.diagWatchers {
+		// replace diagnostics the observer didn't get yet
+		select {
+		case <-updates:
+		default:
+		}
+		updates <- diags
+	}
+}
+
+// vet runs go vet on the workspace after the given build succeeded and keeps
+// its findings as warnings.
+func (w *Workspace) vet(build int) {
+	var out bytes.Buffer
+	cmd := exec.Command("go", "vet", "./...")
+	cmd.Dir = w.TargetPath
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	// vet exits with an error for its findings
+	cmd.Run()
+	w.setDiagnostics(build, parseDiagnostics(out.Bytes(), w.TargetPath, SeverityWarning, "vet"))
+}
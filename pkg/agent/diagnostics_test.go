@@ -0,0 +1,117 @@
+package agent
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDiagnostics(t *testing.T) {
+	out := `# workspace/pkg/obj/a1
+pkg/obj/a1/component.go:12:2: undefined: foo
+./workspace.go:9:14: cannot use x (type int) as type string in argument to f
+/elsewhere/lib.go:3: syntax error
+	have (int)
+	want (string)
+vet: ./main.go:1:1: expected 'package', found 'EOF'
+note: module requires Go 1.14
+`
+	diags := parseDiagnostics([]byte(out), "/ws", SeverityError, "build")
+	assert.Equal(t, []Diagnostic{
+		{File: "/ws/pkg/obj/a1/component.go", Line: 12, Column: 2, Message: "undefined: foo", Severity: SeverityError, Source: "build"},
+		{File: "/ws/workspace.go", Line: 9, Column: 14, Message: "cannot use x (type int) as type string in argument to f", Severity: SeverityError, Source: "build"},
+		{File: "/elsewhere/lib.go", Line: 3, Message: "syntax error\nhave (int)\nwant (string)", Severity: SeverityError, Source: "build"},
+		{File: "/ws/main.go", Line: 1, Column: 1, Message: "expected 'package', found 'EOF'", Severity: SeverityError, Source: "build"},
+	}, diags)
+
+	assert.Empty(t, parseDiagnostics(nil, "/ws", SeverityWarning, "vet"))
+}
+
+func TestWorkspaceDiagnostics(t *testing.T) {
+	var dir string
+	ag, teardown := setupAgent(t, func(ag *Agent) {
+		dir = filepath.Join(ag.Path, "src", "diag")
+		require.NoError(t, os.MkdirAll(dir, 0700))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "go.mod"), []byte("module diag\n"), 0644))
+		require.NoError(t, os.Symlink(dir, filepath.Join(ag.WorkspacesPath, "diag")))
+	})
+	defer teardown()
+	ws := ag.Workspace("diag")
+	require.NotNil(t, ws)
+	updates := make(chan []Diagnostic, 10)
+	stop := ws.ObserveDiagnostics(func(_ *Workspace, diags []Diagnostic) {
+		updates <- diags
+	})
+	defer stop()
+	source := filepath.Join(dir, "workspace.go")
+
+	require.NoError(t, ioutil.WriteFile(source, []byte("package main\n\nfunc main() {\n\tundefined()\n}\n"), 0644))
+	assert.Error(t, ws.Recompile())
+	diags := <-updates
+	require.Len(t, diags, 1)
+	assert.Equal(t, Diagnostic{File: source, Line: 4, Column: 2, Message: "undefined: undefined", Severity: SeverityError, Source: "build"}, diags[0])
+	assert.Equal(t, diags, ws.Diagnostics())
+	assert.Equal(t, 1, ws.Info().LastBuild.Errors)
+
+	require.NoError(t, ioutil.WriteFile(source, []byte("package main\n\nimport \"fmt\"\n\nfunc main() {\n\tfmt.Printf(\"%d\\n\", \"x\")\n}\n"), 0644))
+	assert.NoError(t, ws.Recompile())
+	assert.Empty(t, <-updates)
+	select {
+	case diags = <-updates:
+	case <-time.After(30 * time.Second):
+		t.Fatal("no vet diagnostics")
+	}
+	require.Len(t, diags, 1)
+	assert.Equal(t, source, diags[0].File)
+	assert.Equal(t, 6, diags[0].Line)
+	assert.Equal(t, SeverityWarning, diags[0].Severity)
+	assert.Equal(t, "vet", diags[0].Source)
+	assert.Equal(t, 1, ws.Info().LastBuild.Warnings)
+}
+
+func TestObserveDiagnosticsSlow(t *testing.T) {
+	ws := &Workspace{}
+	called := make(chan struct{}, 10)
+	block := make(chan struct{})
+	updates := make(chan []Diagnostic, 10)
+	stop := ws.ObserveDiagnostics(func(_ *Workspace, diags []Diagnostic) {
+		called <- struct{}{}
+		<-block
+		updates <- diags
+	})
+	defer stop()
+
+	first := []Diagnostic{{Message: "first"}}
+	third := []Diagnostic{{Message: "third"}}
+	ws.setDiagnostics(0, first)
+	<-called
+
+	// the observer is busy, so only the latest diagnostics are kept for it
+	done := make(chan struct{})
+	go func() {
+		ws.setDiagnostics(0, []Diagnostic{{Message: "second"}})
+		ws.setDiagnostics(0, third)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("setDiagnostics waits for the observer")
+	}
+	close(block)
+	assert.Equal(t, first, <-updates)
+	assert.Equal(t, third, <-updates)
+
+	stop()
+	ws.setDiagnostics(0, nil)
+	select {
+	case diags := <-updates:
+		t.Fatalf("unexpected diagnostics after stop: %v", diags)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
@@ -0,0 +1,195 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/manifold/qtalk/golang/mux"
+	qrpc "github.com/manifold/qtalk/golang/rpc"
+	"github.com/manifold/tractor/pkg/misc/subcmd"
+)
+
+const (
+	// DefaultHealthInterval is the time between health checks of a ready
+	// workspace daemon.
+	DefaultHealthInterval = 2 * time.Second
+	// DefaultReadyTimeout is the time a workspace daemon has to become ready
+	// before it is marked degraded.
+	DefaultReadyTimeout = 30 * time.Second
+
+	readyInterval = 100 * time.Millisecond // between checks until ready
+	healthTimeout = 5 * time.Second        // for a single check
+)
+
+// Health is the health of a workspace daemon as reported by its health RPC.
+type Health struct {
+	ImageLoaded bool             `msgpack:"imageLoaded"`
+	Initialized bool             `msgpack:"initialized"` // components were initialized
+	Errors      []ComponentError `msgpack:"errors"`
+
+	Time  int64  `msgpack:"time"`  // Unix time of the check
+	Error string `msgpack:"error"` // why the daemon didn't answer, empty if it did
+}
+
+// Ready returns whether the daemon answered and has loaded and initialized
+// the workspace.
+func (h Health) Ready() bool {
+	return h.Error == "" && h.ImageLoaded && h.Initialized
+}
+
+// problem describes why the daemon is not healthy.
+func (h Health) problem() string {
+	switch {
+	case h.Error != "":
+		return h.Error
+	case !h.Ready():
+		return "not ready"
+	case len(h.Errors) > 0:
+		e := h.Errors[0]
+		return fmt.Sprintf("%s %s: %s", e.Node, e.Component, e.Error)
+	}
+	return "healthy"
+}
+
+// ComponentError is an error of a component reported by the daemon.
+type ComponentError struct {
+	Node      string `msgpack:"node"` // path of the object
+	Component string `msgpack:"component"`
+	Error     string `msgpack:"error"`
+}
+
+// startHealthChecks marks the workspace as starting and checks the health of
+// the daemon run that just started until it ends. Without a HealthInterval
+// the workspace is available right away.
+func (w *Workspace) startHealthChecks() {
+	if w.HealthInterval <= 0 {
+		w.setStatus(StatusAvailable)
+		return
+	}
+	done := make(chan struct{})
+	w.healthMu.Lock()
+	w.healthDone = done
+	w.healthMu.Unlock()
+	w.infoMu.Lock()
+	w.health = nil
+	w.infoMu.Unlock()
+	w.setStatus(StatusStarting)
+	go w.watchHealth(done)
+}
+
+// stopHealthChecks stops the checks of the daemon run that ended.
+func (w *Workspace) stopHealthChecks() {
+	w.healthMu.Lock()
+	if w.healthDone != nil {
+		close(w.healthDone)
+		w.healthDone = nil
+	}
+	w.healthMu.Unlock()
+}
+
+// watchHealth checks the health of the daemon until done is closed. The
+// workspace is Starting until the daemon is ready, then Available, or Degraded
+// while components have errors or the daemon doesn't answer. A daemon that is
+// not ready within the ReadyTimeout is Degraded too. A degraded run of a new
+// binary fails its trial, see checkTrial.
+func (w *Workspace) watchHealth(done chan struct{}) {
+	started := time.Now()
+	interval := readyInterval
+	wasReady := false
+	for {
+		select {
+		case <-done:
+			return
+		case <-time.After(interval):
+		}
+		h := w.probe()
+		w.infoMu.Lock()
+		w.health = &h
+		w.infoMu.Unlock()
+
+		var status WorkspaceStatus
+		switch {
+		case h.Ready() && len(h.Errors) == 0:
+			status = StatusAvailable
+		case h.Ready() || wasReady || time.Since(started) > w.ReadyTimeout:
+			status = StatusDegraded
+		default:
+			continue
+		}
+		wasReady = wasReady || h.Ready()
+		interval = w.HealthInterval
+		if !w.setRunStatus(done, status) {
+			return
+		}
+		w.checkTrial(status, h)
+	}
+}
+
+// setRunStatus sets the status found by the checks of a daemon run unless the
+// run ended.
+func (w *Workspace) setRunStatus(done chan struct{}, s WorkspaceStatus) bool {
+	w.healthMu.Lock()
+	defer w.healthMu.Unlock()
+	select {
+	case <-done:
+		return false
+	default:
+	}
+	w.setStatus(s)
+	return true
+}
+
+// checkHealth calls the health RPC of the daemon.
+func (w *Workspace) checkHealth() Health {
+	type result struct {
+		health Health
+		err    error
+	}
+	// buffered, as a daemon that hangs keeps the call waiting after the timeout
+	ch := make(chan result, 1)
+	go func() {
+		sess, err := mux.DialUnix(w.SocketPath)
+		if err != nil {
+			ch <- result{err: err}
+			return
+		}
+		defer sess.Close()
+		var r result
+		client := &qrpc.Client{Session: sess}
+		if r.err = w.authenticate(client); r.err == nil {
+			_, r.err = client.Call("health", nil, &r.health)
+		}
+		ch <- r
+	}()
+	var r result
+	select {
+	case r = <-ch:
+	case <-time.After(healthTimeout):
+		r.err = errors.New("health check timed out")
+	}
+	r.health.Time = time.Now().Unix()
+	if r.err != nil {
+		r.health = Health{Time: r.health.Time, Error: r.err.Error()}
+	}
+	return r.health
+}
+
+// waitReady waits until the daemon is ready, which is when the workspace is
+// Available or Degraded, or until the daemon is not running anymore.
+func (w *Workspace) waitReady() error {
+	deadline := time.Now().Add(w.ReadyTimeout)
+	for time.Now().Before(deadline) {
+		switch w.Status() {
+		case StatusAvailable, StatusDegraded:
+			return nil
+		case StatusStarting:
+		default:
+			if !subcmd.Running(w.daemon) {
+				return nil
+			}
+		}
+		time.Sleep(readyInterval / 2)
+	}
+	return fmt.Errorf("workspace %s is not ready after %s", w.Name, w.ReadyTimeout)
+}
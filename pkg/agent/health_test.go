@@ -0,0 +1,85 @@
+package agent
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorkspaceHealth(t *testing.T) {
+	ag, teardown := setup(t)
+	defer teardown()
+
+	ws := ag.Workspace("test")
+	require.NotNil(t, ws)
+	ws.HealthInterval = 10 * time.Millisecond
+	ws.ReadyTimeout = time.Minute
+	var mu sync.Mutex
+	reports := []Health{
+		{},
+		{ImageLoaded: true},
+		{ImageLoaded: true, Initialized: true},
+		{ImageLoaded: true, Initialized: true, Errors: []ComponentError{{Node: "/a", Component: "C", Error: "boom"}}},
+		{Error: "connection refused"},
+	}
+	ws.probe = func() Health {
+		mu.Lock()
+		defer mu.Unlock()
+		h := reports[0]
+		if len(reports) > 1 {
+			reports = reports[1:]
+		} else {
+			// healthy from now on
+			reports[0] = Health{ImageLoaded: true, Initialized: true}
+		}
+		return h
+	}
+	status := make(chan WorkspaceStatus, 10)
+	ws.Observe(func(_ *Workspace, newStatus WorkspaceStatus) {
+		status <- newStatus
+	})
+	require.NoError(t, ws.StartDaemon())
+	assert.Equal(t, StatusStarting, <-status)
+
+	// connect waits for the daemon to be ready
+	out, err := ws.Connect()
+	require.NoError(t, err)
+	out.Close()
+	assert.Equal(t, StatusAvailable, <-status)
+	assert.Equal(t, StatusDegraded, <-status)
+	assert.Equal(t, StatusAvailable, <-status)
+
+	info := ws.Info()
+	require.NotNil(t, info.Health)
+	assert.True(t, info.Health.Ready())
+
+	assert.NoError(t, ws.Stop())
+	assert.Equal(t, StatusUnavailable, <-status)
+}
+
+func TestWorkspaceReadyTimeout(t *testing.T) {
+	ag, teardown := setup(t)
+	defer teardown()
+
+	ws := ag.Workspace("test")
+	require.NotNil(t, ws)
+	ws.HealthInterval = 10 * time.Millisecond
+	ws.ReadyTimeout = 200 * time.Millisecond
+	ws.probe = func() Health {
+		return Health{Error: "connection refused"}
+	}
+	status := make(chan WorkspaceStatus, 10)
+	ws.Observe(func(_ *Workspace, newStatus WorkspaceStatus) {
+		status <- newStatus
+	})
+	require.NoError(t, ws.StartDaemon())
+	assert.Equal(t, StatusStarting, <-status)
+	assert.Equal(t, StatusDegraded, <-status)
+	assert.Equal(t, "connection refused", ws.Info().Health.Error)
+
+	assert.NoError(t, ws.Stop())
+	assert.Equal(t, StatusUnavailable, <-status)
+}
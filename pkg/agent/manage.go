@@ -0,0 +1,256 @@
+package agent
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/manifold/tractor/pkg/misc/subcmd"
+)
+
+// CreateWorkspace copies the template directory to path and registers it as
+// the workspace name. An empty template uses the TemplatePath of the agent.
+// Files ending in .go.data lose the .data suffix, which keeps the template out
+// of the tractor build. The module of go.mod is set to module, or name if it is
+// empty, and relative replace paths leaving the template are made absolute.
+func (a *Agent) CreateWorkspace(name, path, template, module string) (*Workspace, error) {
+	if err := a.checkNewName(name); err != nil {
+		return nil, err
+	}
+	if template == "" {
+		template = a.TemplatePath
+	}
+	template = filepath.Clean(template)
+	if module == "" {
+		module = name
+	}
+	if !filepath.IsAbs(path) || !filepath.IsAbs(template) {
+		return nil, fmt.Errorf("workspace and template paths must be absolute")
+	}
+	if fi, err := os.Stat(template); err != nil || !fi.IsDir() {
+		return nil, fmt.Errorf("template is not a directory: %s", template)
+	}
+	if entries, err := ioutil.ReadDir(path); err == nil && len(entries) > 0 {
+		return nil, fmt.Errorf("directory is not empty: %s", path)
+	}
+	if err := copyTemplate(template, path); err != nil {
+		return nil, err
+	}
+	if err := rewriteGoMod(filepath.Join(path, "go.mod"), module, template); err != nil {
+		return nil, err
+	}
+	return a.RegisterWorkspace(name, path)
+}
+
+// RegisterWorkspace symlinks the workspace at path into the WorkspacesPath as
+// name, or the base name of path if name is empty.
+func (a *Agent) RegisterWorkspace(name, path string) (*Workspace, error) {
+	if name == "" {
+		name = filepath.Base(path)
+	}
+	if err := a.checkNewName(name); err != nil {
+		return nil, err
+	}
+	if !filepath.IsAbs(path) {
+		return nil, fmt.Errorf("workspace path must be absolute: %s", path)
+	}
+	if fi, err := os.Stat(filepath.Join(path, "workspace.go")); err != nil || fi.IsDir() {
+		return nil, fmt.Errorf("no workspace.go in %s", path)
+	}
+	if err := os.Symlink(path, filepath.Join(a.WorkspacesPath, name)); err != nil {
+		return nil, err
+	}
+	ws, err := a.openWorkspace(name)
+	if err != nil {
+		return nil, err
+	}
+	a.serve(ws, true)
+	return ws, nil
+}
+
+// UnregisterWorkspace stops the workspace and removes its symlink, socket and
+// binaries. The workspace directory is kept.
+func (a *Agent) UnregisterWorkspace(name string) error {
+	ws, err := a.registered(name)
+	if err != nil {
+		return err
+	}
+	a.close(ws)
+	if err := os.Remove(ws.SymlinkPath); err != nil {
+		return err
+	}
+	os.Remove(ws.BinPath)
+	os.Remove(ws.GoodBinPath)
+	return nil
+}
+
+// RenameWorkspace renames the workspace symlink and binaries. The workspace is
+// served again under the new name, and its daemon is started again unless it
+// was stopped. A workspace that never built is built again.
+func (a *Agent) RenameWorkspace(name, newName string) (*Workspace, error) {
+	ws, err := a.registered(name)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.checkNewName(newName); err != nil {
+		return nil, err
+	}
+	start := ws.daemon == nil || ws.daemon.Status() != subcmd.StatusStopped
+	a.close(ws)
+	if err := os.Rename(ws.SymlinkPath, filepath.Join(a.WorkspacesPath, newName)); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(ws.BinPath, filepath.Join(a.WorkspaceBinPath, newName)); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err := os.Rename(ws.GoodBinPath, filepath.Join(a.WorkspaceBinPath, newName+".good")); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	renamed, err := a.openWorkspace(newName)
+	if err != nil {
+		return nil, err
+	}
+	a.serve(renamed, start)
+	return renamed, nil
+}
+
+// checkNewName returns an error if name is not a valid name for a new
+// workspace or is taken.
+func (a *Agent) checkNewName(name string) error {
+	if name == "" || name != filepath.Base(name) || strings.HasPrefix(name, ".") {
+		return fmt.Errorf("invalid workspace name: %q", name)
+	}
+	if _, err := os.Lstat(filepath.Join(a.WorkspacesPath, name)); err == nil {
+		return fmt.Errorf("workspace already exists: %s", name)
+	}
+	return nil
+}
+
+// registered returns the workspace symlinked as name.
+func (a *Agent) registered(name string) (*Workspace, error) {
+	workspaces, err := a.Workspaces()
+	if err != nil {
+		return nil, err
+	}
+	for _, ws := range workspaces {
+		if ws.Name == name {
+			return ws, nil
+		}
+	}
+	return nil, fmt.Errorf("no workspace found for %q", name)
+}
+
+func (a *Agent) openWorkspace(name string) (*Workspace, error) {
+	ws, err := OpenWorkspace(a, name)
+	if err != nil {
+		return nil, err
+	}
+	a.mu.Lock()
+	a.workspaces[name] = ws
+	a.mu.Unlock()
+	return ws, nil
+}
+
+// serve runs the workspace like the other workspaces if the agent daemon is
+// running. Unless start is set, the workspace is only watched for changes and
+// its daemon is not started.
+func (a *Agent) serve(ws *Workspace, start bool) {
+	if a.Daemon == nil || a.Daemon.Context == nil {
+		return
+	}
+	if start {
+		go ws.Serve(a.Daemon.Context)
+	} else {
+		go ws.Watch(a.Daemon.Context)
+	}
+}
+
+// close stops the workspace and forgets it.
+func (a *Agent) close(ws *Workspace) {
+	ws.Stop()
+	ws.stopWatching()
+	os.Remove(ws.SocketPath)
+	a.mu.Lock()
+	delete(a.workspaces, ws.Name)
+	a.mu.Unlock()
+}
+
+// copyTemplate copies the files of the template directory to dst.
+func copyTemplate(template, dst string) error {
+	return filepath.Walk(template, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(template, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		if fi.IsDir() {
+			return os.MkdirAll(target, fi.Mode().Perm()|0700)
+		}
+		if strings.HasSuffix(target, ".go.data") {
+			target = strings.TrimSuffix(target, ".data")
+		}
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return ioutil.WriteFile(target, b, fi.Mode().Perm())
+	})
+}
+
+// rewriteGoMod sets the module path of a go.mod file copied from the template
+// and makes relative replace paths that point outside of the template
+// absolute. Missing go.mod files are ignored.
+func rewriteGoMod(filename, module, template string) error {
+	b, err := ioutil.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	lines := strings.Split(string(b), "\n")
+	inReplace := false
+	for i, line := range lines {
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) == 0:
+			continue
+		case fields[0] == "module":
+			lines[i] = "module " + module
+		case fields[0] == "replace" && len(fields) > 1 && fields[1] == "(":
+			inReplace = true
+		case inReplace && fields[0] == ")":
+			inReplace = false
+		case fields[0] == "replace" || inReplace:
+			lines[i] = rewriteReplace(line, fields, template)
+		}
+	}
+	return ioutil.WriteFile(filename, []byte(strings.Join(lines, "\n")), 0644)
+}
+
+// rewriteReplace makes the relative path of a replace directive absolute if
+// it points outside of the template.
+func rewriteReplace(line string, fields []string, template string) string {
+	for i, f := range fields {
+		if f != "=>" || i+1 >= len(fields) {
+			continue
+		}
+		path := fields[i+1]
+		if path != "." && path != ".." && !strings.HasPrefix(path, "./") && !strings.HasPrefix(path, "../") {
+			return line
+		}
+		abs := filepath.Join(template, path)
+		if abs == template || strings.HasPrefix(abs, template+string(filepath.Separator)) {
+			return line
+		}
+		fields[i+1] = abs
+		indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+		return indent + strings.Join(fields, " ")
+	}
+	return line
+}
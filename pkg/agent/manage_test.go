@@ -0,0 +1,120 @@
+package agent
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/manifold/tractor/pkg/misc/daemon"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestManageWorkspaces(t *testing.T) {
+	ag, teardown := setupAgent(t, nil)
+	defer teardown()
+	root, err := filepath.Abs(filepath.Join(pkgpath, "..", "..", ".."))
+	require.NoError(t, err)
+	template := filepath.Join(root, "data", "workspace")
+	dir := filepath.Join(ag.Path, "src", "mine")
+
+	ws, err := ag.CreateWorkspace("mine", dir, template, "example.com/mine")
+	require.NoError(t, err)
+	assert.Equal(t, dir, ws.TargetPath)
+	assert.FileExists(t, filepath.Join(dir, "workspace.go"))
+	assert.FileExists(t, filepath.Join(dir, "pkg", "obj", "import.go"))
+	_, err = os.Stat(filepath.Join(dir, "workspace.go.data"))
+	assert.True(t, os.IsNotExist(err))
+
+	gomod, err := ioutil.ReadFile(filepath.Join(dir, "go.mod"))
+	require.NoError(t, err)
+	assert.Contains(t, string(gomod), "module example.com/mine\n")
+	assert.Contains(t, string(gomod), "replace workspace => ./\n")
+	assert.Contains(t, string(gomod), "replace github.com/manifold/tractor => "+root+"\n")
+	assert.Equal(t, []string{"mine"}, agentWsNames(ag))
+
+	_, err = ag.CreateWorkspace("other", dir, template, "")
+	assert.Error(t, err, "directory is not empty")
+	_, err = ag.RegisterWorkspace("mine", dir)
+	assert.Error(t, err, "name is taken")
+	_, err = ag.RegisterWorkspace("../escape", dir)
+	assert.Error(t, err, "invalid name")
+	_, err = ag.RegisterWorkspace("", ag.Path)
+	assert.Error(t, err, "no workspace.go")
+
+	ws, err = ag.RegisterWorkspace("", filepath.Join(pkgpath, "testworkspace"))
+	require.NoError(t, err)
+	assert.Equal(t, "testworkspace", ws.Name)
+	assert.Equal(t, []string{"mine", "testworkspace"}, agentWsNames(ag))
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(ag.WorkspaceBinPath, "mine"), nil, 0700))
+	ws, err = ag.RenameWorkspace("mine", "yours")
+	require.NoError(t, err)
+	assert.Equal(t, "yours", ws.Name)
+	assert.Equal(t, dir, ws.TargetPath)
+	assert.Equal(t, filepath.Join(ag.WorkspaceSocketsPath, "yours.sock"), ws.SocketPath)
+	assert.FileExists(t, filepath.Join(ag.WorkspaceBinPath, "yours"))
+	assert.Equal(t, []string{"testworkspace", "yours"}, agentWsNames(ag))
+	_, err = ag.RenameWorkspace("mine", "again")
+	assert.Error(t, err)
+
+	require.NoError(t, ag.UnregisterWorkspace("yours"))
+	assert.Equal(t, []string{"testworkspace"}, agentWsNames(ag))
+	assert.DirExists(t, dir)
+	_, err = os.Stat(filepath.Join(ag.WorkspaceBinPath, "yours"))
+	assert.True(t, os.IsNotExist(err))
+	assert.Error(t, ag.UnregisterWorkspace("yours"))
+}
+
+func TestRenameWorkspaceServing(t *testing.T) {
+	ag, teardown := setupAgent(t, nil)
+	defer teardown()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ag.Daemon = &daemon.Daemon{Context: ctx}
+	waitStatus := func(ws *Workspace, status WorkspaceStatus) {
+		for i := 0; i < 300 && ws.Status() != status; i++ {
+			time.Sleep(100 * time.Millisecond)
+		}
+		require.Equal(t, status, ws.Status(), ws.Name)
+	}
+
+	t.Run("never built", func(t *testing.T) {
+		dir := filepath.Join(ag.Path, "src", "broken")
+		require.NoError(t, os.MkdirAll(dir, 0700))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "go.mod"), []byte("module broken\n"), 0644))
+		source := filepath.Join(dir, "workspace.go")
+		require.NoError(t, ioutil.WriteFile(source, []byte("package main\n\nfunc main() {\n\tundefined()\n}\n"), 0644))
+		ws, err := ag.RegisterWorkspace("broken", dir)
+		require.NoError(t, err)
+		for i := 0; i < 300 && ws.Info().LastBuild == nil; i++ {
+			time.Sleep(100 * time.Millisecond)
+		}
+		require.NotNil(t, ws.Info().LastBuild)
+
+		// the renamed workspace is watched and builds once it is fixed
+		ws, err = ag.RenameWorkspace("broken", "fixed")
+		require.NoError(t, err)
+		time.Sleep(500 * time.Millisecond)
+		copyFile(t, filepath.Join(pkgpath, "testworkspace", "workspace.go"), source)
+		waitStatus(ws, StatusAvailable)
+		assert.NoError(t, ws.Stop())
+	})
+
+	t.Run("stopped", func(t *testing.T) {
+		ws, err := ag.RegisterWorkspace("stopped", filepath.Join(pkgpath, "testworkspace"))
+		require.NoError(t, err)
+		waitStatus(ws, StatusAvailable)
+		require.NoError(t, ws.Stop())
+
+		// the renamed workspace stays stopped
+		ws, err = ag.RenameWorkspace("stopped", "still")
+		require.NoError(t, err)
+		time.Sleep(time.Second)
+		assert.Nil(t, ws.daemon)
+		assert.Zero(t, ws.Info().PID)
+	})
+}
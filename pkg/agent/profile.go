@@ -0,0 +1,35 @@
+package agent
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/manifold/tractor/pkg/workspace/env"
+)
+
+// profileEnv returns the variables of the profile of the workspace for the
+// environment of the daemon, with the values of its secrets read from the
+// secrets file of the agent. Without a profile there are none.
+func (w *Workspace) profileEnv() ([]string, error) {
+	w.cfgMu.Lock()
+	name := w.config.Profile
+	profile := w.config.Profiles[name]
+	w.cfgMu.Unlock()
+	if name == "" {
+		return nil, nil
+	}
+
+	vars := make([]string, 0, len(profile.Env)+len(profile.Secrets))
+	for k, v := range profile.Env {
+		vars = append(vars, k+"="+v)
+	}
+	for k, secret := range profile.Secrets {
+		v, err := w.secrets.Get(secret)
+		if err != nil {
+			return nil, fmt.Errorf("profile %s: %s: %v", name, k, err)
+		}
+		vars = append(vars, k+"="+v)
+	}
+	sort.Strings(vars)
+	return append([]string{env.ProfileEnv + "=" + name}, vars...), nil
+}
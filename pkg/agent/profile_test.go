@@ -0,0 +1,91 @@
+package agent
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/manifold/tractor/pkg/misc/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorkspaceProfileEnv(t *testing.T) {
+	dirname, err := ioutil.TempDir("", "tractor-pkg-agent-profile")
+	require.NoError(t, err)
+	defer os.RemoveAll(dirname)
+	ag := newAgent(t, dirname)
+	defer ag.Shutdown()
+
+	dir := filepath.Join(dirname, "src", "prof")
+	require.NoError(t, os.MkdirAll(dir, 0700))
+	require.NoError(t, os.Symlink(dir, filepath.Join(ag.WorkspacesPath, "prof")))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, config.WorkspaceFile), []byte(`profile = "prod"
+
+[profiles.dev.env]
+API_URL = "http://localhost:8080"
+
+[profiles.prod.env]
+API_URL = "https://api.example.com"
+LOG_LEVEL = "warn"
+
+[profiles.prod.secrets]
+API_TOKEN = "prod-token"
+`), 0644))
+	ws := ag.Workspace("prof")
+	require.NotNil(t, ws)
+
+	_, err = ws.profileEnv()
+	assert.EqualError(t, err, `profile prod: API_TOKEN: secret not found: "prod-token"`)
+
+	require.NoError(t, ag.Secrets.Set("prod-token", "hunter2"))
+	vars, err := ws.profileEnv()
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"TRACTOR_PROFILE=prod",
+		"API_TOKEN=hunter2",
+		"API_URL=https://api.example.com",
+		"LOG_LEVEL=warn",
+	}, vars)
+
+	ws.cfgMu.Lock()
+	ws.config.Profile = "dev"
+	ws.cfgMu.Unlock()
+	vars, err = ws.profileEnv()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"TRACTOR_PROFILE=dev", "API_URL=http://localhost:8080"}, vars)
+
+	ws.cfgMu.Lock()
+	ws.config.Profile = ""
+	ws.cfgMu.Unlock()
+	vars, err = ws.profileEnv()
+	require.NoError(t, err)
+	assert.Empty(t, vars)
+
+	// the environment of the agent doesn't select the profile of workspaces
+	os.Setenv("TRACTOR_PROFILE", "dev")
+	defer os.Unsetenv("TRACTOR_PROFILE")
+	ws.loadConfig()
+	vars, err = ws.profileEnv()
+	require.NoError(t, err)
+	assert.Equal(t, "TRACTOR_PROFILE=prod", vars[0])
+}
+
+func TestWorkspaceConfigInvalid(t *testing.T) {
+	dirname, err := ioutil.TempDir("", "tractor-pkg-agent-profile")
+	require.NoError(t, err)
+	defer os.RemoveAll(dirname)
+	ag := newAgent(t, dirname)
+	defer ag.Shutdown()
+
+	dir := filepath.Join(dirname, "src", "bad")
+	require.NoError(t, os.MkdirAll(dir, 0700))
+	require.NoError(t, os.Symlink(dir, filepath.Join(ag.WorkspacesPath, "bad")))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, config.WorkspaceFile), []byte(`profile = "nope"`), 0644))
+	ws := ag.Workspace("bad")
+	require.NotNil(t, ws)
+	ws.cfgMu.Lock()
+	defer ws.cfgMu.Unlock()
+	assert.Equal(t, config.DefaultWorkspace(), ws.config)
+}
@@ -0,0 +1,155 @@
+package agent
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/manifold/qtalk/golang/mux"
+	qrpc "github.com/manifold/qtalk/golang/rpc"
+	"github.com/manifold/tractor/pkg/misc/auth"
+)
+
+const (
+	// ReloadPath is the directory in a workspace delegate plugins are built in.
+	ReloadPath = ".tractor/reload"
+
+	workspaceModule = "workspace"
+)
+
+// delegateIDs returns the IDs of the delegate packages (pkg/obj/{id}) the
+// changed files belong to. It returns false if any file is outside of them.
+func (w *Workspace) delegateIDs(paths []string) ([]string, bool) {
+	objPath := filepath.Join(w.TargetPath, "pkg", "obj")
+	seen := make(map[string]bool)
+	var ids []string
+	for _, path := range paths {
+		rel, err := filepath.Rel(objPath, path)
+		if err != nil {
+			return nil, false
+		}
+		parts := strings.Split(filepath.ToSlash(rel), "/")
+		if len(parts) != 2 || parts[0] == ".." {
+			return nil, false
+		}
+		if !seen[parts[0]] {
+			seen[parts[0]] = true
+			ids = append(ids, parts[0])
+		}
+	}
+	return ids, len(ids) > 0
+}
+
+// HotReload builds the delegate packages as a Go plugin and has the running
+// workspace daemon load it, which swaps the delegate components in place
+// instead of restarting the daemon.
+func (w *Workspace) HotReload(ids []string) error {
+	dir, err := w.writePluginSource(ids)
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	plugin := dir + ".so"
+	cmd := exec.Command("go", "build", "-buildmode=plugin", "-o", plugin, ".")
+	cmd.Dir = dir
+	if w.consolePipe != nil {
+		cmd.Stdout = w.consolePipe
+		cmd.Stderr = w.consolePipe
+	} else {
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+	}
+	if err := cmd.Run(); err != nil {
+		return err
+	}
+	defer os.Remove(plugin)
+
+	sess, err := mux.DialUnix(w.SocketPath)
+	if err != nil {
+		return err
+	}
+	defer sess.Close()
+	client := &qrpc.Client{Session: sess}
+	if err := w.authenticate(client); err != nil {
+		return err
+	}
+	var reloaded []string
+	if _, err := client.Call("loadPlugin", map[string]string{"Path": plugin}, &reloaded); err != nil {
+		return err
+	}
+	if len(reloaded) < len(ids) {
+		return fmt.Errorf("delegates not reloaded: only %s", strings.Join(reloaded, ", "))
+	}
+	return nil
+}
+
+// authenticate authenticates the agent to the workspace daemon as an editor.
+func (w *Workspace) authenticate(client *qrpc.Client) error {
+	key, err := auth.LoadKey(w.authKeyPath)
+	if err != nil {
+		return err
+	}
+	token, err := auth.Issue(key, auth.Claims{
+		Role:      auth.Editor,
+		Workspace: w.Name,
+		Expires:   time.Now().Add(time.Minute).Unix(),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = client.Call("authenticate", token, nil)
+	return err
+}
+
+// writePluginSource copies the delegate packages to a new directory under
+// ReloadPath and adds a main package importing them. Every build gets new
+// package paths since a process can't load two versions of a package.
+func (w *Workspace) writePluginSource(ids []string) (string, error) {
+	name := strconv.FormatInt(time.Now().UnixNano(), 36)
+	dir := filepath.Join(w.TargetPath, ReloadPath, name)
+	var imports []string
+	for _, id := range ids {
+		src := filepath.Join(w.TargetPath, "pkg", "obj", id)
+		if err := copyPackage(src, filepath.Join(dir, id)); err != nil {
+			os.RemoveAll(dir)
+			return "", err
+		}
+		imports = append(imports, fmt.Sprintf("\t_ %q", strings.Join([]string{workspaceModule, ReloadPath, name, id}, "/")))
+	}
+	main := fmt.Sprintf("package main\n\nimport (\n%s\n)\n", strings.Join(imports, "\n"))
+	if err := ioutil.WriteFile(filepath.Join(dir, "main.go"), []byte(main), 0644); err != nil {
+		os.RemoveAll(dir)
+		return "", err
+	}
+	return dir, nil
+}
+
+func copyPackage(src, dst string) error {
+	entries, err := ioutil.ReadDir(src)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dst, 0755); err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || filepath.Ext(name) != ".go" || strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		b, err := ioutil.ReadFile(filepath.Join(src, name))
+		if err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(filepath.Join(dst, name), b, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,31 @@
+package agent
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDelegateIDs(t *testing.T) {
+	w := &Workspace{TargetPath: "/workspace"}
+
+	ids, ok := w.delegateIDs([]string{
+		"/workspace/pkg/obj/a1/component.go",
+		"/workspace/pkg/obj/b2/component.go",
+		"/workspace/pkg/obj/a1/handler.go",
+	})
+	assert.True(t, ok)
+	assert.Equal(t, []string{"a1", "b2"}, ids)
+
+	for _, path := range []string{
+		"/workspace/main.go",
+		"/workspace/pkg/obj/import.go",
+		"/workspace/pkg/obj/a1/sub/file.go",
+		"/elsewhere/pkg/obj/a1/component.go",
+	} {
+		_, ok := w.delegateIDs([]string{"/workspace/pkg/obj/a1/component.go", path})
+		assert.False(t, ok, path)
+	}
+	_, ok = w.delegateIDs(nil)
+	assert.False(t, ok)
+}
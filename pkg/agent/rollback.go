@@ -0,0 +1,175 @@
+package agent
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// DefaultRollbackGrace is the time a newly built workspace binary has to keep
+// running before it becomes the last good binary.
+const DefaultRollbackGrace = 5 * time.Second
+
+// Rollback describes the last time a workspace went back to its last good
+// binary because a new one failed.
+type Rollback struct {
+	Time  int64  // Unix time of the rollback
+	Error string // how the new binary failed
+}
+
+// startTrial puts the run of the daemon that just started on trial if it
+// runs a newly built binary. The binary becomes the last good binary if the
+// run lasts for the RollbackGrace and, with health checks, is ready and
+// healthy by then.
+func (w *Workspace) startTrial() {
+	w.binMu.Lock()
+	defer w.binMu.Unlock()
+	if !w.untried {
+		return
+	}
+	w.untried = false
+	w.trials++
+	w.trial = w.trials
+	w.graceOver = false
+	trial := w.trial
+	time.AfterFunc(w.RollbackGrace, func() {
+		w.endGrace(trial)
+	})
+}
+
+// newBinary puts the freshly built binary on trial for its next run. A run
+// of the previous binary that is still on trial is not anymore, as the
+// previous binary won't be started again.
+func (w *Workspace) newBinary() {
+	w.binMu.Lock()
+	w.untried = true
+	w.trial = 0
+	w.binMu.Unlock()
+}
+
+// endGrace promotes the binary at the end of the grace period of its trial.
+// With health checks, a binary that is not healthy yet stays on trial until
+// the checks find it healthy or degraded.
+func (w *Workspace) endGrace(trial int) {
+	w.binMu.Lock()
+	defer w.binMu.Unlock()
+	if w.trial != trial {
+		return
+	}
+	if w.HealthInterval > 0 && w.Status() != StatusAvailable {
+		w.graceOver = true
+		return
+	}
+	w.promote()
+}
+
+// checkTrial judges the binary on trial by the status its health checks
+// found. A degraded binary fails its trial, which rolls back to the last good
+// binary and restarts the daemon. A healthy binary is promoted if its grace
+// period is over.
+func (w *Workspace) checkTrial(status WorkspaceStatus, h Health) {
+	w.binMu.Lock()
+	if w.trial == 0 {
+		w.binMu.Unlock()
+		return
+	}
+	switch status {
+	case StatusAvailable:
+		if w.graceOver {
+			w.promote()
+		}
+		w.binMu.Unlock()
+		return
+	case StatusDegraded:
+	default:
+		w.binMu.Unlock()
+		return
+	}
+	w.trial = 0
+	rolledBack := w.rollBack(fmt.Sprintf("new binary failed health checks within %s: %s", w.RollbackGrace, h.problem()))
+	w.binMu.Unlock()
+	if !rolledBack {
+		return
+	}
+	if err := w.daemon.Restart(); err != nil {
+		logErr(w.log, "[workspace]", w.Name, "unable to restart after rolling back:", err)
+	}
+}
+
+// promote keeps the binary on trial as the last good binary. binMu must be
+// held.
+func (w *Workspace) promote() {
+	w.trial = 0
+	if err := copyBinary(w.BinPath, w.GoodBinPath); err != nil {
+		logErr(w.log, "[workspace]", w.Name, "unable to keep the good binary:", err)
+		return
+	}
+	w.infoMu.Lock()
+	w.rollback = nil
+	w.infoMu.Unlock()
+}
+
+// endTrial ends the trial of the running binary after the daemon exited. A
+// binary that failed during its trial is replaced by the last good binary, so
+// the restart of the daemon runs that instead. A binary whose trial was cut
+// short by a stop gets a new trial when it starts again.
+func (w *Workspace) endTrial(stopped bool, err error) {
+	w.binMu.Lock()
+	defer w.binMu.Unlock()
+	if w.trial == 0 {
+		return
+	}
+	w.trial = 0
+	if stopped || err == nil {
+		w.untried = stopped
+		return
+	}
+	w.rollBack(fmt.Sprintf("new binary failed within %s: %v", w.RollbackGrace, err))
+}
+
+// rollBack replaces the failed binary on trial by the last good binary and
+// returns whether it did. binMu must be held.
+func (w *Workspace) rollBack(reason string) bool {
+	if _, err := os.Stat(w.GoodBinPath); err != nil {
+		info(w.log, "[workspace]", w.Name, "new binary failed, no good binary to roll back to")
+		return false
+	}
+	if err := copyBinary(w.GoodBinPath, w.BinPath); err != nil {
+		logErr(w.log, "[workspace]", w.Name, "unable to roll back:", err)
+		return false
+	}
+	info(w.log, "[workspace]", w.Name, "rolled back to the last good binary:", reason)
+	w.infoMu.Lock()
+	w.rollback = &Rollback{
+		Time:  time.Now().Unix(),
+		Error: reason,
+	}
+	w.infoMu.Unlock()
+	return true
+}
+
+// copyBinary copies the executable src to dst. It writes a temporary file
+// and renames it, as dst may be running.
+func copyBinary(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	tmp := dst + ".tmp"
+	out, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0755)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, dst)
+}
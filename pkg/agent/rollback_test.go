@@ -0,0 +1,134 @@
+package agent
+
+import (
+	"bufio"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/manifold/tractor/pkg/misc/subcmd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorkspaceRollback(t *testing.T) {
+	var dir string
+	ag, teardown := setupAgent(t, func(ag *Agent) {
+		dir = filepath.Join(ag.Path, "src", "roll")
+		require.NoError(t, os.MkdirAll(dir, 0700))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "go.mod"), []byte("module roll\n"), 0644))
+		copyFile(t, filepath.Join(pkgpath, "testworkspace", "workspace.go"), filepath.Join(dir, "workspace.go"))
+		require.NoError(t, os.Symlink(dir, filepath.Join(ag.WorkspacesPath, "roll")))
+	})
+	defer teardown()
+
+	ws := ag.Workspace("roll")
+	require.NotNil(t, ws)
+	ws.RollbackGrace = 200 * time.Millisecond
+	ws.RestartPolicy = subcmd.RestartPolicy{Mode: subcmd.RestartOnFailure, MinBackoff: 10 * time.Millisecond}
+	status := make(chan WorkspaceStatus, 10)
+	ws.Observe(func(_ *Workspace, newStatus WorkspaceStatus) {
+		status <- newStatus
+	})
+	require.NoError(t, ws.StartDaemon())
+	assert.Equal(t, StatusAvailable, <-status)
+
+	for i := 0; i < 100; i++ {
+		if _, err := os.Stat(ws.GoodBinPath); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	require.FileExists(t, ws.GoodBinPath)
+	assert.Nil(t, ws.Info().Rollback)
+
+	// the new binary builds but fails on startup
+	copyFile(t, filepath.Join(pkgpath, "errworkspace", "workspace.go"), filepath.Join(dir, "workspace.go"))
+	ws.reload()
+	assert.Equal(t, StatusPartially, <-status)
+	assert.Equal(t, StatusAvailable, <-status)
+	assert.Equal(t, StatusUnavailable, <-status)
+	assert.Equal(t, StatusAvailable, <-status)
+
+	info := ws.Info()
+	require.NotNil(t, info.Rollback)
+	assert.Contains(t, info.Rollback.Error, "exit status 1")
+	assert.Empty(t, info.LastBuild.Error)
+
+	out, err := ws.Connect()
+	require.NoError(t, err)
+	scanner := bufio.NewScanner(out)
+	require.True(t, scanner.Scan())
+	assert.True(t, strings.HasPrefix(scanner.Text(), "pid "))
+
+	assert.NoError(t, ws.Stop())
+	assert.Equal(t, StatusUnavailable, <-status)
+}
+
+func TestWorkspaceRollbackUnhealthy(t *testing.T) {
+	var dir string
+	ag, teardown := setupAgent(t, func(ag *Agent) {
+		dir = filepath.Join(ag.Path, "src", "roll")
+		require.NoError(t, os.MkdirAll(dir, 0700))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "go.mod"), []byte("module roll\n"), 0644))
+		copyFile(t, filepath.Join(pkgpath, "testworkspace", "workspace.go"), filepath.Join(dir, "workspace.go"))
+		require.NoError(t, os.Symlink(dir, filepath.Join(ag.WorkspacesPath, "roll")))
+	})
+	defer teardown()
+
+	ws := ag.Workspace("roll")
+	require.NotNil(t, ws)
+	ws.RollbackGrace = 500 * time.Millisecond
+	ws.HealthInterval = 10 * time.Millisecond
+	ws.ReadyTimeout = time.Minute
+	ws.probe = func() Health {
+		h := Health{ImageLoaded: true, Initialized: true}
+		if ws.Info().Restarts == 1 {
+			// the run of the new binary
+			h.Errors = []ComponentError{{Node: "/a", Component: "C", Error: "boom"}}
+		}
+		return h
+	}
+	status := make(chan WorkspaceStatus, 20)
+	ws.Observe(func(_ *Workspace, newStatus WorkspaceStatus) {
+		status <- newStatus
+	})
+	require.NoError(t, ws.StartDaemon())
+	assert.Equal(t, StatusStarting, <-status)
+	assert.Equal(t, StatusAvailable, <-status)
+
+	for i := 0; i < 100; i++ {
+		if _, err := os.Stat(ws.GoodBinPath); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	require.FileExists(t, ws.GoodBinPath)
+
+	// the new binary starts but its components fail
+	ws.reload()
+	assert.Equal(t, StatusPartially, <-status)
+	assert.Equal(t, StatusStarting, <-status)
+	assert.Equal(t, StatusDegraded, <-status)
+	assert.Equal(t, StatusPartially, <-status)
+	assert.Equal(t, StatusStarting, <-status)
+	assert.Equal(t, StatusAvailable, <-status)
+
+	info := ws.Info()
+	require.NotNil(t, info.Rollback)
+	assert.Contains(t, info.Rollback.Error, "failed health checks")
+	assert.Contains(t, info.Rollback.Error, "boom")
+	assert.Equal(t, 2, info.Restarts)
+
+	assert.NoError(t, ws.Stop())
+	assert.Equal(t, StatusUnavailable, <-status)
+}
+
+func copyFile(t *testing.T, src, dst string) {
+	b, err := ioutil.ReadFile(src)
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(dst, b, 0644))
+}
@@ -0,0 +1,177 @@
+package rpc
+
+import (
+	"fmt"
+	"time"
+
+	qrpc "github.com/manifold/qtalk/golang/rpc"
+	"github.com/manifold/tractor/pkg/misc/auth"
+	wsrpc "github.com/manifold/tractor/pkg/workspace/rpc"
+)
+
+// errorf returns an error with the same codes as the errors of the workspace
+// RPC handlers.
+func errorf(code wsrpc.ErrorCode, format string, args ...interface{}) error {
+	return &wsrpc.Error{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+type TokenParams struct {
+	Role      auth.Role
+	Workspace string // empty for every workspace
+	TTL       int    // seconds until the token expires, zero for no expiry
+}
+
+// handle registers a handler that is only served to callers authenticated
+// with a role allowing the required role.
+func (s *Service) handle(name string, required auth.Role, h func(qrpc.Responder, *qrpc.Call)) {
+	s.api.HandleFunc(name, func(r qrpc.Responder, c *qrpc.Call) {
+		claims, err := s.claims(c.Caller)
+		if err != nil {
+			r.Return(err)
+			return
+		}
+		if !claims.Role.Allows(required) {
+			r.Return(errorf(wsrpc.PermissionDenied, "%s requires the %s role", name, required))
+			return
+		}
+		h(r, c)
+	})
+}
+
+// claims returns the claims the caller authenticated with.
+func (s *Service) claims(caller qrpc.Caller) (auth.Claims, error) {
+	s.mu.Lock()
+	claims, ok := s.callers[caller]
+	s.mu.Unlock()
+	if !ok {
+		return claims, errorf(wsrpc.Unauthenticated, "call authenticate first")
+	}
+	if claims.Expires != 0 && time.Now().Unix() >= claims.Expires {
+		return claims, errorf(wsrpc.Unauthenticated, "%v", auth.ErrExpiredToken)
+	}
+	return claims, nil
+}
+
+// Authenticate verifies the token of the caller, which has to be the first
+// call of a client. It replies with the role granted by the token.
+func (s *Service) Authenticate() func(qrpc.Responder, *qrpc.Call) {
+	return func(r qrpc.Responder, c *qrpc.Call) {
+		var token string
+		if err := c.Decode(&token); err != nil {
+			r.Return(err)
+			return
+		}
+		role, err := s.authenticate(c.Caller, token)
+		if err != nil {
+			r.Return(err)
+			return
+		}
+		r.Return(role)
+	}
+}
+
+// authenticate keeps the claims of the token for the caller until its session
+// ends.
+func (s *Service) authenticate(caller qrpc.Caller, token string) (auth.Role, error) {
+	claims, err := s.Agent.VerifyToken(token)
+	if err != nil {
+		return "", errorf(wsrpc.Unauthenticated, "%v", err)
+	}
+	s.mu.Lock()
+	_, known := s.callers[caller]
+	s.callers[caller] = claims
+	s.mu.Unlock()
+	if closed := wsrpc.CallerClosed(caller); !known && closed != nil {
+		go func() {
+			<-closed
+			s.mu.Lock()
+			delete(s.callers, caller)
+			s.mu.Unlock()
+		}()
+	}
+	return claims.Role, nil
+}
+
+// ExchangeToken replaces an exchange token, like the one the systray puts in
+// the Studio URL, by a token for the same role and workspace that doesn't
+// expire. It doesn't require authentication, but each exchange token is only
+// exchanged once, so one leaked through the URL is useless after Studio used
+// it.
+func (s *Service) ExchangeToken() func(qrpc.Responder, *qrpc.Call) {
+	return func(r qrpc.Responder, c *qrpc.Call) {
+		var token string
+		if err := c.Decode(&token); err != nil {
+			r.Return(err)
+			return
+		}
+		exchanged, err := s.exchange(token)
+		if err != nil {
+			r.Return(err)
+			return
+		}
+		r.Return(exchanged)
+	}
+}
+
+func (s *Service) exchange(token string) (string, error) {
+	claims, err := s.Agent.VerifyExchangeToken(token)
+	if err != nil {
+		return "", errorf(wsrpc.Unauthenticated, "%v", err)
+	}
+	now := time.Now().Unix()
+	s.mu.Lock()
+	for t, expires := range s.exchanged {
+		if now >= expires {
+			delete(s.exchanged, t)
+		}
+	}
+	_, used := s.exchanged[token]
+	if !used {
+		s.exchanged[token] = claims.Expires
+	}
+	s.mu.Unlock()
+	if used {
+		return "", errorf(wsrpc.Unauthenticated, "token was already exchanged")
+	}
+	return s.Agent.IssueToken(auth.Claims{Role: claims.Role, Workspace: claims.Workspace})
+}
+
+// IssueToken issues a token for other clients. The token is limited to the
+// workspace and expiry of the caller's token.
+func (s *Service) IssueToken() func(qrpc.Responder, *qrpc.Call) {
+	return func(r qrpc.Responder, c *qrpc.Call) {
+		var params TokenParams
+		if err := c.Decode(&params); err != nil {
+			r.Return(err)
+			return
+		}
+		caller, err := s.claims(c.Caller)
+		if err != nil {
+			r.Return(err)
+			return
+		}
+		claims := auth.Claims{
+			Role:      params.Role,
+			Workspace: params.Workspace,
+		}
+		if params.TTL > 0 {
+			claims.Expires = time.Now().Add(time.Duration(params.TTL) * time.Second).Unix()
+		}
+		if caller.Workspace != "" {
+			if claims.Workspace != "" && claims.Workspace != caller.Workspace {
+				r.Return(errorf(wsrpc.PermissionDenied, "token is not valid for workspace %q", claims.Workspace))
+				return
+			}
+			claims.Workspace = caller.Workspace
+		}
+		if caller.Expires != 0 && (claims.Expires == 0 || claims.Expires > caller.Expires) {
+			claims.Expires = caller.Expires
+		}
+		token, err := s.Agent.IssueToken(claims)
+		if err != nil {
+			r.Return(err)
+			return
+		}
+		r.Return(token)
+	}
+}
@@ -0,0 +1,94 @@
+package rpc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/manifold/qtalk/golang/mux"
+	qrpc "github.com/manifold/qtalk/golang/rpc"
+	"github.com/manifold/tractor/pkg/misc/auth"
+	wsrpc "github.com/manifold/tractor/pkg/workspace/rpc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testSession is a session that ends when it is closed.
+type testSession struct {
+	mux.Session
+	closed chan struct{}
+}
+
+func (s *testSession) Close() error {
+	close(s.closed)
+	return nil
+}
+
+func (s *testSession) Wait() error {
+	<-s.closed
+	return nil
+}
+
+func TestAuthenticate(t *testing.T) {
+	s, teardown := setupDashboard(t)
+	defer teardown()
+	s.callers = make(map[qrpc.Caller]auth.Claims)
+	sess := &testSession{closed: make(chan struct{})}
+	caller := &qrpc.Client{Session: sess}
+
+	_, err := s.claims(caller)
+	require.Error(t, err)
+	assert.Equal(t, wsrpc.Unauthenticated, err.(*wsrpc.Error).Code)
+	_, err = s.authenticate(caller, "invalid")
+	require.Error(t, err)
+	assert.Equal(t, wsrpc.Unauthenticated, err.(*wsrpc.Error).Code)
+
+	token := issueToken(t, s, auth.Claims{Role: auth.Viewer, Workspace: "other"})
+	role, err := s.authenticate(caller, token)
+	require.NoError(t, err)
+	assert.Equal(t, auth.Viewer, role)
+	err = s.allowWorkspaces(&qrpc.Call{Caller: caller}, "test")
+	require.Error(t, err)
+	assert.Equal(t, wsrpc.PermissionDenied, err.(*wsrpc.Error).Code)
+
+	// the claims are dropped when the session ends
+	sess.Close()
+	for i := 0; i < 100; i++ {
+		if _, err = s.claims(caller); err != nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Error(t, err)
+	s.mu.Lock()
+	assert.Empty(t, s.callers)
+	s.mu.Unlock()
+}
+
+func TestExchangeToken(t *testing.T) {
+	s, teardown := setupDashboard(t)
+	defer teardown()
+	s.exchanged = make(map[string]int64)
+
+	token := issueToken(t, s, auth.Claims{
+		Role:      auth.Editor,
+		Workspace: "test",
+		Expires:   time.Now().Add(time.Minute).Unix(),
+		Exchange:  true,
+	})
+	_, err := s.Agent.VerifyToken(token)
+	assert.Equal(t, auth.ErrExchangeToken, err)
+
+	exchanged, err := s.exchange(token)
+	require.NoError(t, err)
+	claims, err := s.Agent.VerifyToken(exchanged)
+	require.NoError(t, err)
+	assert.Equal(t, auth.Claims{Role: auth.Editor, Workspace: "test"}, claims)
+
+	// each token is only exchanged once
+	_, err = s.exchange(token)
+	require.Error(t, err)
+	assert.Equal(t, wsrpc.Unauthenticated, err.(*wsrpc.Error).Code)
+	_, err = s.exchange(exchanged)
+	require.Error(t, err)
+	assert.Equal(t, wsrpc.Unauthenticated, err.(*wsrpc.Error).Code)
+}
@@ -0,0 +1,214 @@
+package rpc
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/manifold/tractor/pkg/agent"
+	"github.com/manifold/tractor/pkg/misc/auth"
+)
+
+// dashboardAPI is the path prefix of the JSON resources of the dashboard.
+const dashboardAPI = "/api/workspaces"
+
+// dashboardError is an error of a dashboard request with its status code.
+type dashboardError struct {
+	status  int
+	message string
+}
+
+func (e *dashboardError) Error() string {
+	return e.message
+}
+
+func dashboardErrorf(status int, format string, args ...interface{}) error {
+	return &dashboardError{status: status, message: fmt.Sprintf(format, args...)}
+}
+
+// workspaceAction is an action of the dashboard on a workspace, like the
+// start, restart and stop handlers of the service.
+type workspaceAction struct {
+	do   func(*agent.Workspace) error
+	done string // past tense for the reply
+}
+
+var workspaceActions = map[string]workspaceAction{
+	"start":   {(*agent.Workspace).Start, "started"},
+	"restart": {(*agent.Workspace).Restart, "restarted"},
+	"stop":    {(*agent.Workspace).Stop, "stopped"},
+}
+
+// Dashboard returns a handler serving a web dashboard of the workspaces for
+// agents running without the systray. The page at / uses these resources,
+// which take a bearer token issued by the agent like the authenticate call:
+//
+//	GET  /api/workspaces                     list the workspace infos
+//	GET  /api/workspaces/{name}              get the info of a workspace
+//	GET  /api/workspaces/{name}/diagnostics  get the build diagnostics
+//	GET  /api/workspaces/{name}/console      stream the console output
+//	POST /api/workspaces/{name}/{action}     start, restart or stop
+func (s *Service) Dashboard() http.Handler {
+	return http.HandlerFunc(s.serveDashboard)
+}
+
+func (s *Service) serveDashboard(w http.ResponseWriter, req *http.Request) {
+	if req.URL.Path == "/" {
+		if req.Method != "GET" {
+			writeDashboardError(w, dashboardErrorf(http.StatusMethodNotAllowed, "method not allowed: %s", req.Method))
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		io.WriteString(w, dashboardPage)
+		return
+	}
+	if req.URL.Path != dashboardAPI && !strings.HasPrefix(req.URL.Path, dashboardAPI+"/") {
+		writeDashboardError(w, dashboardErrorf(http.StatusNotFound, "unknown resource: %s", req.URL.Path))
+		return
+	}
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(req.URL.Path, dashboardAPI), "/"), "/")
+	if len(parts) > 2 {
+		writeDashboardError(w, dashboardErrorf(http.StatusNotFound, "unknown resource: %s", req.URL.Path))
+		return
+	}
+
+	required := auth.Viewer
+	if req.Method == "POST" {
+		required = auth.Editor
+	}
+	claims, err := s.authorizeDashboard(req, required)
+	if err != nil {
+		writeDashboardError(w, err)
+		return
+	}
+
+	if parts[0] == "" {
+		if req.Method != "GET" {
+			writeDashboardError(w, dashboardErrorf(http.StatusMethodNotAllowed, "method not allowed: %s", req.Method))
+			return
+		}
+		s.listWorkspaces(w, claims)
+		return
+	}
+
+	ws := s.Agent.Workspace(parts[0])
+	if ws == nil {
+		writeDashboardError(w, dashboardErrorf(http.StatusNotFound, "no workspace found for %q", parts[0]))
+		return
+	}
+	if !claims.AllowsWorkspace(ws.Name) {
+		writeDashboardError(w, dashboardErrorf(http.StatusForbidden, "token is not valid for workspace %q", ws.Name))
+		return
+	}
+
+	var resource string
+	if len(parts) == 2 {
+		resource = parts[1]
+	}
+	action, isAction := workspaceActions[resource]
+	switch {
+	case req.Method == "POST" && isAction:
+		if err := action.do(ws); err != nil {
+			writeDashboardError(w, err)
+			return
+		}
+		writeDashboardJSON(w, http.StatusOK, fmt.Sprintf("workspace %q %s", ws.Name, action.done))
+	case isAction || req.Method != "GET":
+		writeDashboardError(w, dashboardErrorf(http.StatusMethodNotAllowed, "method not allowed: %s", req.Method))
+	case resource == "":
+		writeDashboardJSON(w, http.StatusOK, ws.Info())
+	case resource == "diagnostics":
+		writeDashboardJSON(w, http.StatusOK, ws.Diagnostics())
+	case resource == "console":
+		streamConsole(w, req, ws)
+	default:
+		writeDashboardError(w, dashboardErrorf(http.StatusNotFound, "unknown resource: %s", req.URL.Path))
+	}
+}
+
+// authorizeDashboard verifies the bearer token of a request and returns its
+// claims if they allow the required role.
+func (s *Service) authorizeDashboard(req *http.Request, required auth.Role) (auth.Claims, error) {
+	token := strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")
+	if token == "" {
+		return auth.Claims{}, dashboardErrorf(http.StatusUnauthorized, "bearer token is required")
+	}
+	claims, err := s.Agent.VerifyToken(token)
+	if err != nil {
+		return claims, dashboardErrorf(http.StatusUnauthorized, "%v", err)
+	}
+	if !claims.Role.Allows(required) {
+		return claims, dashboardErrorf(http.StatusForbidden, "%s %s requires the %s role", req.Method, req.URL.Path, required)
+	}
+	return claims, nil
+}
+
+// listWorkspaces writes the infos of the workspaces the claims allow, like
+// the list handler.
+func (s *Service) listWorkspaces(w http.ResponseWriter, claims auth.Claims) {
+	workspaces, err := s.Agent.Workspaces()
+	if err != nil {
+		writeDashboardError(w, err)
+		return
+	}
+	infos := []agent.WorkspaceInfo{}
+	for _, ws := range workspaces {
+		if claims.AllowsWorkspace(ws.Name) {
+			infos = append(infos, ws.Info())
+		}
+	}
+	writeDashboardJSON(w, http.StatusOK, infos)
+}
+
+// streamConsole writes the console output of a workspace as it is written
+// until the client goes away.
+func streamConsole(w http.ResponseWriter, req *http.Request, ws *agent.Workspace) {
+	out := ws.Output()
+	go func() {
+		<-req.Context().Done()
+		out.Close()
+	}()
+	defer out.Close()
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+	buf := make([]byte, 4096)
+	for {
+		n, err := out.Read(buf)
+		if n > 0 {
+			if _, err := w.Write(buf[:n]); err != nil {
+				return
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// writeDashboardError writes an error as JSON with its status code, or 500
+// for errors of the agent.
+func writeDashboardError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	if e, ok := err.(*dashboardError); ok {
+		status = e.status
+	}
+	writeDashboardJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+func writeDashboardJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Println(err)
+	}
+}
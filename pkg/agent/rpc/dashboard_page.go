@@ -0,0 +1,168 @@
+package rpc
+
+// dashboardPage is the page of the dashboard. It reads the token from the
+// #token= fragment of the URL or asks for it, and keeps it in local storage.
+const dashboardPage = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Tractor Agent</title>
+<style>
+body { margin: 0; font: 14px sans-serif; color: #222; display: flex; height: 100vh; }
+#workspaces { width: 280px; border-right: 1px solid #ddd; overflow-y: auto; }
+#workspaces h1 { font-size: 16px; margin: 12px; }
+.workspace { padding: 8px 12px; cursor: pointer; border-bottom: 1px solid #eee; }
+.workspace.selected { background: #eef3fb; }
+.workspace .status { float: right; font-size: 12px; }
+.Available { color: #2a8a2a; } .Starting, .Degraded, .Partially { color: #b8860b; } .Unavailable { color: #999; }
+#detail { flex: 1; display: flex; flex-direction: column; min-width: 0; }
+#header { padding: 12px; border-bottom: 1px solid #ddd; }
+#header h2 { margin: 0 0 6px 0; font-size: 16px; }
+#info { font-size: 12px; color: #555; white-space: pre-wrap; }
+#diagnostics { max-height: 30%; overflow-y: auto; font: 12px monospace; border-bottom: 1px solid #ddd; }
+#diagnostics div { padding: 2px 12px; white-space: pre-wrap; }
+.error { color: #c00; } .warning { color: #b8860b; }
+#console { flex: 1; margin: 0; padding: 12px; overflow: auto; background: #1e1e1e; color: #ddd; font: 12px monospace; }
+button { margin-right: 4px; }
+</style>
+</head>
+<body>
+<div id="workspaces"><h1>Workspaces</h1><div id="list"></div></div>
+<div id="detail">
+  <div id="header">
+    <h2 id="name">No workspace selected</h2>
+    <div id="actions" hidden>
+      <button data-action="start">Start</button>
+      <button data-action="restart">Restart</button>
+      <button data-action="stop">Stop</button>
+    </div>
+    <div id="info"></div>
+  </div>
+  <div id="diagnostics"></div>
+  <pre id="console"></pre>
+</div>
+<script>
+let token = localStorage.getItem("tractor-token") || "";
+const match = location.hash.match(/token=([^&]+)/);
+if (match) {
+  token = decodeURIComponent(match[1]);
+  localStorage.setItem("tractor-token", token);
+  history.replaceState(null, "", location.pathname);
+}
+let selected = null;
+let console_ = null;
+
+async function api(method, path) {
+  const resp = await fetch("/api/workspaces" + path, {method, headers: {"Authorization": "Bearer " + token}});
+  if (resp.status === 401) {
+    token = prompt("Token of the agent (tractor agent token):") || "";
+    localStorage.setItem("tractor-token", token);
+    throw new Error("unauthenticated");
+  }
+  const body = await resp.json();
+  if (!resp.ok) {
+    throw new Error(body.error);
+  }
+  return body;
+}
+
+function text(tag, className, value) {
+  const el = document.createElement(tag);
+  if (className) el.className = className;
+  el.textContent = value;
+  return el;
+}
+
+async function refresh() {
+  let infos;
+  try {
+    infos = await api("GET", "");
+  } catch (e) {
+    return;
+  }
+  const list = document.getElementById("list");
+  list.innerHTML = "";
+  for (const info of infos) {
+    const el = text("div", "workspace" + (info.Name === selected ? " selected" : ""), info.Name);
+    el.appendChild(text("span", "status " + info.Status, info.CrashLooping ? "Crash looping" : info.Status));
+    el.onclick = () => select(info.Name);
+    list.appendChild(el);
+    if (info.Name === selected) {
+      showInfo(info);
+    }
+  }
+}
+
+function showInfo(info) {
+  const lines = ["Path: " + info.TargetPath, "Status: " + info.Status];
+  if (info.PID) lines.push("PID: " + info.PID + ", up " + Math.round(info.Uptime / 1e9) + "s");
+  lines.push("Restarts: " + info.Restarts);
+  if (info.LastBuild) lines.push("Last build: " + (info.LastBuild.Error ? "failed (" + info.LastBuild.Errors + " errors)" : "ok (" + info.LastBuild.Warnings + " warnings)"));
+  if (info.Rollback) lines.push("Rolled back: " + info.Rollback.Error);
+  if (info.Health && info.Health.Error) lines.push("Health: " + info.Health.Error);
+  for (const e of (info.Health && info.Health.Errors) || []) lines.push("Component error: " + e.Node + " " + e.Component + ": " + e.Error);
+  document.getElementById("info").textContent = lines.join("\n");
+}
+
+async function showDiagnostics() {
+  const el = document.getElementById("diagnostics");
+  el.innerHTML = "";
+  for (const d of await api("GET", "/" + selected + "/diagnostics")) {
+    el.appendChild(text("div", d.Severity, d.File + ":" + d.Line + (d.Column ? ":" + d.Column : "") + ": " + d.Message));
+  }
+}
+
+async function streamConsole(name) {
+  const el = document.getElementById("console");
+  el.textContent = "";
+  const ctrl = new AbortController();
+  console_ = ctrl;
+  try {
+    const resp = await fetch("/api/workspaces/" + name + "/console", {headers: {"Authorization": "Bearer " + token}, signal: ctrl.signal});
+    const reader = resp.body.getReader();
+    const decoder = new TextDecoder();
+    for (;;) {
+      const {done, value} = await reader.read();
+      if (done) break;
+      const bottom = el.scrollTop + el.clientHeight >= el.scrollHeight - 4;
+      el.textContent += decoder.decode(value, {stream: true});
+      if (bottom) el.scrollTop = el.scrollHeight;
+    }
+  } catch (e) {
+  }
+  // reconnect unless another workspace was selected
+  if (console_ === ctrl && !ctrl.signal.aborted) {
+    setTimeout(() => { if (console_ === ctrl) streamConsole(name); }, 2000);
+  }
+}
+
+function select(name) {
+  selected = name;
+  document.getElementById("name").textContent = name;
+  document.getElementById("actions").hidden = false;
+  if (console_) console_.abort();
+  streamConsole(name);
+  showDiagnostics().catch(() => {});
+  refresh();
+}
+
+for (const button of document.querySelectorAll("#actions button")) {
+  button.onclick = async () => {
+    try {
+      await api("POST", "/" + selected + "/" + button.dataset.action);
+    } catch (e) {
+      alert(e.message);
+    }
+    refresh();
+  };
+}
+
+refresh();
+setInterval(() => {
+  refresh();
+  if (selected) showDiagnostics().catch(() => {});
+}, 2000);
+</script>
+</body>
+</html>
+`
@@ -0,0 +1,154 @@
+package rpc
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/manifold/tractor/pkg/agent"
+	"github.com/manifold/tractor/pkg/misc/auth"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	_, b, _, _ = runtime.Caller(0)
+	wspath     = filepath.Join(filepath.Dir(b), "..", "testutil", "testworkspace")
+)
+
+func setupDashboard(t *testing.T) (*Service, func()) {
+	dirname, err := ioutil.TempDir("", "tractor-pkg-agent-rpc")
+	require.NoError(t, err)
+	ag, err := agent.Open(dirname, nil, false)
+	require.NoError(t, err)
+	// the test workspace doesn't serve the health RPC
+	ag.HealthInterval = 0
+	for _, name := range []string{"test", "other"} {
+		require.NoError(t, os.Symlink(wspath, filepath.Join(ag.WorkspacesPath, name)))
+	}
+	return &Service{Agent: ag}, func() {
+		ag.Shutdown()
+		os.RemoveAll(dirname)
+	}
+}
+
+func dashboardRequest(s *Service, method, path, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	s.Dashboard().ServeHTTP(w, req)
+	return w
+}
+
+func issueToken(t *testing.T, s *Service, claims auth.Claims) string {
+	token, err := s.Agent.IssueToken(claims)
+	require.NoError(t, err)
+	return token
+}
+
+func TestDashboard(t *testing.T) {
+	s, teardown := setupDashboard(t)
+	defer teardown()
+	ws := s.Agent.Workspace("test")
+	require.NotNil(t, ws)
+	require.NoError(t, ws.StartDaemon())
+	viewer := issueToken(t, s, auth.Claims{Role: auth.Viewer})
+	editor := issueToken(t, s, auth.Claims{Role: auth.Editor})
+
+	w := dashboardRequest(s, "GET", "/", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "<title>Tractor Agent</title>")
+
+	w = dashboardRequest(s, "GET", "/api/workspaces", "")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = dashboardRequest(s, "GET", "/api/workspaces", viewer)
+	require.Equal(t, http.StatusOK, w.Code)
+	var infos []agent.WorkspaceInfo
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &infos))
+	require.Len(t, infos, 2)
+	assert.Equal(t, "other", infos[0].Name)
+	assert.Equal(t, "test", infos[1].Name)
+	assert.Equal(t, agent.StatusAvailable, infos[1].Status)
+
+	w = dashboardRequest(s, "GET", "/api/workspaces/test/diagnostics", viewer)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "[]\n", w.Body.String())
+
+	w = dashboardRequest(s, "POST", "/api/workspaces/test/stop", viewer)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w = dashboardRequest(s, "GET", "/api/workspaces/nope", viewer)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = dashboardRequest(s, "GET", "/api/workspaces/test/nope", viewer)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = dashboardRequest(s, "GET", "/api/workspaces/test/stop", editor)
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+
+	w = dashboardRequest(s, "POST", "/api/workspaces/test/stop", editor)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w = dashboardRequest(s, "POST", "/api/workspaces/test/restart", editor)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "\"workspace \\\"test\\\" restarted\"\n", w.Body.String())
+
+	t.Run("console", func(t *testing.T) {
+		srv := httptest.NewServer(s.Dashboard())
+		defer srv.Close()
+		req, err := http.NewRequest("GET", srv.URL+"/api/workspaces/test/console", nil)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer "+viewer)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		scanner := bufio.NewScanner(resp.Body)
+		require.True(t, scanner.Scan())
+		assert.True(t, strings.HasPrefix(scanner.Text(), "pid "), scanner.Text())
+	})
+
+	t.Run("workspace token", func(t *testing.T) {
+		token := issueToken(t, s, auth.Claims{Role: auth.Viewer, Workspace: "other"})
+		w := dashboardRequest(s, "GET", "/api/workspaces", token)
+		require.Equal(t, http.StatusOK, w.Code)
+		var infos []agent.WorkspaceInfo
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &infos))
+		require.Len(t, infos, 1)
+		assert.Equal(t, "other", infos[0].Name)
+
+		w = dashboardRequest(s, "GET", "/api/workspaces/test", token)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+}
+
+func TestDashboardUnbuilt(t *testing.T) {
+	s, teardown := setupDashboard(t)
+	defer teardown()
+	dir, err := ioutil.TempDir("", "tractor-pkg-agent-rpc-broken")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "go.mod"), []byte("module broken\n"), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "workspace.go"), []byte("package main\n\nfunc main() {\n\tundefined()\n}\n"), 0644))
+	require.NoError(t, os.Symlink(dir, filepath.Join(s.Agent.WorkspacesPath, "broken")))
+	ws := s.Agent.Workspace("broken")
+	require.NotNil(t, ws)
+	require.Error(t, ws.StartDaemon())
+	editor := issueToken(t, s, auth.Claims{Role: auth.Editor})
+
+	w := dashboardRequest(s, "GET", "/api/workspaces/broken", editor)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	for _, action := range []string{"start", "restart"} {
+		w = dashboardRequest(s, "POST", "/api/workspaces/broken/"+action, editor)
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.Contains(t, w.Body.String(), "workspace failed to build")
+	}
+	w = dashboardRequest(s, "POST", "/api/workspaces/broken/stop", editor)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+}
@@ -3,13 +3,17 @@ package rpc
 import (
 	"fmt"
 	"io"
+	"path/filepath"
+	"sync"
 
 	qrpc "github.com/manifold/qtalk/golang/rpc"
+	"github.com/manifold/tractor/pkg/agent"
+	wsrpc "github.com/manifold/tractor/pkg/workspace/rpc"
 )
 
 func (s *Service) Connect() func(qrpc.Responder, *qrpc.Call) {
 	return func(r qrpc.Responder, c *qrpc.Call) {
-		ws, err := findWorkspace(s.Agent, c)
+		ws, err := s.findWorkspace(c)
 		if err != nil {
 			r.Return(err)
 			return
@@ -41,7 +45,7 @@ func (s *Service) Connect() func(qrpc.Responder, *qrpc.Call) {
 
 func (s *Service) Start() func(qrpc.Responder, *qrpc.Call) {
 	return func(r qrpc.Responder, c *qrpc.Call) {
-		ws, err := findWorkspace(s.Agent, c)
+		ws, err := s.findWorkspace(c)
 		if err != nil {
 			r.Return(err)
 			return
@@ -56,9 +60,25 @@ func (s *Service) Start() func(qrpc.Responder, *qrpc.Call) {
 	}
 }
 
+// Restart restarts a workspace, or starts it if it is not running.
+func (s *Service) Restart() func(qrpc.Responder, *qrpc.Call) {
+	return func(r qrpc.Responder, c *qrpc.Call) {
+		ws, err := s.findWorkspace(c)
+		if err != nil {
+			r.Return(err)
+			return
+		}
+		if err := ws.Restart(); err != nil {
+			r.Return(err)
+			return
+		}
+		r.Return(fmt.Sprintf("workspace %q restarted", ws.Name))
+	}
+}
+
 func (s *Service) Stop() func(qrpc.Responder, *qrpc.Call) {
 	return func(r qrpc.Responder, c *qrpc.Call) {
-		ws, err := findWorkspace(s.Agent, c)
+		ws, err := s.findWorkspace(c)
 		if err != nil {
 			r.Return(err)
 			return
@@ -68,3 +88,230 @@ func (s *Service) Stop() func(qrpc.Responder, *qrpc.Call) {
 		r.Return(fmt.Sprintf("workspace %q stopped", ws.Name))
 	}
 }
+
+// List replies with the info of the workspaces the caller has access to.
+func (s *Service) List() func(qrpc.Responder, *qrpc.Call) {
+	return func(r qrpc.Responder, c *qrpc.Call) {
+		claims, err := s.claims(c.Caller)
+		if err != nil {
+			r.Return(err)
+			return
+		}
+		workspaces, err := s.Agent.Workspaces()
+		if err != nil {
+			r.Return(err)
+			return
+		}
+		infos := []agent.WorkspaceInfo{}
+		for _, ws := range workspaces {
+			if claims.AllowsWorkspace(ws.Name) {
+				infos = append(infos, ws.Info())
+			}
+		}
+		r.Return(infos)
+	}
+}
+
+// Status replies with the status of a workspace.
+func (s *Service) Status() func(qrpc.Responder, *qrpc.Call) {
+	return func(r qrpc.Responder, c *qrpc.Call) {
+		ws, err := s.findWorkspace(c)
+		if err != nil {
+			r.Return(err)
+			return
+		}
+		r.Return(ws.Status())
+	}
+}
+
+// Info replies with the info of a workspace.
+func (s *Service) Info() func(qrpc.Responder, *qrpc.Call) {
+	return func(r qrpc.Responder, c *qrpc.Call) {
+		ws, err := s.findWorkspace(c)
+		if err != nil {
+			r.Return(err)
+			return
+		}
+		r.Return(ws.Info())
+	}
+}
+
+// Diagnostics replies with the build and vet diagnostics of a workspace.
+func (s *Service) Diagnostics() func(qrpc.Responder, *qrpc.Call) {
+	return func(r qrpc.Responder, c *qrpc.Call) {
+		ws, err := s.findWorkspace(c)
+		if err != nil {
+			r.Return(err)
+			return
+		}
+		r.Return(ws.Diagnostics())
+	}
+}
+
+// DiagnosticsUpdate is sent with the "diagnostics" callback to callers
+// watching the diagnostics of a workspace.
+type DiagnosticsUpdate struct {
+	Workspace   string
+	Path        string // target path of the workspace
+	Diagnostics []agent.Diagnostic
+}
+
+// WatchDiagnostics sends the diagnostics of a workspace to the caller with
+// the "diagnostics" callback, now and whenever they change, until a callback
+// fails or the caller disconnects.
+func (s *Service) WatchDiagnostics() func(qrpc.Responder, *qrpc.Call) {
+	return func(r qrpc.Responder, c *qrpc.Call) {
+		ws, err := s.findWorkspace(c)
+		if err != nil {
+			r.Return(err)
+			return
+		}
+		send := func(ws *agent.Workspace, diags []agent.Diagnostic) error {
+			_, err := c.Caller.Call("diagnostics", DiagnosticsUpdate{
+				Workspace:   ws.Name,
+				Path:        ws.TargetPath,
+				Diagnostics: diags,
+			}, nil)
+			return err
+		}
+		if err := send(ws, ws.Diagnostics()); err != nil {
+			r.Return(err)
+			return
+		}
+		var once sync.Once
+		failed := make(chan struct{})
+		stop := ws.ObserveDiagnostics(func(ws *agent.Workspace, diags []agent.Diagnostic) {
+			if err := send(ws, diags); err != nil {
+				once.Do(func() { close(failed) })
+			}
+		})
+		go func() {
+			select {
+			case <-failed:
+			case <-wsrpc.CallerClosed(c.Caller):
+			}
+			stop()
+		}()
+		r.Return(nil)
+	}
+}
+
+type CreateParams struct {
+	Name     string
+	Path     string // absolute path of the new workspace directory
+	Template string // absolute path of the template, empty for the default
+	Module   string // module path for go.mod, empty for the name
+}
+
+type RegisterParams struct {
+	Name string // empty for the base name of the path
+	Path string // absolute path of the workspace directory
+}
+
+type RenameParams struct {
+	Name    string
+	NewName string
+}
+
+// Create creates a workspace from a template and replies with its info.
+func (s *Service) Create() func(qrpc.Responder, *qrpc.Call) {
+	return func(r qrpc.Responder, c *qrpc.Call) {
+		var params CreateParams
+		if err := c.Decode(&params); err != nil {
+			r.Return(err)
+			return
+		}
+		if err := s.allowWorkspaces(c, params.Name); err != nil {
+			r.Return(err)
+			return
+		}
+		ws, err := s.Agent.CreateWorkspace(params.Name, params.Path, params.Template, params.Module)
+		if err != nil {
+			r.Return(err)
+			return
+		}
+		r.Return(ws.Info())
+	}
+}
+
+// Register registers an existing workspace directory and replies with its
+// info.
+func (s *Service) Register() func(qrpc.Responder, *qrpc.Call) {
+	return func(r qrpc.Responder, c *qrpc.Call) {
+		var params RegisterParams
+		if err := c.Decode(&params); err != nil {
+			r.Return(err)
+			return
+		}
+		if params.Name == "" {
+			params.Name = filepath.Base(params.Path)
+		}
+		if err := s.allowWorkspaces(c, params.Name); err != nil {
+			r.Return(err)
+			return
+		}
+		ws, err := s.Agent.RegisterWorkspace(params.Name, params.Path)
+		if err != nil {
+			r.Return(err)
+			return
+		}
+		r.Return(ws.Info())
+	}
+}
+
+// Unregister stops a workspace and removes it from the agent, keeping its
+// directory.
+func (s *Service) Unregister() func(qrpc.Responder, *qrpc.Call) {
+	return func(r qrpc.Responder, c *qrpc.Call) {
+		var name string
+		if err := c.Decode(&name); err != nil {
+			r.Return(err)
+			return
+		}
+		if err := s.allowWorkspaces(c, name); err != nil {
+			r.Return(err)
+			return
+		}
+		if err := s.Agent.UnregisterWorkspace(name); err != nil {
+			r.Return(err)
+			return
+		}
+		r.Return(fmt.Sprintf("workspace %q unregistered", name))
+	}
+}
+
+// Rename renames a workspace and replies with its info.
+func (s *Service) Rename() func(qrpc.Responder, *qrpc.Call) {
+	return func(r qrpc.Responder, c *qrpc.Call) {
+		var params RenameParams
+		if err := c.Decode(&params); err != nil {
+			r.Return(err)
+			return
+		}
+		if err := s.allowWorkspaces(c, params.Name, params.NewName); err != nil {
+			r.Return(err)
+			return
+		}
+		ws, err := s.Agent.RenameWorkspace(params.Name, params.NewName)
+		if err != nil {
+			r.Return(err)
+			return
+		}
+		r.Return(ws.Info())
+	}
+}
+
+// allowWorkspaces returns an error if the token of the caller is not valid for
+// all of the named workspaces.
+func (s *Service) allowWorkspaces(c *qrpc.Call, names ...string) error {
+	claims, err := s.claims(c.Caller)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		if !claims.AllowsWorkspace(name) {
+			return errorf(wsrpc.PermissionDenied, "token is not valid for workspace %q", name)
+		}
+	}
+	return nil
+}
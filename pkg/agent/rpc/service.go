@@ -4,22 +4,27 @@ import (
 	"context"
 	"fmt"
 	"os"
-	"strings"
-	"time"
+	"sync"
 
 	"github.com/manifold/qtalk/golang/mux"
 	qrpc "github.com/manifold/qtalk/golang/rpc"
 	"github.com/manifold/tractor/pkg/agent"
+	"github.com/manifold/tractor/pkg/misc/auth"
 	"github.com/manifold/tractor/pkg/misc/logging"
+	wsrpc "github.com/manifold/tractor/pkg/workspace/rpc"
 )
 
-// Service provides a QRPC server to connect, restart, and stop running
-// workspaces.
+// Service provides a QRPC server to manage workspaces and to list, inspect,
+// connect, restart, and stop running workspaces. Clients authenticate with a
+// token issued by the agent first.
 type Service struct {
-	Agent *agent.Agent
-	Log   logging.Logger
-	api   qrpc.API
-	l     mux.Listener
+	Agent     *agent.Agent
+	Log       logging.Logger
+	api       qrpc.API
+	l         mux.Listener
+	callers   map[qrpc.Caller]auth.Claims
+	exchanged map[string]int64 // expiry of the exchange tokens used
+	mu        sync.Mutex
 }
 
 func (s *Service) InitializeDaemon() (err error) {
@@ -27,18 +32,31 @@ func (s *Service) InitializeDaemon() (err error) {
 		return err
 	}
 
+	s.callers = make(map[qrpc.Caller]auth.Claims)
+	s.exchanged = make(map[string]int64)
 	s.api = qrpc.NewAPI()
-	s.api.HandleFunc("connect", s.Connect())
-	s.api.HandleFunc("start", s.Start())
-	s.api.HandleFunc("stop", s.Stop())
+	s.api.HandleFunc("authenticate", s.Authenticate())
+	s.api.HandleFunc("exchangeToken", s.ExchangeToken())
+	s.handle("connect", auth.Viewer, s.Connect())
+	s.handle("start", auth.Editor, s.Start())
+	s.handle("restart", auth.Editor, s.Restart())
+	s.handle("stop", auth.Editor, s.Stop())
+	s.handle("list", auth.Viewer, s.List())
+	s.handle("status", auth.Viewer, s.Status())
+	s.handle("info", auth.Viewer, s.Info())
+	s.handle("diagnostics", auth.Viewer, s.Diagnostics())
+	s.handle("watchDiagnostics", auth.Viewer, s.WatchDiagnostics())
+	s.handle("create", auth.Editor, s.Create())
+	s.handle("register", auth.Editor, s.Register())
+	s.handle("unregister", auth.Editor, s.Unregister())
+	s.handle("rename", auth.Editor, s.Rename())
+	s.handle("issueToken", auth.Editor, s.IssueToken())
 	return nil
 }
 
 func (s *Service) Serve(ctx context.Context) {
 	server := &qrpc.Server{}
 
-	//s.periodicStatus()
-
 	s.Log.Infof("[server] unix://%s", s.Agent.SocketPath)
 	if err := server.Serve(s.l, s.api); err != nil {
 		fmt.Println(err)
@@ -52,47 +70,23 @@ func (s *Service) TerminateDaemon() error {
 	return nil
 }
 
-func (s *Service) periodicStatus() {
-	go func() {
-		var lastMsg string
-		for {
-			time.Sleep(time.Second * 3)
-			msg, err := wsStatus(s.Agent)
-			if err != nil {
-				s.Log.Info("[workspaces]", err)
-			}
-			if lastMsg != msg && len(msg) > 0 {
-				s.Log.Info("[workspaces]", msg)
-			}
-			lastMsg = msg
-		}
-	}()
-}
-
-func wsStatus(a *agent.Agent) (string, error) {
-	workspaces, err := a.Workspaces()
-	if err != nil || len(workspaces) == 0 {
-		return "", err
-	}
-
-	pairs := make([]string, len(workspaces))
-	for i, ws := range workspaces {
-		p, w := ws.BufferStatus()
-		pairs[i] = fmt.Sprintf("%s=%s (%d pipe(s), %d written)",
-			ws.Name, ws.Status(), p, w)
-	}
-	return strings.Join(pairs, ", "), nil
-}
-
-func findWorkspace(a *agent.Agent, call *qrpc.Call) (*agent.Workspace, error) {
+func (s *Service) findWorkspace(call *qrpc.Call) (*agent.Workspace, error) {
 	var workspacePath string
 	if err := call.Decode(&workspacePath); err != nil {
 		return nil, err
 	}
 
-	if ws := a.Workspace(workspacePath); ws != nil {
-		return ws, nil
+	ws := s.Agent.Workspace(workspacePath)
+	if ws == nil {
+		return nil, errorf(wsrpc.NotFound, "no workspace found for %q", workspacePath)
 	}
 
-	return nil, fmt.Errorf("no workspace found for %q", workspacePath)
+	claims, err := s.claims(call.Caller)
+	if err != nil {
+		return nil, err
+	}
+	if !claims.AllowsWorkspace(ws.Name) {
+		return nil, errorf(wsrpc.PermissionDenied, "token is not valid for workspace %q", ws.Name)
+	}
+	return ws, nil
 }
@@ -7,14 +7,21 @@ import (
 	"io"
 	"os"
 	"os/exec"
+	"strings"
+	"time"
 
 	"github.com/manifold/tractor/pkg/agent"
+	"github.com/manifold/tractor/pkg/misc/auth"
 	"github.com/manifold/tractor/pkg/misc/daemon"
 	"github.com/manifold/tractor/pkg/misc/logging"
 	"github.com/manifold/tractor/pkg/misc/subcmd"
 	"github.com/skratchdot/open-golang/open"
 )
 
+// studioTokenTTL is the time Studio has to exchange the token it is opened
+// with.
+const studioTokenTTL = time.Minute
+
 type Service struct {
 	Agent  *agent.Agent
 	Logger logging.DebugLogger
@@ -113,7 +120,19 @@ func (s *Service) Serve(ctx context.Context) {
 				}
 				for _, ws := range workspaces {
 					if ws.Name == msg.Item.Title {
-						open.Start("http://localhost:3000/#" + ws.TargetPath)
+						// Studio exchanges the token in the URL for one it
+						// keeps, so the one in the URL is short-lived
+						token, err := s.Agent.IssueToken(auth.Claims{
+							Role:      auth.Editor,
+							Workspace: ws.Name,
+							Expires:   time.Now().Add(studioTokenTTL).Unix(),
+							Exchange:  true,
+						})
+						if err != nil {
+							s.Logger.Debug("unable to issue token:", err)
+							continue
+						}
+						open.Start(strings.TrimSuffix(s.Agent.Config().StudioURL, "/") + "/?token=" + token + "#" + ws.TargetPath)
 					}
 				}
 			default:
@@ -20,6 +20,8 @@ var (
 		"Unavailable": iconData.Unavailable,
 		"Available":   iconData.Available,
 		"Partially":   iconData.Partially,
+		"Starting":    iconData.Partially,
+		"Degraded":    iconData.Partially,
 	}
 
 	menuItems []*systray.MenuItem
@@ -1,6 +1,7 @@
 package agent
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
@@ -15,8 +16,11 @@ import (
 
 	"github.com/manifold/tractor/pkg/agent/console"
 	"github.com/manifold/tractor/pkg/data/icons"
+	"github.com/manifold/tractor/pkg/misc/auth"
 	"github.com/manifold/tractor/pkg/misc/buffer"
+	"github.com/manifold/tractor/pkg/misc/config"
 	"github.com/manifold/tractor/pkg/misc/logging"
+	"github.com/manifold/tractor/pkg/misc/secrets"
 	"github.com/manifold/tractor/pkg/misc/subcmd"
 	"github.com/radovskyb/watcher"
 )
@@ -27,15 +31,28 @@ const (
 	StatusAvailable   WorkspaceStatus = "Available"
 	StatusPartially   WorkspaceStatus = "Partially"
 	StatusUnavailable WorkspaceStatus = "Unavailable"
+	StatusStarting    WorkspaceStatus = "Starting" // running but not ready yet
+	StatusDegraded    WorkspaceStatus = "Degraded" // running with errors or not answering
 
-	WatchInterval = 50 * time.Millisecond
+	WatchInterval = 50 * time.Millisecond // default between polls for changed files
 )
 
+// DefaultRestartPolicy restarts workspace daemons that fail, backing off while
+// they keep crashing on startup until they are given up as unavailable.
+var DefaultRestartPolicy = subcmd.RestartPolicy{
+	Mode:        subcmd.RestartOnFailure,
+	MinBackoff:  500 * time.Millisecond,
+	MaxBackoff:  30 * time.Second,
+	Jitter:      0.2,
+	CrashWindow: 10 * time.Second,
+	CrashLoop:   5,
+}
+
 func (s WorkspaceStatus) Icon() []byte {
 	switch s {
 	case StatusAvailable:
 		return icons.Available
-	case StatusPartially:
+	case StatusPartially, StatusStarting, StatusDegraded:
 		return icons.Partially
 	default:
 		return icons.Unavailable
@@ -48,12 +65,53 @@ func (s WorkspaceStatus) String() string {
 
 type WorkspaceObserver func(*Workspace, WorkspaceStatus)
 
+// WorkspaceInfo summarizes the state of a workspace.
+type WorkspaceInfo struct {
+	Name       string
+	TargetPath string
+	Status     WorkspaceStatus
+	PID        int           // zero if the daemon is not running
+	Started    int64         // Unix time the daemon started, zero if not running
+	Uptime     time.Duration // time since the daemon started
+	Restarts   int           // restarts of the daemon since the agent started
+	LastBuild  *BuildResult  // nil if the workspace wasn't built yet
+	Console    ConsoleStats
+
+	CrashLooping bool          // if the daemon is not restarted as it kept crashing
+	Exits        []subcmd.Exit // recent exits of the daemon, oldest first
+	Rollback     *Rollback     // nil unless the last new binary was rolled back
+	Health       *Health       // last health check of the daemon, nil if none
+}
+
+// BuildResult is the result of compiling a workspace.
+type BuildResult struct {
+	Time     int64 // Unix time the build finished
+	Duration time.Duration
+	Error    string // empty if the build succeeded
+	Errors   int    // diagnostics of the build
+	Warnings int    // diagnostics of go vet after the build succeeded
+}
+
+// ConsoleStats describes the console buffer of a workspace.
+type ConsoleStats struct {
+	Pipes   int   // readers of the console, like connected clients
+	Written int64 // bytes written since the daemon started
+}
+
 type Workspace struct {
 	Name        string // base name of dir (~/.tractor/workspaces/{name})
 	SymlinkPath string // absolute path to symlink file (~/.tractor/workspaces/{name})
 	TargetPath  string // absolute path to target of symlink (actual workspace)
 	SocketPath  string // absolute path to socket file (~/.tractor/sockets/{name}.sock)
 	BinPath     string // absolute path to compiled binary (~/.tractor/bin/{name})
+	GoodBinPath string // absolute path to the last good binary (~/.tractor/bin/{name}.good)
+
+	RestartPolicy subcmd.RestartPolicy // used when the daemon is started
+	RollbackGrace time.Duration        // time a new binary must run to become the last good one
+	// HealthInterval is the time between health checks of the daemon, zero
+	// to consider it available once it started.
+	HealthInterval time.Duration
+	ReadyTimeout   time.Duration // time the daemon has to become ready
 
 	log         logging.Logger
 	status      WorkspaceStatus
@@ -63,8 +121,38 @@ type Workspace struct {
 	daemon      *subcmd.Subcmd
 	daemonCmd   []string
 	goBin       string
-
-	watcher *watcher.Watcher
+	authKeyPath string
+	secrets     *secrets.Store
+
+	starts      int // times the daemon started
+	builds      int // times the workspace was built
+	lastBuild   *BuildResult
+	diagnostics []Diagnostic
+	rollback    *Rollback
+	health      *Health
+	infoMu      sync.Mutex
+
+	probe      func() Health
+	healthDone chan struct{} // closed when the checked daemon run ends
+	healthMu   sync.Mutex
+
+	untried   bool // if the binary was built but did not run yet
+	trial     int  // number of the run on trial, zero if none
+	trials    int
+	graceOver bool // if the run on trial outlasted the RollbackGrace
+	binMu     sync.Mutex
+
+	watcher  *watcher.Watcher
+	changed  []string // files changed since the last reload
+	removed  bool     // if files were removed since the last reload
+	changeMu sync.Mutex
+
+	diagWatchers  map[int]chan []Diagnostic // latest diagnostics per observer
+	diagObservers int                       // number of the last diagnostics observer
+
+	config        config.Workspace // from the tractor.toml of the workspace
+	watchDefaults config.Watch     // from the agent
+	cfgMu         sync.Mutex
 
 	starting sync.Mutex
 	statMu   sync.Mutex
@@ -89,15 +177,34 @@ func OpenWorkspace(a *Agent, name string) (*Workspace, error) {
 		TargetPath:  targetPath,
 		SocketPath:  socketPath,
 		BinPath:     binPath,
+		GoodBinPath: binPath + ".good",
+
+		RestartPolicy: a.RestartPolicy,
+		RollbackGrace: a.RollbackGrace,
+
+		HealthInterval: a.HealthInterval,
+		ReadyTimeout:   a.ReadyTimeout,
+
 		status:      StatusPartially,
 		observers:   make([]WorkspaceObserver, 0),
+		config:      config.DefaultWorkspace(),
 		log:         a.Logger,
 		consolePipe: consolePipe,
 		goBin:       a.GoBin,
+		authKeyPath: a.AuthKeyPath,
+		secrets:     a.Secrets,
 		daemonCmd: []string{binPath,
 			"-proto", "unix", "-addr", socketPath},
 	}
-	ws.consoleBuf, err = buffer.NewBuffer(1024 * 1024)
+	ws.probe = ws.checkHealth
+	agentConfig := a.Config()
+	ws.watchDefaults = agentConfig.Watch
+	ws.loadConfig()
+	size := agentConfig.ConsoleBuffer
+	if ws.config.ConsoleBuffer > 0 {
+		size = ws.config.ConsoleBuffer
+	}
+	ws.consoleBuf, err = buffer.NewBuffer(int64(size))
 	if err != nil {
 		return nil, err
 	}
@@ -110,17 +217,86 @@ func (w *Workspace) Status() WorkspaceStatus {
 	return w.status
 }
 
+// Info returns a summary of the state of the workspace.
+func (w *Workspace) Info() WorkspaceInfo {
+	wi := WorkspaceInfo{
+		Name:       w.Name,
+		TargetPath: w.TargetPath,
+		Status:     w.Status(),
+	}
+	wi.Console.Pipes, wi.Console.Written = w.BufferStatus()
+	if w.daemon != nil {
+		wi.CrashLooping = w.daemon.Status() == subcmd.StatusCrashLooping
+		wi.Exits = w.daemon.Exits()
+		wi.PID = w.daemon.Pid()
+		if started := w.daemon.StartedAt(); !started.IsZero() {
+			wi.Started = started.Unix()
+			wi.Uptime = time.Since(started)
+		}
+	}
+	w.infoMu.Lock()
+	if w.starts > 1 {
+		wi.Restarts = w.starts - 1
+	}
+	if w.lastBuild != nil {
+		b := *w.lastBuild
+		wi.LastBuild = &b
+	}
+	if w.rollback != nil {
+		r := *w.rollback
+		wi.Rollback = &r
+	}
+	if w.health != nil {
+		h := *w.health
+		wi.Health = &h
+	}
+	w.infoMu.Unlock()
+	return wi
+}
+
+// Recompile builds the workspace binary and keeps the result with its
+// diagnostics. After a successful build, go vet runs in the background and
+// its findings replace the diagnostics.
 func (w *Workspace) Recompile() error {
+	start := time.Now()
+	out, err := w.build()
+	result := &BuildResult{
+		Time:     time.Now().Unix(),
+		Duration: time.Since(start),
+	}
+	if err != nil {
+		result.Error = err.Error()
+	} else {
+		w.newBinary()
+	}
+	w.infoMu.Lock()
+	w.lastBuild = result
+	w.builds++
+	build := w.builds
+	w.infoMu.Unlock()
+	if err != nil {
+		w.setDiagnostics(build, parseDiagnostics(out, w.TargetPath, SeverityError, "build"))
+		return err
+	}
+	w.setDiagnostics(build, []Diagnostic{})
+	go w.vet(build)
+	return nil
+}
+
+// build runs go build and returns its output, which also goes to the console.
+func (w *Workspace) build() ([]byte, error) {
+	var out bytes.Buffer
 	cmd := exec.Command("go", "build", "-o", w.BinPath, ".")
 	cmd.Dir = w.TargetPath
+	var console io.Writer = os.Stderr
 	if w.consolePipe != nil {
-		cmd.Stdout = w.consolePipe
-		cmd.Stderr = w.consolePipe
-	} else {
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
+		console = w.consolePipe
 	}
-	return cmd.Run()
+	// a single writer for both, as they are copied by one goroutine then
+	cmd.Stdout = io.MultiWriter(console, &out)
+	cmd.Stderr = cmd.Stdout
+	err := cmd.Run()
+	return out.Bytes(), err
 }
 
 func (w *Workspace) SetDaemonCmd(args ...string) {
@@ -133,19 +309,33 @@ func (w *Workspace) signal(sig os.Signal) {
 	}
 }
 
+// StartDaemon builds the workspace and starts its daemon. A workspace has no
+// daemon until it built, so the daemon controls call it until then.
 func (w *Workspace) StartDaemon() error {
+	w.starting.Lock()
+	defer w.starting.Unlock()
 	if w.daemon != nil {
 		return errors.New("daemon already started")
 	}
 	if err := w.Recompile(); err != nil {
-		return err
+		return fmt.Errorf("workspace failed to build: %w", err)
 	}
 	w.daemon = subcmd.New(w.daemonCmd[0], w.daemonCmd[1:]...)
+	w.daemon.SetRestartPolicy(w.RestartPolicy)
 	w.daemon.Setup = func(cmd *exec.Cmd) error {
 		w.consoleBuf.Reset()
 
+		profile, err := w.profileEnv()
+		if err != nil {
+			logErr(w.log, "[workspace]", w.Name, err)
+			return err
+		}
 		cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
 		cmd.Dir = w.TargetPath
+		cmd.Env = append(os.Environ(),
+			auth.KeyFileEnv+"="+w.authKeyPath,
+			auth.WorkspaceEnv+"="+w.Name)
+		cmd.Env = append(cmd.Env, profile...)
 		cmd.StdinPipe()
 		if w.consolePipe != nil {
 			cmd.Stdout = io.MultiWriter(w.consoleBuf, w.consolePipe)
@@ -161,9 +351,15 @@ func (w *Workspace) StartDaemon() error {
 	w.daemon.Observe(func(cmd *subcmd.Subcmd, status subcmd.Status) {
 		switch status {
 		case subcmd.StatusStarted:
-			w.setStatus(StatusAvailable)
+			w.infoMu.Lock()
+			w.starts++
+			w.infoMu.Unlock()
+			w.startTrial()
+			w.startHealthChecks()
 		case subcmd.StatusExited:
+			w.stopHealthChecks()
 			w.cleanup()
+			w.endTrial(false, cmd.Error())
 			if cmd.Error() != nil {
 				//info(w.log, cmd.Error())
 				w.setStatus(StatusUnavailable)
@@ -171,7 +367,12 @@ func (w *Workspace) StartDaemon() error {
 				w.setStatus(StatusPartially)
 			}
 		case subcmd.StatusStopped:
+			w.stopHealthChecks()
 			w.cleanup()
+			w.endTrial(true, nil)
+			w.setStatus(StatusUnavailable)
+		case subcmd.StatusCrashLooping:
+			info(w.log, "[workspace]", w.Name, "keeps crashing, not restarting until it changes")
 			w.setStatus(StatusUnavailable)
 		}
 	})
@@ -190,21 +391,31 @@ func (w *Workspace) cleanup() {
 	w.consoleBuf.Close()
 }
 
+// Serve starts the daemon and watches the workspace for changes to reload it.
 func (w *Workspace) Serve(ctx context.Context) {
 	w.StartDaemon()
+	w.Watch(ctx)
+}
 
+// Watch watches the workspace for changes to reload it until ctx is done,
+// without starting the daemon first.
+func (w *Workspace) Watch(ctx context.Context) {
+	w.changeMu.Lock()
 	w.watcher = watcher.New()
+	w.changeMu.Unlock()
 	// w.watcher.SetMaxEvents(1)
 	w.watcher.IgnoreHiddenFiles(true)
 	w.watcher.AddFilterHook(func(info os.FileInfo, fullPath string) error {
-		allowedExt := []string{".go", ".ts", ".tsx", ".js", ".jsx", ".html"}
-		ignoreSubstr := []string{"node_modules"}
-		for _, substr := range ignoreSubstr {
+		if fullPath == filepath.Join(w.TargetPath, config.WorkspaceFile) {
+			return nil
+		}
+		watch := w.watchConfig()
+		for _, substr := range watch.Ignore {
 			if strings.Contains(fullPath, substr) {
 				return watcher.ErrSkip
 			}
 		}
-		for _, ext := range allowedExt {
+		for _, ext := range watch.Extensions {
 			if filepath.Ext(info.Name()) == ext {
 				return nil
 			}
@@ -218,7 +429,8 @@ func (w *Workspace) Serve(ctx context.Context) {
 		return
 	}
 
-	debounce := Debounce(WatchInterval)
+	interval := time.Duration(w.watchConfig().Interval)
+	debounce := Debounce(interval)
 	go func() {
 		for {
 			select {
@@ -245,23 +457,26 @@ func (w *Workspace) Serve(ctx context.Context) {
 					// }
 				}
 
+				if event.Path == filepath.Join(w.TargetPath, config.WorkspaceFile) {
+					info(w.log, "[workspace]", w.Name, "reloading", config.WorkspaceFile)
+					w.loadConfig()
+					continue
+				}
+
 				if filepath.Ext(event.Path) != ".go" { //&& !dirCreated
 					continue
 				}
 
 				info(w.log, "detected change:", event.Path)
 
-				debounce(func() {
-					info(w.log, "recompiling workspace:", w.Name)
-					if err := w.Recompile(); err != nil {
-						info(w.log, err)
-						return
-					}
-					info(w.log, "reloading workspace:", w.Name)
-					if err := w.daemon.Restart(); err != nil {
-						info(w.log, err)
-					}
-				})
+				w.changeMu.Lock()
+				w.changed = append(w.changed, event.Path)
+				if event.Op&(watcher.Remove|watcher.Rename|watcher.Move) != 0 {
+					w.removed = true
+				}
+				w.changeMu.Unlock()
+
+				debounce(w.reload)
 
 			case err, ok := <-w.watcher.Error:
 				if !ok {
@@ -274,17 +489,72 @@ func (w *Workspace) Serve(ctx context.Context) {
 		}
 	}()
 
-	if err := w.watcher.Start(WatchInterval); err != nil {
+	if err := w.watcher.Start(interval); err != nil {
 		logErr(w.log, "watcher error:", err)
 	}
 }
 
+// stopWatching stops watching the workspace for changes.
+func (w *Workspace) stopWatching() {
+	w.changeMu.Lock()
+	defer w.changeMu.Unlock()
+	if w.watcher != nil {
+		w.watcher.Close()
+	}
+}
+
+// reload hot reloads the changed delegate packages if only those changed and
+// otherwise recompiles and restarts the daemon.
+func (w *Workspace) reload() {
+	w.changeMu.Lock()
+	changed, removed := w.changed, w.removed
+	w.changed, w.removed = nil, false
+	w.changeMu.Unlock()
+
+	if w.daemon == nil {
+		info(w.log, "building workspace:", w.Name)
+		if err := w.StartDaemon(); err != nil {
+			info(w.log, err)
+		}
+		return
+	}
+
+	if ids, ok := w.delegateIDs(changed); ok && !removed && subcmd.Running(w.daemon) {
+		info(w.log, "hot reloading delegates:", strings.Join(ids, ", "))
+		err := w.HotReload(ids)
+		if err == nil {
+			// keep the binary up to date for the next restart
+			if err := w.Recompile(); err != nil {
+				info(w.log, err)
+			}
+			return
+		}
+		info(w.log, "hot reload failed:", err)
+	}
+
+	info(w.log, "recompiling workspace:", w.Name)
+	if err := w.Recompile(); err != nil {
+		info(w.log, err)
+		return
+	}
+	info(w.log, "reloading workspace:", w.Name)
+	if err := w.daemon.Restart(); err != nil {
+		info(w.log, err)
+	}
+}
+
 func (w *Workspace) Connect() (io.ReadCloser, error) {
 	info(w.log, "[workspace]", w.Name, "Connect()")
 	var err error
-	if !subcmd.Running(w.daemon) {
+	switch {
+	case w.daemon == nil:
+		err = w.StartDaemon()
+	case !subcmd.Running(w.daemon):
 		err = w.daemon.Start()
 	}
+	if err == nil {
+		err = w.waitReady()
+	}
 	out := w.consoleBuf.Pipe()
 	return out, err
 }
@@ -293,9 +563,29 @@ func (w *Workspace) Connect() (io.ReadCloser, error) {
 // not exist, using the path basename as the symlink name
 func (w *Workspace) Start() error {
 	info(w.log, "[workspace]", w.Name, "Start()")
+	if w.daemon == nil {
+		return w.StartDaemon()
+	}
+	return w.daemon.Restart()
+}
+
+// Restart restarts the workspace daemon, or starts it if it is not running.
+// A workspace that never built is built first.
+func (w *Workspace) Restart() error {
+	info(w.log, "[workspace]", w.Name, "Restart()")
+	if w.daemon == nil {
+		return w.StartDaemon()
+	}
 	return w.daemon.Restart()
 }
 
+// Output returns a reader of the console output of the workspace daemon,
+// beginning with the recent output kept in the buffer. Unlike Connect it
+// doesn't start the daemon.
+func (w *Workspace) Output() io.ReadCloser {
+	return w.consoleBuf.Pipe()
+}
+
 // Stop stops the workspace daemon, deleting the unix socket file.
 func (w *Workspace) Stop() error {
 	info(w.log, "[workspace]", w.Name, "Stop()")
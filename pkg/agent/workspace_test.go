@@ -5,9 +5,13 @@ import (
 	"bytes"
 	"io"
 	"io/ioutil"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/manifold/tractor/pkg/misc/subcmd"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -25,6 +29,15 @@ func TestWorkspace(t *testing.T) {
 
 		assert.Nil(t, ws.Start())
 		assert.Equal(t, StatusAvailable, <-status)
+
+		info := ws.Info()
+		assert.Equal(t, "test1", info.Name)
+		assert.Equal(t, StatusAvailable, info.Status)
+		assert.NotZero(t, info.PID)
+		assert.NotZero(t, info.Started)
+		assert.Equal(t, 1, info.Restarts)
+		require.NotNil(t, info.LastBuild)
+		assert.Empty(t, info.LastBuild.Error)
 	})
 
 	t.Run("connect/stop", func(t *testing.T) {
@@ -74,6 +87,45 @@ func TestWorkspace(t *testing.T) {
 	})
 }
 
+func TestWorkspaceCrashLoop(t *testing.T) {
+	ag, teardown := setupAgent(t, func(ag *Agent) {
+		err := os.Symlink(filepath.Join(pkgpath, "errworkspace"), filepath.Join(ag.WorkspacesPath, "err"))
+		require.Nil(t, err)
+	})
+	defer teardown()
+
+	ws := ag.Workspace("err")
+	require.NotNil(t, ws)
+	ws.RestartPolicy = subcmd.RestartPolicy{
+		Mode:        subcmd.RestartOnFailure,
+		MinBackoff:  10 * time.Millisecond,
+		CrashWindow: time.Minute,
+		CrashLoop:   2,
+	}
+	status := make(chan WorkspaceStatus, 10)
+	ws.Observe(func(_ *Workspace, newStatus WorkspaceStatus) {
+		status <- newStatus
+	})
+	require.NoError(t, ws.StartDaemon())
+
+	for i := 0; i < 2; i++ {
+		assert.Equal(t, StatusAvailable, <-status)
+		assert.Equal(t, StatusUnavailable, <-status)
+	}
+
+	var info WorkspaceInfo
+	for i := 0; i < 100; i++ {
+		if info = ws.Info(); info.CrashLooping {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.True(t, info.CrashLooping)
+	require.Len(t, info.Exits, 2)
+	assert.Equal(t, 1, info.Exits[1].Code)
+	assert.True(t, strings.HasPrefix(info.Exits[1].Stderr, "boomtown "))
+}
+
 func setupWorkspace(t *testing.T, ag *Agent, name string) (chan WorkspaceStatus, *Workspace) {
 	status := make(chan WorkspaceStatus, 3)
 	ws := ag.Workspace(name)
@@ -284,6 +284,7 @@ type ComponentSnapshot struct {
 	ObjectID string
 	ID       string
 	Name     string
+	Type     string
 	Enabled  bool
 	Attrs    map[string]interface{}
 	Value    interface{}
@@ -61,33 +61,34 @@ func (i *Image) DestroyObjectPackage(obj manifold.Object) error {
 	return i.IndexObjectPackages()
 }
 
-func (i *Image) CreateObjectPackage(obj manifold.Object) error {
+// CreateObjectPackage creates the delegate package of an object from the named
+// delegate template, or DefaultTemplate if name is empty. Existing packages are
+// left unchanged.
+func (i *Image) CreateObjectPackage(obj manifold.Object, name string) error {
 	i.pkgFs = afero.NewBasePathFs(i.fs, PackageDir)
 
 	dir := path.Join(ObjectDir, obj.ID())
-	if err := i.pkgFs.MkdirAll(dir, 0755); err != nil {
-		return err
-	}
-
-	filepath := path.Join(dir, "component.go")
-	if ok, _ := afero.Exists(i.pkgFs, filepath); ok {
+	if files, _ := afero.Glob(i.pkgFs, path.Join(dir, "*.go")); len(files) > 0 {
 		return nil
 	}
 
-	src := fmt.Sprintf(`package object
-
-import "github.com/manifold/tractor/pkg/manifold/library"
-
-func init() {
-	library.Register(&Main{}, "%s", "")
-}
-
-type Main struct{}
-`, obj.ID())
+	t, err := i.DelegateTemplate(name)
+	if err != nil {
+		return err
+	}
+	files, err := t.Render(obj)
+	if err != nil {
+		return err
+	}
 
-	if err := afero.WriteFile(i.pkgFs, filepath, []byte(src), 0644); err != nil {
+	if err := i.pkgFs.MkdirAll(dir, 0755); err != nil {
 		return err
 	}
+	for filename, src := range files {
+		if err := afero.WriteFile(i.pkgFs, path.Join(dir, filename), src, 0644); err != nil {
+			return err
+		}
+	}
 
 	return i.IndexObjectPackages()
 }
@@ -128,13 +129,29 @@ func (i *Image) Load() (manifold.Object, error) {
 		return nil, err
 	}
 
+	ResolveRefs(obj, refs)
+
+	manifold.Walk(obj, func(o manifold.Object) {
+		o.UpdateRegistry()
+		for _, c := range o.Components() {
+			if e, ok := c.Pointer().(componentEnabler); ok {
+				e.ComponentEnable()
+			}
+		}
+	})
+
+	return obj, nil
+}
+
+// ResolveRefs sets the fields of snapshot refs to the objects they reference.
+func ResolveRefs(root manifold.Object, refs []manifold.SnapshotRef) {
 	for _, ref := range refs {
-		src := obj.FindID(ref.ObjectID)
+		src := root.FindID(ref.ObjectID)
 		if src == nil {
 			log.Printf("no object found for snapshot ref at %s", ref.ObjectID)
 			continue
 		}
-		dst := obj.FindID(ref.TargetID)
+		dst := root.FindID(ref.TargetID)
 		if dst == nil {
 			log.Printf("no object found for snapshot ref target at %s", ref.TargetID)
 			continue
@@ -144,17 +161,6 @@ func (i *Image) Load() (manifold.Object, error) {
 		dst.ValueTo(ptr)
 		src.SetField(ref.Path, reflect.Indirect(ptr).Interface())
 	}
-
-	manifold.Walk(obj, func(o manifold.Object) {
-		o.UpdateRegistry()
-		for _, c := range o.Components() {
-			if e, ok := c.Pointer().(componentEnabler); ok {
-				e.ComponentEnable()
-			}
-		}
-	})
-
-	return obj, nil
 }
 
 func (i *Image) loadObject(fs afero.Fs, path string) (manifold.Object, []manifold.SnapshotRef, error) {
@@ -176,8 +182,7 @@ func (i *Image) loadObject(fs afero.Fs, path string) (manifold.Object, []manifol
 	i.lastObjPath[obj.ID()] = path
 	for _, c := range snapshot.Components {
 		refs = append(refs, c.Refs...)
-		com := library.NewComponent(c.Name, c.Value, c.ID)
-		com.SetEnabled(c.Enabled)
+		com := library.ComponentFromSnapshot(c)
 		obj.AppendComponent(com)
 		if snapshot.Main != "" && c.ID == snapshot.Main {
 			obj.SetMain(com)
@@ -239,6 +244,7 @@ func (i *Image) writeObject(fs afero.Fs, path string, obj manifold.Object) error
 			if err := i.objFs.Rename(oldPath, childPath); err != nil {
 				return err
 			}
+			i.movedObjPath(oldPath, childPath)
 		}
 		if err := fs.MkdirAll(pathName(child), 0755); err != nil {
 			return err
@@ -252,6 +258,16 @@ func (i *Image) writeObject(fs afero.Fs, path string, obj manifold.Object) error
 	return nil
 }
 
+// movedObjPath updates the last paths of the objects in a directory that
+// moved along with it, so that they can be moved again from their new path.
+func (i *Image) movedObjPath(oldPath, newPath string) {
+	for id, p := range i.lastObjPath {
+		if strings.HasPrefix(p, oldPath+"/") {
+			i.lastObjPath[id] = newPath + strings.TrimPrefix(p, oldPath)
+		}
+	}
+}
+
 func pathNameFromImage(parts []string) string {
 	shortid := parts[0][len(parts[0])-8:]
 	exp := regexp.MustCompile("[^a-zA-Z0-9]+")
@@ -0,0 +1,55 @@
+package image
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/manifold/tractor/pkg/manifold"
+	"github.com/manifold/tractor/pkg/manifold/object"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteMovedObjects(t *testing.T) {
+	dir, err := ioutil.TempDir("", "image")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	img := New(dir)
+
+	root := object.New("::root")
+	a := object.New("A")
+	b := object.New("B")
+	c := object.New("C")
+	d := object.New("D")
+	root.AppendChild(a)
+	root.AppendChild(b)
+	a.AppendChild(c)
+	c.AppendChild(d)
+	require.NoError(t, img.Write(root))
+
+	// rename A, which moves C and D on disk, then move C out of it
+	a.SetName("Renamed")
+	a.RemoveChild(c)
+	b.AppendChild(c)
+	require.NoError(t, img.Write(root))
+
+	objDir := filepath.Join(dir, ObjectDir)
+	assert.DirExists(t, filepath.Join(objDir, pathName(b), pathName(c), pathName(d)))
+	_, err = os.Stat(filepath.Join(objDir, pathName(a), pathName(c)))
+	assert.True(t, os.IsNotExist(err))
+
+	loaded, err := New(dir).Load()
+	require.NoError(t, err)
+	paths := map[string]string{}
+	manifold.Walk(loaded, func(o manifold.Object) {
+		paths[o.ID()] = o.Path()
+	})
+	assert.Equal(t, map[string]string{
+		a.ID(): "/Renamed",
+		b.ID(): "/B",
+		c.ID(): "/B/C",
+		d.ID(): "/B/C/D",
+	}, paths)
+}
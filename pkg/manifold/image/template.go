@@ -0,0 +1,382 @@
+package image
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/manifold/tractor/pkg/manifold"
+	"github.com/spf13/afero"
+)
+
+const (
+	// TemplateDir is the workspace directory with additional delegate templates.
+	// Every directory in it is a template named after the directory with files
+	// ending in .tmpl that are rendered into the delegate package without the
+	// extension.
+	TemplateDir = "templates"
+
+	// DefaultTemplate is used for delegates created without a template.
+	DefaultTemplate = "empty"
+)
+
+// DelegateTemplate generates the files of a delegate package. Files map file
+// names to text/template sources executed with TemplateData.
+type DelegateTemplate struct {
+	Name        string
+	Description string
+	Files       map[string]string
+}
+
+// TemplateData is passed to delegate templates.
+type TemplateData struct {
+	ID      string // object ID, which the delegate is registered with
+	Name    string // object name
+	Package string // package name
+}
+
+// DelegateTemplates returns the built-in delegate templates and the ones in
+// the workspace TemplateDir, sorted by name. Workspace templates replace
+// built-in templates with the same name.
+func (i *Image) DelegateTemplates() ([]DelegateTemplate, error) {
+	templates := make(map[string]DelegateTemplate)
+	for _, t := range builtinTemplates {
+		templates[t.Name] = t
+	}
+
+	fi, err := afero.ReadDir(i.fs, TemplateDir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, info := range fi {
+		if !info.IsDir() {
+			continue
+		}
+		t := DelegateTemplate{
+			Name:  info.Name(),
+			Files: make(map[string]string),
+		}
+		dir := path.Join(TemplateDir, info.Name())
+		files, err := afero.ReadDir(i.fs, dir)
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			if file.IsDir() || path.Ext(file.Name()) != ".tmpl" {
+				continue
+			}
+			src, err := afero.ReadFile(i.fs, path.Join(dir, file.Name()))
+			if err != nil {
+				return nil, err
+			}
+			t.Files[strings.TrimSuffix(file.Name(), ".tmpl")] = string(src)
+		}
+		templates[t.Name] = t
+	}
+
+	var names []string
+	for name := range templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var ts []DelegateTemplate
+	for _, name := range names {
+		ts = append(ts, templates[name])
+	}
+	return ts, nil
+}
+
+// DelegateTemplate returns the named delegate template.
+func (i *Image) DelegateTemplate(name string) (DelegateTemplate, error) {
+	if name == "" {
+		name = DefaultTemplate
+	}
+	ts, err := i.DelegateTemplates()
+	if err != nil {
+		return DelegateTemplate{}, err
+	}
+	for _, t := range ts {
+		if t.Name == name {
+			return t, nil
+		}
+	}
+	return DelegateTemplate{}, fmt.Errorf("unknown delegate template: %s", name)
+}
+
+// Render executes the template files for an object.
+func (t DelegateTemplate) Render(obj manifold.Object) (map[string][]byte, error) {
+	data := TemplateData{
+		ID:      obj.ID(),
+		Name:    obj.Name(),
+		Package: "object",
+	}
+	files := make(map[string][]byte)
+	for name, src := range t.Files {
+		tmpl, err := template.New(name).Parse(src)
+		if err != nil {
+			return nil, fmt.Errorf("template %s: %v", t.Name, err)
+		}
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, data); err != nil {
+			return nil, fmt.Errorf("template %s: %v", t.Name, err)
+		}
+		files[name] = buf.Bytes()
+	}
+	return files, nil
+}
+
+var builtinTemplates = []DelegateTemplate{
+	{
+		Name:        "empty",
+		Description: "Component without fields or methods",
+		Files: map[string]string{
+			"component.go": `package {{.Package}}
+
+import "github.com/manifold/tractor/pkg/manifold/library"
+
+func init() {
+	library.Register(&Main{}, "{{.ID}}", "")
+}
+
+type Main struct{}
+`,
+			"component_test.go": `package {{.Package}}
+
+import (
+	"testing"
+
+	"github.com/manifold/tractor/pkg/manifold/library"
+)
+
+func TestRegistered(t *testing.T) {
+	if library.LookupID("{{.ID}}") == nil {
+		t.Fatal("component is not registered")
+	}
+}
+`,
+		},
+	},
+	{
+		Name:        "http",
+		Description: "HTTP handler responding to requests",
+		Files: map[string]string{
+			"component.go": `package {{.Package}}
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/manifold/tractor/pkg/manifold/library"
+)
+
+func init() {
+	library.Register(&Main{}, "{{.ID}}", "")
+}
+
+// Main handles HTTP requests for {{.Name}}.
+type Main struct {
+	// Body is written in response to every request.
+	Body string
+}
+
+func (c *Main) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	fmt.Fprint(w, c.Body)
+}
+`,
+			"component_test.go": `package {{.Package}}
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestServeHTTP(t *testing.T) {
+	c := &Main{Body: "Hello"}
+	w := httptest.NewRecorder()
+	c.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if w.Body.String() != "Hello" {
+		t.Fatalf("unexpected body: %q", w.Body.String())
+	}
+}
+`,
+		},
+	},
+	{
+		Name:        "irc",
+		Description: "IRC handler replying to a bang command",
+		Files: map[string]string{
+			"component.go": `package {{.Package}}
+
+import (
+	"github.com/manifold/tractor/pkg/manifold/library"
+	ircx "github.com/nickvanw/ircx/v2"
+	"gopkg.in/sorcix/irc.v2"
+)
+
+func init() {
+	library.Register(&Main{}, "{{.ID}}", "")
+}
+
+// Main replies to IRC messages for {{.Name}}.
+type Main struct {
+	// Command replied to, without the leading "!".
+	Command string
+
+	// Reply sent to the channel the command was sent in.
+	Reply string
+}
+
+func (c *Main) HandleMessage(s ircx.Sender, m *irc.Message) {
+	if m.Trailing() != "!"+c.Command || len(m.Params) == 0 {
+		return
+	}
+	s.Send(&irc.Message{
+		Command: irc.PRIVMSG,
+		Params:  []string{m.Params[0], c.Reply},
+	})
+}
+`,
+			"component_test.go": `package {{.Package}}
+
+import (
+	"testing"
+
+	"gopkg.in/sorcix/irc.v2"
+)
+
+type sender []*irc.Message
+
+func (s *sender) Send(m *irc.Message) error {
+	*s = append(*s, m)
+	return nil
+}
+
+func TestHandleMessage(t *testing.T) {
+	c := &Main{Command: "hello", Reply: "Hello!"}
+	s := &sender{}
+	c.HandleMessage(s, irc.ParseMessage(":nick PRIVMSG #channel :!other"))
+	c.HandleMessage(s, irc.ParseMessage(":nick PRIVMSG #channel :!hello"))
+	if len(*s) != 1 {
+		t.Fatalf("expected 1 reply, got %d", len(*s))
+	}
+	if reply := (*s)[0].Trailing(); reply != "Hello!" {
+		t.Fatalf("unexpected reply: %q", reply)
+	}
+}
+`,
+		},
+	},
+	{
+		Name:        "lifecycle",
+		Description: "Component enabled and disabled with its object",
+		Files: map[string]string{
+			"component.go": `package {{.Package}}
+
+import (
+	"log"
+
+	"github.com/manifold/tractor/pkg/manifold/library"
+)
+
+func init() {
+	library.Register(&Main{}, "{{.ID}}", "")
+}
+
+// Main is enabled when it is loaded or reloaded and disabled before it is
+// reloaded. Start resources in ComponentEnable and release them in
+// ComponentDisable.
+type Main struct {
+	enabled bool
+}
+
+func (c *Main) ComponentEnable() {
+	c.enabled = true
+	log.Println({{printf "%q" .Name}}, "enabled")
+}
+
+func (c *Main) ComponentDisable() {
+	c.enabled = false
+	log.Println({{printf "%q" .Name}}, "disabled")
+}
+`,
+			"component_test.go": `package {{.Package}}
+
+import "testing"
+
+func TestLifecycle(t *testing.T) {
+	c := &Main{}
+	c.ComponentEnable()
+	if !c.enabled {
+		t.Fatal("component not enabled")
+	}
+	c.ComponentDisable()
+	if c.enabled {
+		t.Fatal("component not disabled")
+	}
+}
+`,
+		},
+	},
+	{
+		Name:        "buttons",
+		Description: "Component with methods called from inspector buttons",
+		Files: map[string]string{
+			"component.go": `package {{.Package}}
+
+import (
+	"github.com/manifold/tractor/pkg/manifold/library"
+	"github.com/manifold/tractor/pkg/workspace/view"
+)
+
+func init() {
+	library.Register(&Main{}, "{{.ID}}", "")
+}
+
+// Main counts clicks on its inspector buttons.
+type Main struct {
+	Count int
+}
+
+// InspectorButtons returns buttons calling the methods with the same name.
+func (c *Main) InspectorButtons() []view.Button {
+	return []view.Button{
+		{Name: "Increment"},
+		{Name: "Reset"},
+	}
+}
+
+func (c *Main) Increment() {
+	c.Count++
+}
+
+func (c *Main) Reset() {
+	c.Count = 0
+}
+`,
+			"component_test.go": `package {{.Package}}
+
+import "testing"
+
+func TestButtons(t *testing.T) {
+	c := &Main{}
+	if len(c.InspectorButtons()) != 2 {
+		t.Fatal("expected 2 buttons")
+	}
+	c.Increment()
+	c.Increment()
+	if c.Count != 2 {
+		t.Fatalf("unexpected count: %d", c.Count)
+	}
+	c.Reset()
+	if c.Count != 0 {
+		t.Fatalf("unexpected count: %d", c.Count)
+	}
+}
+`,
+		},
+	},
+}
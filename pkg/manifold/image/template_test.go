@@ -0,0 +1,66 @@
+package image
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/manifold/tractor/pkg/manifold/object"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateObjectPackage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "image")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	img := New(dir)
+
+	obj := object.New("Greeter")
+	pkgDir := filepath.Join(dir, PackageDir, ObjectDir, obj.ID())
+	require.NoError(t, img.CreateObjectPackage(obj, "http"))
+	src, err := ioutil.ReadFile(filepath.Join(pkgDir, "component.go"))
+	require.NoError(t, err)
+	assert.Contains(t, string(src), `library.Register(&Main{}, "`+obj.ID()+`", "")`)
+	assert.Contains(t, string(src), "ServeHTTP")
+	assert.FileExists(t, filepath.Join(pkgDir, "component_test.go"))
+	imports, err := ioutil.ReadFile(filepath.Join(dir, PackageDir, ObjectDir, "import.go"))
+	require.NoError(t, err)
+	assert.Contains(t, string(imports), "workspace/pkg/obj/"+obj.ID())
+
+	// existing packages are left unchanged
+	require.NoError(t, img.CreateObjectPackage(obj, "irc"))
+	again, err := ioutil.ReadFile(filepath.Join(pkgDir, "component.go"))
+	require.NoError(t, err)
+	assert.Equal(t, src, again)
+
+	assert.Error(t, img.CreateObjectPackage(object.New("Other"), "missing"))
+}
+
+func TestDelegateTemplates(t *testing.T) {
+	dir, err := ioutil.TempDir("", "image")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	img := New(dir)
+
+	ts, err := img.DelegateTemplates()
+	require.NoError(t, err)
+	var names []string
+	for _, tmpl := range ts {
+		names = append(names, tmpl.Name)
+		assert.Contains(t, tmpl.Files, "component_test.go", tmpl.Name)
+	}
+	assert.Equal(t, []string{"buttons", "empty", "http", "irc", "lifecycle"}, names)
+
+	custom := filepath.Join(dir, TemplateDir, "custom")
+	require.NoError(t, os.MkdirAll(custom, 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(custom, "main.go.tmpl"), []byte("package {{.Package}} // {{.Name}}\n"), 0644))
+
+	tmpl, err := img.DelegateTemplate("custom")
+	require.NoError(t, err)
+	files, err := tmpl.Render(object.New("Custom"))
+	require.NoError(t, err)
+	assert.Equal(t, "package object // Custom", strings.TrimSpace(string(files["main.go"])))
+}
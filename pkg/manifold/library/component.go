@@ -4,7 +4,6 @@ import (
 	"fmt"
 	"path"
 	"reflect"
-	"strings"
 
 	"github.com/manifold/tractor/pkg/manifold"
 	"github.com/manifold/tractor/pkg/misc/jsonpointer"
@@ -14,13 +13,15 @@ import (
 )
 
 type component struct {
-	object  manifold.Object
-	name    string
-	id      string
-	enabled bool
-	value   interface{}
-	typed   bool
-	loaded  bool // if Reload has been called once
+	object   manifold.Object
+	name     string
+	typeName string // fully qualified name of the registered component
+	id       string
+	enabled  bool
+	value    interface{}
+	typed    bool
+	loaded   bool // if Reload has been called once
+	proxy    Proxy
 }
 
 type ComponentEnabler interface {
@@ -56,6 +57,16 @@ func NewComponent(name string, value interface{}, id string) manifold.Component
 	return newComponent(name, value, id)
 }
 
+// ComponentFromSnapshot returns a component for a snapshot. The registered
+// component is resolved by the snapshot Type if there is one, otherwise by
+// its Name for snapshots of older images.
+func ComponentFromSnapshot(snapshot manifold.ComponentSnapshot) manifold.Component {
+	com := newComponent(snapshot.Name, snapshot.Value, snapshot.ID)
+	com.typeName = snapshot.Type
+	com.enabled = snapshot.Enabled
+	return com
+}
+
 func (c *component) GetField(path string) (interface{}, reflect.Type, error) {
 	// TODO: check if field exists
 	return jsonpointer.Reflect(c.Pointer(), path), c.FieldType(path), nil
@@ -63,10 +74,19 @@ func (c *component) GetField(path string) (interface{}, reflect.Type, error) {
 
 func (c *component) SetField(path string, value interface{}) error {
 	old, _, _ := c.GetField(path)
-	if old == value {
+	if t := reflect.TypeOf(value); (t == nil || t.Comparable()) && old == value {
 		return nil
 	}
-	jsonpointer.SetReflect(c.value, path, value)
+	if err := jsonpointer.SetReflect(c.value, path, value); err != nil {
+		return err
+	}
+	if p := c.getProxy(); p != nil {
+		if err := p.SetField(c, path, value); err != nil {
+			// a failed remote change isn't kept locally
+			jsonpointer.SetReflect(c.value, path, old)
+			return err
+		}
+	}
 	notify.Send(c.object, manifold.ObjectChange{
 		Object: c.object,
 		Path:   fmt.Sprintf("%s/%s", c.name, path),
@@ -77,18 +97,19 @@ func (c *component) SetField(path string, value interface{}) error {
 }
 
 func (c *component) FieldType(path string) reflect.Type {
-	parts := strings.Split(path, "/")
-	rt := reflected.TypeOf(c.Pointer())
-	for _, part := range parts {
-		rt = rt.FieldType(part)
-	}
-	return rt.Type
+	return jsonpointer.ReflectType(reflect.TypeOf(c.Pointer()), path)
 }
 
 func (c *component) CallMethod(path string, args []interface{}, reply interface{}) error {
+	if p := c.getProxy(); p != nil {
+		return p.CallMethod(c, path, args, reply)
+	}
 	// TODO: support methods on sub paths / data structures
 	rval := reflect.ValueOf(c.Pointer())
 	method := rval.MethodByName(path)
+	if !method.IsValid() {
+		return fmt.Errorf("method not found: %s", path)
+	}
 	var params []reflect.Value
 	for _, arg := range args {
 		params = append(params, reflect.ValueOf(arg))
@@ -177,17 +198,58 @@ func (c *component) SetContainer(obj manifold.Object) {
 // TODO: rename to Value()?
 func (c *component) Pointer() interface{} {
 	if !c.typed {
-		c.value = typedComponentValue(c.value, c.name, c.id)
+		rc := c.registered()
+		c.value = typedComponentValue(c.value, c.lookupName(), rc)
+		c.proxy = rc.Proxy
+		if c.typeName == "" {
+			c.typeName = rc.Name
+		}
 		c.typed = true
 	}
 	return c.value
 }
 
+// getProxy returns the proxy of the registered component, which is resolved
+// when the component value is typed.
+func (c *component) getProxy() Proxy {
+	c.Pointer()
+	return c.proxy
+}
+
+func (c *component) registered() *RegisteredComponent {
+	if c.id != "" {
+		return LookupID(c.id)
+	}
+	return Lookup(c.lookupName())
+}
+
+func (c *component) lookupName() string {
+	if c.typeName != "" {
+		return c.typeName
+	}
+	return c.name
+}
+
+// TypeName returns the fully qualified name of the registered component com
+// is a value of. Components not typed yet return their name.
+func TypeName(com manifold.Component) string {
+	if c, ok := com.(*component); ok {
+		return c.lookupName()
+	}
+	if t := com.Type(); t != nil {
+		return QualifiedName(t)
+	}
+	return com.Name()
+}
+
 func (c *component) Type() reflect.Type {
 	return reflect.TypeOf(c.Pointer())
 }
 
 func (c *component) Reload() error {
+	if p := c.getProxy(); p != nil {
+		return c.reloadProxy(p)
+	}
 	if c.loaded && c.enabled {
 		if e, ok := c.Pointer().(ComponentDisabler); ok {
 			e.ComponentDisable()
@@ -208,6 +270,20 @@ func (c *component) Reload() error {
 	return nil
 }
 
+func (c *component) reloadProxy(p Proxy) error {
+	if c.loaded && c.enabled {
+		if err := p.Disable(c); err != nil {
+			return err
+		}
+	}
+	if err := p.Enable(c); err != nil {
+		return err
+	}
+	c.loaded = true
+	c.SetEnabled(true)
+	return nil
+}
+
 // TODO
 func (c *component) Fields() {}
 
@@ -223,10 +299,14 @@ func (c *component) Snapshot() manifold.ComponentSnapshot {
 	}
 	com := manifold.ComponentSnapshot{
 		Name:    c.name,
+		Type:    c.typeName,
 		ID:      c.id,
 		Enabled: c.enabled,
 		Value:   c.value,
 	}
+	if com.Type == "" {
+		com.Type = QualifiedName(reflect.TypeOf(c.value))
+	}
 	if c.object != nil {
 		com.ObjectID = c.object.ID()
 		com.Value, com.Refs = extractRefs(c.object, com.Name, com.Value)
@@ -245,6 +325,9 @@ func extractRefs(obj manifold.Object, basePath string, v interface{}) (out map[s
 	}
 	rt := rv.Type()
 	for _, field := range rt.Fields() {
+		if field == "_" {
+			continue
+		}
 		ft := rt.FieldType(field)
 		fieldPath := path.Join(basePath, field)
 		var subrefs []manifold.SnapshotRef
@@ -272,20 +355,11 @@ func extractRefs(obj manifold.Object, basePath string, v interface{}) (out map[s
 	return
 }
 
-func typedComponentValue(value interface{}, name, id string) interface{} {
-	var typedValue interface{}
-	if id == "" {
-		if rc := Lookup(name); rc != nil {
-			typedValue = rc.NewValue()
-		}
-	} else {
-		if rc := LookupID(id); rc != nil {
-			typedValue = rc.NewValue()
-		}
-	}
-	if typedValue == nil {
+func typedComponentValue(value interface{}, name string, rc *RegisteredComponent) interface{} {
+	if rc == nil {
 		panic("unable to find registered component: " + name)
 	}
+	typedValue := rc.NewValue()
 	if err := mapstructure.Decode(value, typedValue); err == nil {
 		return typedValue
 	} else {
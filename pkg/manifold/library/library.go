@@ -1,9 +1,12 @@
 package library
 
 import (
+	"fmt"
 	"path/filepath"
+	"reflect"
 	"runtime"
 	"strings"
+	"sync"
 
 	"github.com/manifold/tractor/pkg/manifold"
 	"github.com/progrium/prototypes/go-reflected"
@@ -11,61 +14,254 @@ import (
 
 var (
 	registered []*RegisteredComponent
+	aliases    map[string]string // alias => fully qualified name
+	explicit   map[string]bool   // aliases added with Alias
+	mu         sync.RWMutex
 )
 
+func init() {
+	aliases = make(map[string]string)
+	explicit = make(map[string]bool)
+}
+
+// DuplicateError is returned by Register when a component with the same fully
+// qualified name or ID has already been registered.
+type DuplicateError struct {
+	Name     string
+	ID       string
+	Filepath string
+	Existing *RegisteredComponent
+}
+
+func (e *DuplicateError) Error() string {
+	if e.ID != "" {
+		return fmt.Sprintf("component ID %q already registered by %s (%s)", e.ID, e.Existing.Name, e.Existing.Filepath)
+	}
+	return fmt.Sprintf("component %q already registered (%s)", e.Name, e.Existing.Filepath)
+}
+
 type RegisteredComponent struct {
 	Type     reflected.Type
+	Name     string // fully qualified name: <package path>.<type name>
 	Filepath string
 	ID       string
+	Tags     []string
+	Metadata Metadata
+	Proxy    Proxy
+
+	alias string
+}
+
+// QualifiedName returns the fully qualified name used to identify the
+// component type in the registry.
+func QualifiedName(t reflect.Type) string {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.PkgPath() == "" {
+		return t.Name()
+	}
+	return t.PkgPath() + "." + t.Name()
+}
+
+// Package returns the package path of the component type.
+func (rc *RegisteredComponent) Package() string {
+	return strings.TrimSuffix(rc.Name, "."+rc.Alias())
+}
+
+// Alias returns the short name of the component, which is the name of its type
+// unless the component was registered with another name.
+func (rc *RegisteredComponent) Alias() string {
+	if rc.alias != "" {
+		return rc.alias
+	}
+	return rc.Type.Name()
+}
+
+// HasTag returns whether the component was registered with the given tag.
+func (rc *RegisteredComponent) HasTag(tag string) bool {
+	for _, t := range rc.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
 }
 
 func (rc *RegisteredComponent) New() manifold.Component {
-	return newComponent(rc.Type.Name(), rc.NewValue(), rc.ID)
+	com := newComponent(rc.Alias(), rc.NewValue(), rc.ID)
+	com.typeName = rc.Name
+	com.proxy = rc.Proxy
+	return com
 }
 
 func (rc *RegisteredComponent) NewValue() interface{} {
 	return reflected.New(rc.Type).Interface()
 }
 
-func Register(v interface{}, id, filepath string) {
+// Register adds a component type to the library. Components are identified by
+// their fully qualified name and the short type name is kept as an alias for the
+// first component registered with it. Registering the same type or ID twice
+// returns a *DuplicateError and keeps the original registration, unless it is
+// registered during Replace.
+func Register(v interface{}, id, filepath string, tags ...string) error {
 	if filepath == "" {
 		_, filepath, _, _ = runtime.Caller(1)
 	}
-	registered = append(registered, &RegisteredComponent{
+	rc := &RegisteredComponent{
 		Type:     reflected.ValueOf(v).Type(),
 		Filepath: filepath,
 		ID:       id,
-	})
+		Tags:     tags,
+	}
+	rc.Name = QualifiedName(rc.Type.Type)
+	rc.Metadata = collectMetadata(rc)
+	return register(rc)
+}
+
+func register(rc *RegisteredComponent) error {
+	mu.Lock()
+	defer mu.Unlock()
+	if replacing {
+		for idx, existing := range registered {
+			if (rc.ID != "" && existing.ID == rc.ID) || (rc.Proxy != nil && existing.Name == rc.Name) {
+				replace(idx, rc)
+				return nil
+			}
+		}
+	}
+	for _, existing := range registered {
+		if existing.Name == rc.Name {
+			return &DuplicateError{Name: rc.Name, Filepath: rc.Filepath, Existing: existing}
+		}
+		if rc.ID != "" && existing.ID == rc.ID {
+			return &DuplicateError{Name: rc.Name, ID: rc.ID, Filepath: rc.Filepath, Existing: existing}
+		}
+	}
+	registered = append(registered, rc)
+	if _, exists := aliases[rc.Alias()]; !exists {
+		aliases[rc.Alias()] = rc.Name
+	}
+	return nil
+}
+
+// Alias adds an alias that resolves to the component registered with the fully
+// qualified name. It can be used to keep images working after a component type
+// is renamed or moved to another package.
+func Alias(alias, name string) error {
+	mu.Lock()
+	defer mu.Unlock()
+	if lookup(name) == nil {
+		return fmt.Errorf("no registered component: %s", name)
+	}
+	if existing, ok := aliases[alias]; ok && existing != name {
+		return fmt.Errorf("alias %q already used for %s", alias, existing)
+	}
+	aliases[alias] = name
+	explicit[alias] = true
+	return nil
+}
+
+// Isolate gives the caller a copy of the registry to register components in
+// until the returned function restores the previous registry. It lets tests
+// register components without affecting other tests or runs.
+func Isolate() (restore func()) {
+	mu.Lock()
+	defer mu.Unlock()
+	oldRegistered, oldAliases, oldExplicit := registered, aliases, explicit
+	registered = append([]*RegisteredComponent{}, registered...)
+	aliases = make(map[string]string, len(oldAliases))
+	for k, v := range oldAliases {
+		aliases[k] = v
+	}
+	explicit = make(map[string]bool, len(oldExplicit))
+	for k, v := range oldExplicit {
+		explicit[k] = v
+	}
+	return func() {
+		mu.Lock()
+		registered, aliases, explicit = oldRegistered, oldAliases, oldExplicit
+		mu.Unlock()
+	}
+}
+
+// Ambiguous returns the fully qualified names of all registered components
+// sharing the given short name. It returns more than one name when the alias
+// can't identify a component on its own.
+func Ambiguous(alias string) []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return ambiguous(alias)
+}
+
+func ambiguous(alias string) []string {
+	var names []string
+	for _, rc := range registered {
+		if rc.Alias() == alias {
+			names = append(names, rc.Name)
+		}
+	}
+	return names
 }
 
 // deprecated
 func Names() []string {
+	mu.RLock()
+	defer mu.RUnlock()
 	var names []string
 	for _, rc := range registered {
 		if rc.ID != "" {
 			continue
 		}
-		names = append(names, rc.Type.Name())
+		names = append(names, rc.Alias())
 	}
 	return names
 }
 
 func Registered() []*RegisteredComponent {
+	mu.RLock()
+	defer mu.RUnlock()
 	r := make([]*RegisteredComponent, len(registered))
 	copy(r, registered)
 	return r
 }
 
+// Lookup returns the component registered with the fully qualified name, or
+// resolves the name as an alias if there is none. A short name shared by
+// several components resolves to none of them unless it was added with Alias.
 func Lookup(name string) *RegisteredComponent {
+	mu.RLock()
+	defer mu.RUnlock()
+	return lookup(name)
+}
+
+func lookup(name string) *RegisteredComponent {
 	for _, rc := range registered {
-		if rc.Type.Name() == name {
+		if rc.Name == name {
 			return rc
 		}
 	}
+	if qualified, ok := aliases[name]; ok && qualified != name {
+		if !explicit[name] && len(ambiguous(name)) > 1 {
+			return nil
+		}
+		return lookup(qualified)
+	}
 	return nil
 }
 
+// LookupType returns the component registered for the given type, which can
+// either be the struct type or a pointer to it.
+func LookupType(t reflect.Type) *RegisteredComponent {
+	if t == nil {
+		return nil
+	}
+	return Lookup(QualifiedName(t))
+}
+
 func LookupID(id string) *RegisteredComponent {
+	mu.RLock()
+	defer mu.RUnlock()
 	for _, rc := range registered {
 		if rc.ID == id {
 			return rc
@@ -74,11 +270,52 @@ func LookupID(id string) *RegisteredComponent {
 	return nil
 }
 
+// Query filters registered components in Search. Empty fields match everything.
+type Query struct {
+	// Package matches the package path of the component or any of its parents.
+	Package string
+
+	// Implements matches components whose pointer type implements the interface.
+	Implements reflect.Type
+
+	// Tags matches components registered with all of the tags.
+	Tags []string
+
+	// IncludeDelegates includes components registered with an ID.
+	IncludeDelegates bool
+}
+
+// Search returns the registered components matching the query.
+func Search(q Query) (results []*RegisteredComponent) {
+	for _, rc := range Registered() {
+		if rc.ID != "" && !q.IncludeDelegates {
+			continue
+		}
+		if q.Package != "" && rc.Package() != q.Package && !strings.HasPrefix(rc.Package(), q.Package+"/") {
+			continue
+		}
+		if q.Implements != nil && !reflect.PtrTo(rc.Type.Type).Implements(q.Implements) {
+			continue
+		}
+		matched := true
+		for _, tag := range q.Tags {
+			if !rc.HasTag(tag) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			results = append(results, rc)
+		}
+	}
+	return
+}
+
 func Related(c *RegisteredComponent) (related []*RegisteredComponent) {
 	if c == nil {
 		return
 	}
-	for _, rc := range registered {
+	for _, rc := range Registered() {
 		if rc.Type == c.Type {
 			continue
 		}
@@ -0,0 +1,86 @@
+package library
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/progrium/prototypes/go-reflected"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type registryComponent struct{}
+
+type taggedComponent struct{}
+
+func (c *taggedComponent) Echo(args ...string) []string {
+	return args
+}
+
+type echoer interface {
+	Echo(args ...string) []string
+}
+
+func TestRegistry(t *testing.T) {
+	defer Isolate()()
+	require.NoError(t, Register(&registryComponent{}, "", ""))
+	require.NoError(t, Register(&taggedComponent{}, "", "", "test", "echo"))
+
+	t.Run("QualifiedName", func(t *testing.T) {
+		name := QualifiedName(reflect.TypeOf(&registryComponent{}))
+		assert.Equal(t, "github.com/manifold/tractor/pkg/manifold/library.registryComponent", name)
+		assert.Equal(t, name, QualifiedName(reflect.TypeOf(registryComponent{})))
+	})
+
+	t.Run("Lookup", func(t *testing.T) {
+		rc := Lookup("github.com/manifold/tractor/pkg/manifold/library.registryComponent")
+		require.NotNil(t, rc)
+		assert.Equal(t, rc, Lookup("registryComponent"))
+		assert.Equal(t, rc, LookupType(reflect.TypeOf(&registryComponent{})))
+		assert.Nil(t, Lookup("nope"))
+	})
+
+	t.Run("Duplicate", func(t *testing.T) {
+		err := Register(&registryComponent{}, "", "")
+		require.Error(t, err)
+		dup, ok := err.(*DuplicateError)
+		require.True(t, ok)
+		assert.Equal(t, Lookup("registryComponent"), dup.Existing)
+		assert.Equal(t, []string{dup.Name}, Ambiguous("registryComponent"))
+	})
+
+	t.Run("Alias", func(t *testing.T) {
+		name := Lookup("registryComponent").Name
+		require.NoError(t, Alias("OldComponent", name))
+		assert.Equal(t, name, Lookup("OldComponent").Name)
+		assert.Error(t, Alias("OldComponent", Lookup("taggedComponent").Name))
+		assert.Error(t, Alias("Missing", "example.com/missing.Component"))
+	})
+
+	t.Run("Search", func(t *testing.T) {
+		tagged := Lookup("taggedComponent")
+		assert.Equal(t, []*RegisteredComponent{tagged}, Search(Query{Tags: []string{"test", "echo"}}))
+		assert.Empty(t, Search(Query{Tags: []string{"test", "missing"}}))
+		assert.Equal(t, []*RegisteredComponent{tagged}, Search(Query{
+			Implements: reflect.TypeOf((*echoer)(nil)).Elem(),
+		}))
+		assert.Len(t, Search(Query{Package: "github.com/manifold/tractor/pkg/manifold"}), 2)
+		assert.Empty(t, Search(Query{Package: "github.com/manifold/tractor/pkg/manifold/lib"}))
+	})
+
+	t.Run("Ambiguous", func(t *testing.T) {
+		rc := Lookup("registryComponent")
+		other := &RegisteredComponent{
+			Type:  reflected.ValueOf(&taggedComponent{}).Type(),
+			Name:  "example.com/other.registryComponent",
+			alias: "registryComponent",
+		}
+		require.NoError(t, register(other))
+		assert.Equal(t, []string{rc.Name, other.Name}, Ambiguous("registryComponent"))
+		assert.Nil(t, Lookup("registryComponent"))
+		assert.Equal(t, rc, Lookup(rc.Name))
+		assert.Equal(t, other, Lookup(other.Name))
+		// an explicit alias still resolves
+		assert.Equal(t, rc, Lookup("OldComponent"))
+	})
+}
@@ -0,0 +1,175 @@
+package library
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path"
+	"reflect"
+	"strings"
+)
+
+// TagKey is the struct tag key used for component and field metadata.
+const TagKey = "tractor"
+
+// Metadata describes a registered component for the inspector and component
+// palette. Descriptions come from doc comments in the component source and
+// the rest from `tractor` struct tags. Component level tags are set on a blank
+// field:
+//
+//	type Server struct {
+//		_ struct{} `tractor:"category=HTTP,icon=server,name=HTTP Server"`
+//
+//		// Listener to accept connections on.
+//		Listener net.Listener `tractor:"help=Listener to serve on"`
+//	}
+type Metadata struct {
+	DisplayName string
+	Description string
+	Category    string
+	Icon        string
+	Fields      map[string]FieldMetadata
+}
+
+// FieldMetadata describes an exported field of a registered component.
+type FieldMetadata struct {
+	DisplayName string
+	Description string
+	Tags        map[string]string
+}
+
+// Field returns the metadata for the named field. Fields without metadata get
+// their name as display name.
+func (m Metadata) Field(name string) FieldMetadata {
+	if fm, ok := m.Fields[name]; ok {
+		return fm
+	}
+	return FieldMetadata{DisplayName: name}
+}
+
+// ParseTag parses a `tractor` struct tag value into keys and values. Options
+// are separated by commas and may have a value after an equal sign, for
+// example `hidden` or `name=Address,help=Address to listen on`.
+func ParseTag(tag string) map[string]string {
+	opts := make(map[string]string)
+	for _, opt := range strings.Split(tag, ",") {
+		opt = strings.TrimSpace(opt)
+		if opt == "" {
+			continue
+		}
+		parts := strings.SplitN(opt, "=", 2)
+		if len(parts) == 1 {
+			opts[parts[0]] = ""
+			continue
+		}
+		opts[parts[0]] = parts[1]
+	}
+	return opts
+}
+
+// FieldTags returns the parsed `tractor` tag of the named struct field.
+func FieldTags(t reflect.Type, field string) map[string]string {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return map[string]string{}
+	}
+	sf, ok := t.FieldByName(field)
+	if !ok {
+		return map[string]string{}
+	}
+	return ParseTag(sf.Tag.Get(TagKey))
+}
+
+func collectMetadata(rc *RegisteredComponent) Metadata {
+	m := Metadata{
+		DisplayName: rc.Alias(),
+		Category:    path.Base(rc.Package()),
+		Fields:      make(map[string]FieldMetadata),
+	}
+
+	typeDoc, fieldDocs := parseDocs(rc.Filepath, rc.Alias())
+	m.Description = typeDoc
+
+	if rc.Type.Kind() != reflect.Struct {
+		return m
+	}
+	for i := 0; i < rc.Type.NumField(); i++ {
+		sf := rc.Type.Field(i)
+		tags := ParseTag(sf.Tag.Get(TagKey))
+		if sf.Name == "_" {
+			if v, ok := tags["name"]; ok {
+				m.DisplayName = v
+			}
+			if v, ok := tags["category"]; ok {
+				m.Category = v
+			}
+			if v, ok := tags["icon"]; ok {
+				m.Icon = v
+			}
+			if v, ok := tags["help"]; ok {
+				m.Description = v
+			}
+			continue
+		}
+		if sf.PkgPath != "" {
+			continue
+		}
+		fm := FieldMetadata{
+			DisplayName: sf.Name,
+			Description: fieldDocs[sf.Name],
+			Tags:        tags,
+		}
+		if v, ok := tags["name"]; ok {
+			fm.DisplayName = v
+		}
+		if v, ok := tags["help"]; ok {
+			fm.Description = v
+		}
+		m.Fields[sf.Name] = fm
+	}
+	return m
+}
+
+// parseDocs returns the doc comments of the named struct type and its fields
+// found in the Go source file. Missing or unparseable files give no docs.
+func parseDocs(filename, typeName string) (string, map[string]string) {
+	fields := make(map[string]string)
+	if filename == "" {
+		return "", fields
+	}
+	f, err := parser.ParseFile(token.NewFileSet(), filename, nil, parser.ParseComments)
+	if err != nil {
+		return "", fields
+	}
+	for _, decl := range f.Decls {
+		gd, ok := decl.(*ast.GenDecl)
+		if !ok || gd.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range gd.Specs {
+			ts, ok := spec.(*ast.TypeSpec)
+			if !ok || ts.Name.Name != typeName {
+				continue
+			}
+			doc := ts.Doc
+			if doc == nil && len(gd.Specs) == 1 {
+				doc = gd.Doc
+			}
+			if st, ok := ts.Type.(*ast.StructType); ok {
+				for _, field := range st.Fields.List {
+					text := field.Doc.Text()
+					if text == "" {
+						text = field.Comment.Text()
+					}
+					for _, name := range field.Names {
+						fields[name.Name] = strings.TrimSpace(text)
+					}
+				}
+			}
+			return strings.TrimSpace(doc.Text()), fields
+		}
+	}
+	return "", fields
+}
@@ -0,0 +1,62 @@
+package library
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// documentedComponent is used to test metadata collection.
+type documentedComponent struct {
+	_ struct{} `tractor:"category=Testing,icon=flask,name=Documented"`
+
+	// Addr is the address to use.
+	Addr string
+
+	Timeout int `tractor:"name=Timeout (ms),help=How long to wait"`
+
+	Secret string `tractor:"hidden"` // never shown
+
+	internal string
+}
+
+func TestParseTag(t *testing.T) {
+	assert.Equal(t, map[string]string{}, ParseTag(""))
+	assert.Equal(t, map[string]string{"hidden": ""}, ParseTag("hidden"))
+	assert.Equal(t, map[string]string{
+		"hidden": "",
+		"name":   "Listen Address",
+		"help":   "a=b",
+	}, ParseTag("hidden, name=Listen Address,help=a=b"))
+}
+
+func TestMetadata(t *testing.T) {
+	defer Isolate()()
+	require.NoError(t, Register(&documentedComponent{}, "", ""))
+	meta := Lookup("documentedComponent").Metadata
+
+	assert.Equal(t, "Documented", meta.DisplayName)
+	assert.Equal(t, "documentedComponent is used to test metadata collection.", meta.Description)
+	assert.Equal(t, "Testing", meta.Category)
+	assert.Equal(t, "flask", meta.Icon)
+
+	assert.Equal(t, FieldMetadata{
+		DisplayName: "Addr",
+		Description: "Addr is the address to use.",
+		Tags:        map[string]string{},
+	}, meta.Field("Addr"))
+	assert.Equal(t, "Timeout (ms)", meta.Field("Timeout").DisplayName)
+	assert.Equal(t, "How long to wait", meta.Field("Timeout").Description)
+	assert.Equal(t, "never shown", meta.Field("Secret").Description)
+	assert.Contains(t, meta.Field("Secret").Tags, "hidden")
+	assert.NotContains(t, meta.Fields, "internal")
+	assert.NotContains(t, meta.Fields, "_")
+
+	t.Run("missing source", func(t *testing.T) {
+		rc := &RegisteredComponent{Type: Lookup("documentedComponent").Type, Filepath: "/nonexistent.go"}
+		meta := collectMetadata(rc)
+		assert.Equal(t, "", meta.Description)
+		assert.Equal(t, "Documented", meta.DisplayName)
+	})
+}
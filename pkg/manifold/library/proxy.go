@@ -0,0 +1,54 @@
+package library
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/manifold/tractor/pkg/manifold"
+	reflected "github.com/progrium/prototypes/go-reflected"
+)
+
+// Proxy implements the behavior of components that aren't backed by Go methods,
+// such as components running in another process. Field values are still kept
+// in a struct value on the component so they can be inspected and snapshotted,
+// but changes, method calls and lifecycle are passed on to the proxy.
+type Proxy interface {
+	// SetField is called after a field of the component value was set. The
+	// field is set back to its old value if it returns an error.
+	SetField(com manifold.Component, path string, value interface{}) error
+
+	// CallMethod calls the named method of the component.
+	CallMethod(com manifold.Component, method string, args []interface{}, reply interface{}) error
+
+	// Enable is called when the component is reloaded.
+	Enable(com manifold.Component) error
+
+	// Disable is called before a loaded component is enabled again.
+	Disable(com manifold.Component) error
+}
+
+// RegisterProxy adds a component type implemented by a proxy. The name must be
+// fully qualified as <package>.<type> and the type part is used as its alias.
+// The type is the struct type used for component values, which is typically
+// built with reflect.StructOf. During Replace it replaces the proxy component
+// registered with the same name.
+func RegisterProxy(name string, typ reflect.Type, proxy Proxy, filepath string, tags ...string) error {
+	idx := strings.LastIndex(name, ".")
+	if idx < 1 || idx == len(name)-1 {
+		return fmt.Errorf("proxy component name must be <package>.<type>: %s", name)
+	}
+	if typ.Kind() != reflect.Struct {
+		return fmt.Errorf("proxy component type must be a struct: %s", typ)
+	}
+	rc := &RegisteredComponent{
+		Type:     reflected.Type{Type: typ},
+		Name:     name,
+		Filepath: filepath,
+		Tags:     tags,
+		Proxy:    proxy,
+		alias:    name[idx+1:],
+	}
+	rc.Metadata = collectMetadata(rc)
+	return register(rc)
+}
@@ -0,0 +1,82 @@
+package library
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/manifold/tractor/pkg/manifold"
+	"github.com/mitchellh/mapstructure"
+)
+
+var (
+	replacing bool
+	replaced  []*RegisteredComponent
+	replaceMu sync.Mutex
+)
+
+// Replace calls fn and lets components registered with an ID during the call
+// replace the component registered with the same ID instead of returning a
+// *DuplicateError. This is used to load new versions of delegate packages at
+// runtime. Replacements keep the name and filepath of the component they
+// replace. It returns the registered components that replaced another.
+func Replace(fn func() error) ([]*RegisteredComponent, error) {
+	replaceMu.Lock()
+	defer replaceMu.Unlock()
+
+	mu.Lock()
+	replacing = true
+	replaced = nil
+	mu.Unlock()
+
+	err := fn()
+
+	mu.Lock()
+	rcs := replaced
+	replacing = false
+	replaced = nil
+	mu.Unlock()
+	return rcs, err
+}
+
+// replace swaps the registered component at idx for rc. It must be called
+// with mu held.
+func replace(idx int, rc *RegisteredComponent) {
+	existing := registered[idx]
+	rc.Name = existing.Name
+	rc.Filepath = existing.Filepath
+	rc.alias = existing.Alias()
+	rc.Metadata = collectMetadata(rc)
+	registered[idx] = rc
+	replaced = append(replaced, rc)
+}
+
+// Swap replaces the value of a component with a new value of the registered
+// component, carrying over its state through its snapshot. The old value is
+// disabled if the component is enabled. Fields referencing other objects are
+// left unset and the component has to be reloaded after.
+func Swap(com manifold.Component, rc *RegisteredComponent) error {
+	c, ok := com.(*component)
+	if !ok {
+		return fmt.Errorf("unable to swap component: %s", com.Name())
+	}
+	c.Pointer()
+	snapshot := c.Snapshot()
+	value := rc.NewValue()
+	if err := mapstructure.Decode(snapshot.Value, value); err != nil {
+		return err
+	}
+	if c.enabled {
+		if c.proxy != nil {
+			if err := c.proxy.Disable(c); err != nil {
+				return err
+			}
+		} else if d, ok := c.value.(ComponentDisabler); ok {
+			d.ComponentDisable()
+		}
+	}
+	c.value = value
+	c.typeName = rc.Name
+	c.proxy = rc.Proxy
+	c.loaded = false
+	return nil
+}
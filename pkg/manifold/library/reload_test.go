@@ -0,0 +1,58 @@
+package library
+
+import (
+	"testing"
+
+	"github.com/manifold/tractor/pkg/manifold/object"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type delegateV1 struct {
+	Greeting string
+	disabled bool
+}
+
+func (d *delegateV1) ComponentDisable() {
+	d.disabled = true
+}
+
+type delegateV2 struct {
+	Greeting string
+	Times    int
+}
+
+func TestReplace(t *testing.T) {
+	defer Isolate()()
+	require.NoError(t, Register(&delegateV1{}, "delegate", "/obj/delegate/component.go"))
+	old := LookupID("delegate")
+
+	obj := object.New("obj")
+	com := old.New()
+	obj.AppendComponent(com)
+	require.NoError(t, com.SetField("Greeting", "hello"))
+	v1 := com.Pointer().(*delegateV1)
+
+	assert.Error(t, Register(&delegateV2{}, "delegate", ""))
+	rcs, err := Replace(func() error {
+		return Register(&delegateV2{}, "delegate", "")
+	})
+	require.NoError(t, err)
+	require.Len(t, rcs, 1)
+
+	rc := LookupID("delegate")
+	assert.Equal(t, rcs[0], rc)
+	assert.Equal(t, rc, Lookup(old.Name))
+	assert.Equal(t, old.Name, rc.Name)
+	assert.Equal(t, old.Filepath, rc.Filepath)
+	assert.Equal(t, "delegateV1", rc.Alias())
+
+	require.NoError(t, Swap(com, rc))
+	assert.True(t, v1.disabled)
+	v2, ok := com.Pointer().(*delegateV2)
+	require.True(t, ok)
+	assert.Equal(t, "hello", v2.Greeting)
+	assert.Equal(t, old.Name, com.Snapshot().Type)
+
+	assert.Error(t, Register(&delegateV1{}, "delegate", ""))
+}
@@ -0,0 +1,171 @@
+// Package auth issues and verifies the tokens clients authenticate with to the
+// agent and workspace daemons.
+//
+// A token is the base64 encoded JSON of its Claims followed by an HMAC-SHA256
+// signature, separated by a dot. The key is kept by the agent and passed to the
+// workspace daemons it starts, so tokens issued by the agent are accepted by
+// every workspace.
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// KeyFileEnv is the environment variable with the path to the key file.
+	KeyFileEnv = "TRACTOR_AUTH_KEY_FILE"
+
+	// WorkspaceEnv is the environment variable with the name of the workspace
+	// a daemon serves, which tokens for a single workspace are checked against.
+	WorkspaceEnv = "TRACTOR_WORKSPACE"
+
+	keySize = 32
+)
+
+var (
+	ErrInvalidToken  = errors.New("invalid token")
+	ErrExpiredToken  = errors.New("token expired")
+	ErrExchangeToken = errors.New("token can only be exchanged")
+)
+
+// Role is the permission level of a client. Every role allows what the roles
+// below it allow.
+type Role string
+
+const (
+	Viewer  Role = "viewer"  // reads the workspace
+	Invoker Role = "invoker" // calls component methods
+	Editor  Role = "editor"  // changes the workspace
+)
+
+var levels = map[Role]int{
+	Viewer:  1,
+	Invoker: 2,
+	Editor:  3,
+}
+
+// Valid returns if r is a known role.
+func (r Role) Valid() bool {
+	return levels[r] > 0
+}
+
+// Allows returns if r includes the permissions of the required role.
+func (r Role) Allows(required Role) bool {
+	return r.Valid() && levels[r] >= levels[required]
+}
+
+// Claims are the permissions granted by a token.
+type Claims struct {
+	Role      Role   `json:"role"`
+	Workspace string `json:"workspace,omitempty"` // empty for every workspace
+	Expires   int64  `json:"exp,omitempty"`       // unix time, zero if the token does not expire
+
+	// Exchange marks a token that can't be used to authenticate, only be
+	// exchanged once for a token with the same role, see VerifyExchange.
+	// Tokens put in URLs are exchange tokens with a short expiry.
+	Exchange bool `json:"xchg,omitempty"`
+}
+
+// AllowsWorkspace returns if the claims grant access to the named workspace.
+func (c Claims) AllowsWorkspace(name string) bool {
+	return c.Workspace == "" || c.Workspace == name
+}
+
+// Issue returns a token for the claims signed with key.
+func Issue(key []byte, c Claims) (string, error) {
+	if !c.Role.Valid() {
+		return "", fmt.Errorf("unknown role: %q", c.Role)
+	}
+	b, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	payload := base64.RawURLEncoding.EncodeToString(b)
+	return payload + "." + base64.RawURLEncoding.EncodeToString(sign(key, payload)), nil
+}
+
+// Verify checks the signature and expiry of a token and returns its claims.
+// Exchange tokens return ErrExchangeToken.
+func Verify(key []byte, token string) (Claims, error) {
+	c, err := verify(key, token)
+	if err == nil && c.Exchange {
+		return c, ErrExchangeToken
+	}
+	return c, err
+}
+
+// VerifyExchange checks the signature and expiry of an exchange token and
+// returns its claims. Other tokens return ErrInvalidToken.
+func VerifyExchange(key []byte, token string) (Claims, error) {
+	c, err := verify(key, token)
+	if err == nil && !c.Exchange {
+		return c, ErrInvalidToken
+	}
+	return c, err
+}
+
+func verify(key []byte, token string) (Claims, error) {
+	var c Claims
+	parts := strings.Split(token, ".")
+	if len(parts) != 2 {
+		return c, ErrInvalidToken
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil || !hmac.Equal(sig, sign(key, parts[0])) {
+		return c, ErrInvalidToken
+	}
+	b, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return c, ErrInvalidToken
+	}
+	if err := json.Unmarshal(b, &c); err != nil || !c.Role.Valid() {
+		return c, ErrInvalidToken
+	}
+	if c.Expires != 0 && time.Now().Unix() >= c.Expires {
+		return c, ErrExpiredToken
+	}
+	return c, nil
+}
+
+// LoadKey reads the key from path. A new key is generated and written to path
+// if it does not exist yet.
+func LoadKey(path string) ([]byte, error) {
+	key, err := ioutil.ReadFile(path)
+	if err == nil {
+		if len(key) < keySize {
+			return nil, fmt.Errorf("auth key too short: %s", path)
+		}
+		return key, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+	key = make([]byte, keySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(path, key, 0600); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func sign(key []byte, payload string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
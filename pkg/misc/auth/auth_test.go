@@ -0,0 +1,87 @@
+package auth
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoleAllows(t *testing.T) {
+	assert.True(t, Editor.Allows(Viewer))
+	assert.True(t, Editor.Allows(Invoker))
+	assert.True(t, Invoker.Allows(Viewer))
+	assert.True(t, Viewer.Allows(Viewer))
+	assert.False(t, Viewer.Allows(Invoker))
+	assert.False(t, Invoker.Allows(Editor))
+	assert.False(t, Role("").Allows(Viewer))
+	assert.False(t, Role("admin").Allows(Viewer))
+}
+
+func TestIssueVerify(t *testing.T) {
+	key := []byte("0123456789abcdef0123456789abcdef")
+	token, err := Issue(key, Claims{Role: Invoker, Workspace: "ws"})
+	require.NoError(t, err)
+
+	c, err := Verify(key, token)
+	require.NoError(t, err)
+	assert.Equal(t, Claims{Role: Invoker, Workspace: "ws"}, c)
+	assert.True(t, c.AllowsWorkspace("ws"))
+	assert.False(t, c.AllowsWorkspace("other"))
+
+	_, err = Verify([]byte("another key another key another k"), token)
+	assert.Equal(t, ErrInvalidToken, err)
+	_, err = Verify(key, "x"+token)
+	assert.Equal(t, ErrInvalidToken, err)
+	_, err = Verify(key, "")
+	assert.Equal(t, ErrInvalidToken, err)
+
+	token, err = Issue(key, Claims{Role: Viewer, Expires: time.Now().Add(-time.Second).Unix()})
+	require.NoError(t, err)
+	_, err = Verify(key, token)
+	assert.Equal(t, ErrExpiredToken, err)
+
+	_, err = Issue(key, Claims{Role: "admin"})
+	assert.Error(t, err)
+}
+
+func TestVerifyExchange(t *testing.T) {
+	key := []byte("0123456789abcdef0123456789abcdef")
+	claims := Claims{Role: Editor, Workspace: "ws", Expires: time.Now().Add(time.Minute).Unix(), Exchange: true}
+	token, err := Issue(key, claims)
+	require.NoError(t, err)
+
+	_, err = Verify(key, token)
+	assert.Equal(t, ErrExchangeToken, err)
+	c, err := VerifyExchange(key, token)
+	require.NoError(t, err)
+	assert.Equal(t, claims, c)
+
+	token, err = Issue(key, Claims{Role: Editor})
+	require.NoError(t, err)
+	_, err = VerifyExchange(key, token)
+	assert.Equal(t, ErrInvalidToken, err)
+}
+
+func TestLoadKey(t *testing.T) {
+	dir, err := ioutil.TempDir("", "auth")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "tractor", "auth.key")
+	key, err := LoadKey(path)
+	require.NoError(t, err)
+	assert.Len(t, key, keySize)
+
+	fi, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), fi.Mode().Perm())
+
+	again, err := LoadKey(path)
+	require.NoError(t, err)
+	assert.Equal(t, key, again)
+}
@@ -0,0 +1,162 @@
+// Package config loads the TOML configuration files of the agent and the
+// workspaces.
+//
+// A configuration is a struct holding the defaults. Load decodes a file over
+// it, then applies the environment variables named by the env tags of its
+// fields, and validates it if it implements Validator. Keys the struct doesn't
+// know are an error, so typos don't go unnoticed:
+//
+//	type Config struct {
+//		Addr    string          `toml:"addr" env:"TRACTOR_ADDR"`
+//		Timeout config.Duration `toml:"timeout"`
+//	}
+package config
+
+import (
+	"encoding"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+// PathEnv is the environment variable with the agent path used instead of
+// ~/.tractor when none is given.
+const PathEnv = "TRACTOR_PATH"
+
+// Validator is implemented by configurations that check their values after
+// they were loaded.
+type Validator interface {
+	Validate() error
+}
+
+// Duration is a time.Duration written as a string like "1m30s" in files and
+// environment variables.
+type Duration time.Duration
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	v, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+// Load decodes the file at path into v, a pointer to a struct holding the
+// defaults, and applies the environment overrides. A missing file leaves the
+// defaults. The configuration is validated last.
+func Load(path string, v interface{}) error {
+	return load(path, v, true)
+}
+
+// LoadFile is Load without the environment overrides, for loading the
+// configuration of another program, whose environment is not ours.
+func LoadFile(path string, v interface{}) error {
+	return load(path, v, false)
+}
+
+func load(path string, v interface{}, env bool) error {
+	md, err := toml.DecodeFile(path, v)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("config: %s: %v", path, err)
+	}
+	if undecoded := md.Undecoded(); len(undecoded) > 0 {
+		return fmt.Errorf("config: %s: unknown key %q", path, undecoded[0].String())
+	}
+	if env {
+		if err := ApplyEnv(v); err != nil {
+			return err
+		}
+	}
+	if validator, ok := v.(Validator); ok {
+		if err := validator.Validate(); err != nil {
+			return fmt.Errorf("config: %s: %v", path, err)
+		}
+	}
+	return nil
+}
+
+// ApplyEnv sets the fields of the struct v points to, and of its nested
+// structs, to the environment variables named by their env tags, if set.
+// Lists are separated by commas.
+func ApplyEnv(v interface{}) error {
+	return applyEnv(reflect.ValueOf(v).Elem())
+}
+
+func applyEnv(v reflect.Value) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := v.Field(i)
+		if t.Field(i).PkgPath != "" {
+			continue
+		}
+		name := t.Field(i).Tag.Get("env")
+		if name == "" {
+			if field.Kind() == reflect.Struct {
+				if err := applyEnv(field); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+		if err := setField(field, value); err != nil {
+			return fmt.Errorf("config: %s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+var textUnmarshaler = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+func setField(field reflect.Value, value string) error {
+	if field.Addr().Type().Implements(textUnmarshaler) {
+		return field.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(value))
+	}
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		field.SetInt(n)
+	case reflect.Slice:
+		if field.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported type %s", field.Type())
+		}
+		var items []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		field.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported type %s", field.Type())
+	}
+	return nil
+}
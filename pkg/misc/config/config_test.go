@@ -0,0 +1,93 @@
+package config
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testConfig struct {
+	Name     string   `toml:"name" env:"TRACTOR_TEST_NAME"`
+	Interval Duration `toml:"interval" env:"TRACTOR_TEST_INTERVAL"`
+	Size     int      `toml:"size"`
+	Nested   struct {
+		Enabled bool     `toml:"enabled" env:"TRACTOR_TEST_ENABLED"`
+		Items   []string `toml:"items" env:"TRACTOR_TEST_ITEMS"`
+	} `toml:"nested"`
+}
+
+func (c *testConfig) Validate() error {
+	if c.Size < 0 {
+		return errors.New("size must not be negative")
+	}
+	return nil
+}
+
+func writeConfig(t *testing.T, dir, content string) string {
+	path := filepath.Join(dir, "config.toml")
+	require.NoError(t, ioutil.WriteFile(path, []byte(content), 0644))
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tractor-pkg-misc-config")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	t.Run("defaults", func(t *testing.T) {
+		c := testConfig{Name: "default", Size: 3}
+		require.NoError(t, Load(filepath.Join(dir, "missing.toml"), &c))
+		assert.Equal(t, "default", c.Name)
+		assert.Equal(t, 3, c.Size)
+	})
+
+	t.Run("file", func(t *testing.T) {
+		c := testConfig{Name: "default", Size: 3}
+		path := writeConfig(t, dir, "interval = \"2s\"\nsize = 5\n\n[nested]\nitems = [\"a\", \"b\"]\n")
+		require.NoError(t, Load(path, &c))
+		assert.Equal(t, "default", c.Name)
+		assert.Equal(t, Duration(2*time.Second), c.Interval)
+		assert.Equal(t, 5, c.Size)
+		assert.Equal(t, []string{"a", "b"}, c.Nested.Items)
+	})
+
+	t.Run("env", func(t *testing.T) {
+		os.Setenv("TRACTOR_TEST_NAME", "env")
+		os.Setenv("TRACTOR_TEST_INTERVAL", "1m")
+		os.Setenv("TRACTOR_TEST_ENABLED", "true")
+		os.Setenv("TRACTOR_TEST_ITEMS", "c, d,")
+		defer func() {
+			for _, name := range []string{"TRACTOR_TEST_NAME", "TRACTOR_TEST_INTERVAL", "TRACTOR_TEST_ENABLED", "TRACTOR_TEST_ITEMS"} {
+				os.Unsetenv(name)
+			}
+		}()
+		var c testConfig
+		path := writeConfig(t, dir, "name = \"file\"\n\n[nested]\nitems = [\"a\"]\n")
+		require.NoError(t, Load(path, &c))
+		assert.Equal(t, "env", c.Name)
+		assert.Equal(t, Duration(time.Minute), c.Interval)
+		assert.True(t, c.Nested.Enabled)
+		assert.Equal(t, []string{"c", "d"}, c.Nested.Items)
+
+		os.Setenv("TRACTOR_TEST_ENABLED", "maybe")
+		assert.EqualError(t, Load(path, &c), "config: TRACTOR_TEST_ENABLED: strconv.ParseBool: parsing \"maybe\": invalid syntax")
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		var c testConfig
+		path := writeConfig(t, dir, "nmae = \"typo\"\n")
+		assert.EqualError(t, Load(path, &c), "config: "+path+": unknown key \"nmae\"")
+
+		path = writeConfig(t, dir, "size = -1\n")
+		assert.EqualError(t, Load(path, &c), "config: "+path+": size must not be negative")
+
+		path = writeConfig(t, dir, "interval = \"soon\"\n")
+		assert.Error(t, Load(path, &c))
+	})
+}
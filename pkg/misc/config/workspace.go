@@ -0,0 +1,137 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// WorkspaceFile is the name of the configuration file in a workspace
+// directory.
+const WorkspaceFile = "tractor.toml"
+
+// Workspace is the configuration of a workspace. Settings left empty use the
+// settings of the agent.
+type Workspace struct {
+	Daemon        Daemon `toml:"daemon"`
+	Watch         Watch  `toml:"watch"`
+	ConsoleBuffer int    `toml:"console_buffer"` // bytes of recent output kept by the agent
+
+	// Profile is the profile the agent starts the daemon with, empty for
+	// none.
+	Profile  string             `toml:"profile"`
+	Profiles map[string]Profile `toml:"profiles"`
+}
+
+// Profile is a set of environment variables the agent adds to the
+// environment of the daemon, like the settings of a dev or prod deployment.
+type Profile struct {
+	Env map[string]string `toml:"env"`
+	// Secrets maps variables to the names of secrets in the secrets file of
+	// the agent.
+	Secrets map[string]string `toml:"secrets"`
+}
+
+// Daemon configures the workspace daemon when it is run without the agent,
+// which passes its own flags.
+type Daemon struct {
+	Addr  string `toml:"addr" env:"TRACTOR_DAEMON_ADDR"`
+	Proto string `toml:"proto" env:"TRACTOR_DAEMON_PROTO"` // "websocket" or "unix"
+	HTTP  string `toml:"http" env:"TRACTOR_DAEMON_HTTP"`   // REST gateway address, disabled if empty
+}
+
+// Watch configures which changed files make the agent reload a workspace.
+type Watch struct {
+	Extensions []string `toml:"extensions"` // like ".go"
+	Ignore     []string `toml:"ignore"`     // substrings of paths to ignore
+	Interval   Duration `toml:"interval"`   // between polls for changes
+}
+
+var envName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// DefaultWorkspace returns the configuration of workspaces without a
+// tractor.toml.
+func DefaultWorkspace() Workspace {
+	return Workspace{
+		Daemon: Daemon{
+			Addr:  "localhost:4243",
+			Proto: "websocket",
+		},
+	}
+}
+
+// LoadWorkspace loads the tractor.toml of the workspace directory over the
+// defaults.
+func LoadWorkspace(dir string) (Workspace, error) {
+	c := DefaultWorkspace()
+	err := Load(filepath.Join(dir, WorkspaceFile), &c)
+	return c, err
+}
+
+// LoadWorkspaceFile loads the tractor.toml of the workspace directory over
+// the defaults without the environment overrides, as the agent does for the
+// workspaces it runs.
+func LoadWorkspaceFile(dir string) (Workspace, error) {
+	c := DefaultWorkspace()
+	err := LoadFile(filepath.Join(dir, WorkspaceFile), &c)
+	return c, err
+}
+
+func (c *Workspace) Validate() error {
+	if c.Daemon.Addr == "" {
+		return errors.New("daemon.addr must not be empty")
+	}
+	if c.Daemon.Proto != "websocket" && c.Daemon.Proto != "unix" {
+		return fmt.Errorf("daemon.proto must be websocket or unix, not %q", c.Daemon.Proto)
+	}
+	if c.ConsoleBuffer < 0 {
+		return errors.New("console_buffer must not be negative")
+	}
+	if _, ok := c.Profiles[c.Profile]; c.Profile != "" && !ok {
+		return fmt.Errorf("profile %q is not defined in profiles", c.Profile)
+	}
+	for name, p := range c.Profiles {
+		for key := range p.Env {
+			if !envName.MatchString(key) {
+				return fmt.Errorf("profiles.%s.env: invalid variable name %q", name, key)
+			}
+		}
+		for key := range p.Secrets {
+			if !envName.MatchString(key) {
+				return fmt.Errorf("profiles.%s.secrets: invalid variable name %q", name, key)
+			}
+			if _, ok := p.Env[key]; ok {
+				return fmt.Errorf("profiles.%s: %s is set by both env and secrets", name, key)
+			}
+		}
+	}
+	return c.Watch.Validate()
+}
+
+func (w *Watch) Validate() error {
+	for _, ext := range w.Extensions {
+		if !strings.HasPrefix(ext, ".") {
+			return fmt.Errorf("watch.extensions must start with a dot: %q", ext)
+		}
+	}
+	if w.Interval < 0 {
+		return errors.New("watch.interval must not be negative")
+	}
+	return nil
+}
+
+// Merge returns the settings of w with the empty ones taken from defaults.
+func (w Watch) Merge(defaults Watch) Watch {
+	if w.Extensions == nil {
+		w.Extensions = defaults.Extensions
+	}
+	if w.Ignore == nil {
+		w.Ignore = defaults.Ignore
+	}
+	if w.Interval == 0 {
+		w.Interval = defaults.Interval
+	}
+	return w
+}
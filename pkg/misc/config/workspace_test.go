@@ -0,0 +1,59 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadWorkspaceProfiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tractor-pkg-misc-config-workspace")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, WorkspaceFile)
+	write := func(s string) {
+		require.NoError(t, ioutil.WriteFile(path, []byte(s), 0644))
+	}
+
+	write(`profile = "dev"
+
+[profiles.dev.env]
+API_URL = "http://localhost:8080"
+
+[profiles.prod.secrets]
+API_TOKEN = "prod-token"
+`)
+	c, err := LoadWorkspace(dir)
+	require.NoError(t, err)
+	assert.Equal(t, "dev", c.Profile)
+	assert.Equal(t, map[string]Profile{
+		"dev":  {Env: map[string]string{"API_URL": "http://localhost:8080"}},
+		"prod": {Secrets: map[string]string{"API_TOKEN": "prod-token"}},
+	}, c.Profiles)
+
+	os.Setenv("TRACTOR_DAEMON_ADDR", "localhost:5000")
+	c, err = LoadWorkspace(dir)
+	require.NoError(t, err)
+	assert.Equal(t, "localhost:5000", c.Daemon.Addr)
+	c, err = LoadWorkspaceFile(dir)
+	os.Unsetenv("TRACTOR_DAEMON_ADDR")
+	require.NoError(t, err)
+	assert.Equal(t, "localhost:4243", c.Daemon.Addr)
+	assert.Equal(t, "dev", c.Profile)
+
+	write(`profile = "staging"`)
+	_, err = LoadWorkspace(dir)
+	assert.EqualError(t, err, "config: "+path+`: profile "staging" is not defined in profiles`)
+
+	write("[profiles.dev.env]\n\"API-URL\" = \"x\"\n")
+	_, err = LoadWorkspace(dir)
+	assert.EqualError(t, err, "config: "+path+`: profiles.dev.env: invalid variable name "API-URL"`)
+
+	write("[profiles.dev.env]\nTOKEN = \"x\"\n[profiles.dev.secrets]\nTOKEN = \"token\"\n")
+	_, err = LoadWorkspace(dir)
+	assert.EqualError(t, err, "config: "+path+": profiles.dev: TOKEN is set by both env and secrets")
+}
@@ -1,6 +1,7 @@
 package jsonpointer
 
 import (
+	"fmt"
 	"reflect"
 	"strconv"
 	"strings"
@@ -15,79 +16,164 @@ func Reflect(o interface{}, path string) interface{} {
 	return oldjsonpointer.Reflect(o, path)
 }
 
-func SetReflect(o interface{}, path string, value interface{}) {
+// SetError is returned by SetReflect when the path doesn't exist or the value
+// can't be converted to the type at the path.
+type SetError struct {
+	Path   string
+	Reason string
+}
+
+func (e *SetError) Error() string {
+	return fmt.Sprintf("unable to set %s: %s", e.Path, e.Reason)
+}
+
+// SetReflect sets the value at the path in o, which has to be a pointer. Nil
+// pointers on the path are allocated, and map entries on the path are copied,
+// changed and stored again. The value is converted to the type at the path if
+// possible, otherwise nothing is set and a *SetError is returned, like for
+// paths that do not exist.
+func SetReflect(o interface{}, path string, value interface{}) error {
 	if path == "" {
-		return
+		return &SetError{Path: path, Reason: "empty path"}
 	}
 	if path[0] != '/' {
 		path = "/" + path
 	}
+	if reason := setReflect(reflect.ValueOf(o), parsePointer(path), value); reason != "" {
+		return &SetError{Path: path, Reason: reason}
+	}
+	return nil
+}
 
-	parts := parsePointer(path)
-	//var rv interface{} = o
-	val := reflect.ValueOf(o)
+// setReflect sets the value at the path parts in val and returns why it
+// couldn't, or an empty string if it did.
+func setReflect(val reflect.Value, parts []string, value interface{}) string {
+	if len(parts) == 0 {
+		return setValue(val, value)
+	}
+	for val.Kind() == reflect.Ptr {
+		if val.IsNil() {
+			if !val.CanSet() {
+				return "nil pointer is not settable"
+			}
+			val.Set(reflect.New(val.Type().Elem()))
+		}
+		val = val.Elem()
+	}
 
-OUTER:
-	for idx, p := range parts {
-		isLast := false
-		if idx == len(parts)-1 {
-			isLast = true
+	p := parts[0]
+	switch val.Kind() {
+	case reflect.Struct:
+		i, ok := StructField(val.Type(), p)
+		if !ok {
+			return fmt.Sprintf("no field %q in %s", p, val.Type())
+		}
+		return setReflect(val.Field(i), parts[1:], value)
+	case reflect.Map:
+		// our pointer always gives us a string key
+		// here we try to convert it into the correct type
+		mapKey, canConvert := makeMapKeyFromString(val.Type().Key(), p)
+		if !canConvert {
+			return fmt.Sprintf("invalid key %q for %s", p, val.Type())
+		}
+		if val.IsNil() {
+			if !val.CanSet() {
+				return "nil map is not settable"
+			}
+			val.Set(reflect.MakeMap(val.Type()))
+		}
+		// map entries are not addressable, so change a copy
+		elem := reflect.New(val.Type().Elem()).Elem()
+		if field := val.MapIndex(mapKey); field.IsValid() {
+			elem.Set(field)
+		} else if len(parts) > 1 {
+			return fmt.Sprintf("no map entry %q", p)
 		}
-		//val := reflect.ValueOf(rv)
-		if val.Kind() == reflect.Ptr {
-			val = val.Elem()
+		if reason := setReflect(elem, parts[1:], value); reason != "" {
+			return reason
+		}
+		val.SetMapIndex(mapKey, elem)
+		return ""
+	case reflect.Slice, reflect.Array:
+		i, err := strconv.Atoi(p)
+		if err != nil || i < 0 || i >= val.Len() {
+			return fmt.Sprintf("index %q out of range", p)
+		}
+		return setReflect(val.Index(i), parts[1:], value)
+	default:
+		return fmt.Sprintf("%s has no element %q", val.Type(), p)
+	}
+}
+
+func setValue(val reflect.Value, value interface{}) string {
+	if !val.CanSet() {
+		return "value is not settable"
+	}
+	if value == nil {
+		val.Set(reflect.Zero(val.Type()))
+		return ""
+	}
+	v := reflect.ValueOf(value)
+	if !v.Type().AssignableTo(val.Type()) {
+		if !v.Type().ConvertibleTo(val.Type()) {
+			return fmt.Sprintf("%s is not convertible to %s", v.Type(), val.Type())
 		}
+		v = v.Convert(val.Type())
+	}
+	val.Set(v)
+	return ""
+}
 
-		if val.Kind() == reflect.Struct {
-			typ := val.Type()
-			for i := 0; i < typ.NumField(); i++ {
-				sf := typ.Field(i)
-				tag := sf.Tag.Get("json")
-				name := parseJSONTagName(tag)
-				if (name != "" && name == p) || sf.Name == p {
-					val = val.Field(i)
-					continue OUTER
-				}
+// ReflectType returns the type of the value at the path in values of type t,
+// or nil if there is no such path.
+func ReflectType(t reflect.Type, path string) reflect.Type {
+	if path == "" {
+		return t
+	}
+	if path[0] != '/' {
+		path = "/" + path
+	}
+	for _, p := range parsePointer(path) {
+		for t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		switch t.Kind() {
+		case reflect.Struct:
+			i, ok := StructField(t, p)
+			if !ok {
+				return nil
 			}
-			// Found no matching field.
-			return
-		} else if val.Kind() == reflect.Map {
-			// our pointer always gives us a string key
-			// here we try to convert it into the correct type
-			mapKey, canConvert := makeMapKeyFromString(val.Type().Key(), p)
-			if canConvert {
-				field := val.MapIndex(mapKey)
-				if field.IsValid() {
-					if isLast {
-						val.SetMapIndex(mapKey, reflect.ValueOf(value))
-						return
-					} else {
-						val = field
-					}
-				} else {
-					return
-				}
-			} else {
-				return
+			t = t.Field(i).Type
+		case reflect.Map:
+			if _, ok := makeMapKeyFromString(t.Key(), p); !ok {
+				return nil
 			}
-		} else if val.Kind() == reflect.Slice || val.Kind() == reflect.Array {
-			i, err := strconv.Atoi(p)
-			if err == nil && i < val.Len() {
-				val = val.Index(i)
-			} else {
-				return
+			t = t.Elem()
+		case reflect.Slice, reflect.Array:
+			if _, err := strconv.Atoi(p); err != nil {
+				return nil
 			}
-		} else {
-			return
+			t = t.Elem()
+		default:
+			return nil
 		}
 	}
+	return t
+}
 
-	if value == nil {
-		val.Set(reflect.Zero(val.Type()))
-	} else {
-		val.Set(reflect.ValueOf(value))
+// StructField returns the index of the exported field of a struct type with
+// the name or JSON name, which is how path segments are resolved to fields.
+func StructField(t reflect.Type, name string) (int, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath != "" {
+			continue
+		}
+		if tag := parseJSONTagName(sf.Tag.Get("json")); (tag != "" && tag == name) || sf.Name == name {
+			return i, true
+		}
 	}
-	return
+	return 0, false
 }
 
 // ReflectListPointers lists all possible pointers from the given struct.
@@ -0,0 +1,75 @@
+package jsonpointer
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type item struct {
+	Name  string `json:"name"`
+	Count int
+}
+
+type value struct {
+	Items   []item
+	ByName  map[string]item
+	Options *item
+	Timeout time.Duration
+}
+
+func TestSetReflect(t *testing.T) {
+	v := &value{
+		Items:  []item{{Name: "a"}},
+		ByName: map[string]item{"b": {Name: "b"}},
+	}
+
+	require.NoError(t, SetReflect(v, "Items/0/name", "A"))
+	assert.Equal(t, "A", v.Items[0].Name)
+
+	require.NoError(t, SetReflect(v, "ByName/b/Count", 2))
+	assert.Equal(t, item{Name: "b", Count: 2}, v.ByName["b"])
+	require.NoError(t, SetReflect(v, "ByName/c", item{Name: "c"}))
+	assert.Equal(t, item{Name: "c"}, v.ByName["c"])
+
+	require.NoError(t, SetReflect(v, "Options/Count", 3))
+	assert.Equal(t, &item{Count: 3}, v.Options)
+	require.NoError(t, SetReflect(v, "Options", &item{Name: "o"}))
+	assert.Equal(t, &item{Name: "o"}, v.Options)
+	require.NoError(t, SetReflect(v, "Options", nil))
+	assert.Nil(t, v.Options)
+
+	require.NoError(t, SetReflect(v, "Timeout", int64(time.Second)))
+	assert.Equal(t, time.Second, v.Timeout)
+
+	// invalid paths and values are not set
+	for path, value := range map[string]interface{}{
+		"Items/1/Name":   "x",
+		"ByName/x/Count": 1,
+		"Missing":        1,
+		"Timeout":        "x",
+	} {
+		err := SetReflect(v, path, value)
+		require.Error(t, err, path)
+		_, ok := err.(*SetError)
+		assert.True(t, ok, path)
+	}
+	assert.EqualError(t, SetReflect(v, "Missing", 1), `unable to set /Missing: no field "Missing" in jsonpointer.value`)
+	assert.Len(t, v.Items, 1)
+	assert.NotContains(t, v.ByName, "x")
+	assert.Equal(t, time.Second, v.Timeout)
+}
+
+func TestReflectType(t *testing.T) {
+	typ := reflect.TypeOf(&value{})
+	assert.Equal(t, reflect.TypeOf(""), ReflectType(typ, "Items/0/name"))
+	assert.Equal(t, reflect.TypeOf(0), ReflectType(typ, "ByName/b/Count"))
+	assert.Equal(t, reflect.TypeOf(""), ReflectType(typ, "/Options/Name"))
+	assert.Equal(t, reflect.TypeOf(&item{}), ReflectType(typ, "Options"))
+	assert.Nil(t, ReflectType(typ, "Items/x"))
+	assert.Nil(t, ReflectType(typ, "Missing"))
+	assert.Nil(t, ReflectType(typ, "Timeout/x"))
+}
@@ -0,0 +1,116 @@
+package repl
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/dop251/goja"
+)
+
+// REPL evaluates JavaScript against Go values. Go values keep their field and
+// method names, and Go functions that return an error throw it.
+type REPL struct {
+	vm    *goja.Runtime
+	print func(v interface{})
+
+	// AfterEval is called after each evaluated statement, if set.
+	AfterEval func()
+}
+
+// NewREPL returns a REPL that passes the results of statements and the
+// arguments of the print function to print.
+func NewREPL(print func(v interface{})) *REPL {
+	r := &REPL{
+		vm:    goja.New(),
+		print: print,
+	}
+	r.vm.Set("print", func(args ...interface{}) {
+		for _, arg := range args {
+			r.print(arg)
+		}
+	})
+	return r
+}
+
+// Set sets a global variable.
+func (r *REPL) Set(name string, v interface{}) {
+	r.vm.Set(name, v)
+}
+
+// Eval evaluates src and returns its result, which is nil for undefined and
+// null. Panics in Go code called by src are returned as errors.
+func (r *REPL) Eval(src string) (v interface{}, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panic: %v", p)
+		}
+	}()
+	prg, err := goja.Compile("", src, false)
+	if err != nil {
+		return nil, err
+	}
+	res, err := r.vm.RunProgram(prg)
+	if err != nil {
+		return nil, err
+	}
+	return res.Export(), nil
+}
+
+// Run sets the globals, then reads statements from in line by line and
+// evaluates them until in is closed. Statements can span lines until they
+// are complete. Errors are written to out.
+func (r *REPL) Run(in io.Reader, out io.Writer, globals map[string]interface{}) error {
+	for name, v := range globals {
+		r.Set(name, v)
+	}
+	var src strings.Builder
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		src.WriteString(scanner.Text())
+		src.WriteString("\n")
+		v, err := r.Eval(src.String())
+		if incomplete(err) {
+			continue
+		}
+		src.Reset()
+		if r.AfterEval != nil {
+			r.AfterEval()
+		}
+		if err != nil {
+			fmt.Fprintln(out, err)
+			continue
+		}
+		if v != nil {
+			r.print(v)
+		}
+	}
+	return scanner.Err()
+}
+
+// incomplete returns whether err is the syntax error of a statement that
+// continues on the next line.
+func incomplete(err error) bool {
+	e, ok := err.(*goja.CompilerSyntaxError)
+	return ok && strings.Contains(e.Message, "Unexpected end of input")
+}
+
+// Format formats a value for printing. Strings are printed as they are,
+// values of Go types with their String method or fmt, and JavaScript objects
+// and arrays as JSON.
+func Format(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case fmt.Stringer:
+		return v.String()
+	case map[string]interface{}, []interface{}:
+		b, err := json.Marshal(v)
+		if err == nil {
+			return string(b)
+		}
+	}
+	return fmt.Sprintf("%v", v)
+}
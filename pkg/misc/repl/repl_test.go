@@ -0,0 +1,67 @@
+package repl
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type counter struct {
+	Count int
+}
+
+func (c *counter) Increment() {
+	c.Count++
+}
+
+func TestRun(t *testing.T) {
+	var printed []string
+	r := NewREPL(func(v interface{}) {
+		printed = append(printed, Format(v))
+	})
+	evals := 0
+	r.AfterEval = func() { evals++ }
+
+	c := &counter{}
+	in := strings.NewReader(strings.Join([]string{
+		"c.Increment()",
+		"c.Count",
+		"function add(a, b) {",
+		"  return a + b",
+		"}",
+		"add(1, 2)",
+		"print('hello', {a: [1]})",
+		"fail()",
+		"missing.x",
+		"'done'",
+	}, "\n"))
+	var out bytes.Buffer
+	require.NoError(t, r.Run(in, &out, map[string]interface{}{
+		"c":    c,
+		"fail": func() error { return errors.New("failed") },
+	}))
+
+	assert.Equal(t, 1, c.Count)
+	assert.Equal(t, []string{"1", "3", "hello", `{"a":[1]}`, "done"}, printed)
+	assert.Equal(t, 8, evals)
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 2)
+	assert.Contains(t, lines[0], "failed")
+	assert.Contains(t, lines[1], "ReferenceError")
+}
+
+func TestEvalPanic(t *testing.T) {
+	r := NewREPL(func(interface{}) {})
+	r.Set("boom", func() { panic("boom") })
+	_, err := r.Eval("boom()")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "boom")
+
+	v, err := r.Eval("undefined")
+	require.NoError(t, err)
+	assert.Nil(t, v)
+}
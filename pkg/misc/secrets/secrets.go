@@ -0,0 +1,165 @@
+// Package secrets keeps named secrets in a local file encrypted with
+// AES-256-GCM.
+//
+// The key is kept in a separate file readable only by the user, so the
+// secrets file itself can be backed up or synced without exposing the
+// secrets. The file is a random nonce followed by the sealed JSON object of
+// the secrets by name.
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/manifold/tractor/pkg/misc/auth"
+)
+
+var ErrNotFound = errors.New("secret not found")
+
+// Store is a file of secrets and the file of the key they are encrypted with.
+// The key is generated with the first secret.
+type Store struct {
+	Path    string
+	KeyPath string
+
+	mu sync.Mutex
+}
+
+// Open returns the store of the secrets file at path encrypted with the key
+// at keyPath. Neither has to exist yet.
+func Open(path, keyPath string) *Store {
+	return &Store{Path: path, KeyPath: keyPath}
+}
+
+// Get returns the named secret, or ErrNotFound.
+func (s *Store) Get(name string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	secrets, err := s.load()
+	if err != nil {
+		return "", err
+	}
+	value, ok := secrets[name]
+	if !ok {
+		return "", fmt.Errorf("%w: %q", ErrNotFound, name)
+	}
+	return value, nil
+}
+
+// Names returns the sorted names of the secrets.
+func (s *Store) Names() ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	secrets, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(secrets))
+	for name := range secrets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Set sets the named secret.
+func (s *Store) Set(name, value string) error {
+	if name == "" {
+		return errors.New("secret name must not be empty")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	secrets, err := s.load()
+	if err != nil {
+		return err
+	}
+	secrets[name] = value
+	return s.save(secrets)
+}
+
+// Delete removes the named secret, or returns ErrNotFound.
+func (s *Store) Delete(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	secrets, err := s.load()
+	if err != nil {
+		return err
+	}
+	if _, ok := secrets[name]; !ok {
+		return fmt.Errorf("%w: %q", ErrNotFound, name)
+	}
+	delete(secrets, name)
+	return s.save(secrets)
+}
+
+// load decrypts the secrets file. A missing file has no secrets.
+func (s *Store) load() (map[string]string, error) {
+	secrets := make(map[string]string)
+	b, err := ioutil.ReadFile(s.Path)
+	if os.IsNotExist(err) {
+		return secrets, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := s.cipher()
+	if err != nil {
+		return nil, err
+	}
+	if len(b) < gcm.NonceSize() {
+		return nil, fmt.Errorf("secrets file too short: %s", s.Path)
+	}
+	plain, err := gcm.Open(nil, b[:gcm.NonceSize()], b[gcm.NonceSize():], nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decrypt %s: %v", s.Path, err)
+	}
+	if err := json.Unmarshal(plain, &secrets); err != nil {
+		return nil, fmt.Errorf("unable to decode %s: %v", s.Path, err)
+	}
+	return secrets, nil
+}
+
+// save encrypts the secrets with a new nonce and replaces the secrets file.
+func (s *Store) save(secrets map[string]string) error {
+	plain, err := json.Marshal(secrets)
+	if err != nil {
+		return err
+	}
+	gcm, err := s.cipher()
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.Path), 0700); err != nil {
+		return err
+	}
+	tmp := s.Path + ".tmp"
+	if err := ioutil.WriteFile(tmp, gcm.Seal(nonce, nonce, plain, nil), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.Path)
+}
+
+func (s *Store) cipher() (cipher.AEAD, error) {
+	key, err := auth.LoadKey(s.KeyPath)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key[:32])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
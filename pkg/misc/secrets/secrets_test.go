@@ -0,0 +1,58 @@
+package secrets
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tractor-pkg-misc-secrets")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	s := Open(filepath.Join(dir, "secrets"), filepath.Join(dir, "secrets.key"))
+
+	names, err := s.Names()
+	require.NoError(t, err)
+	assert.Empty(t, names)
+	_, err = s.Get("token")
+	assert.True(t, errors.Is(err, ErrNotFound))
+
+	require.NoError(t, s.Set("token", "hunter2"))
+	require.NoError(t, s.Set("other", "value"))
+	value, err := s.Get("token")
+	require.NoError(t, err)
+	assert.Equal(t, "hunter2", value)
+	names, err = s.Names()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"other", "token"}, names)
+
+	b, err := ioutil.ReadFile(s.Path)
+	require.NoError(t, err)
+	assert.False(t, strings.Contains(string(b), "hunter2"))
+	fi, err := os.Stat(s.Path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), fi.Mode().Perm())
+
+	// a store with the same files reads the secrets
+	value, err = Open(s.Path, s.KeyPath).Get("token")
+	require.NoError(t, err)
+	assert.Equal(t, "hunter2", value)
+
+	require.NoError(t, s.Delete("token"))
+	assert.True(t, errors.Is(s.Delete("token"), ErrNotFound))
+	names, err = s.Names()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"other"}, names)
+
+	// a different key can't decrypt the file
+	_, err = Open(s.Path, filepath.Join(dir, "other.key")).Names()
+	assert.Error(t, err)
+	assert.EqualError(t, s.Set("", "value"), "secret name must not be empty")
+}
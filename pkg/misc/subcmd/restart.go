@@ -0,0 +1,164 @@
+package subcmd
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/manifold/tractor/pkg/misc/logging"
+)
+
+// RestartMode decides which exits of a command are followed by a restart.
+// Commands terminated by Restart are always started again.
+type RestartMode string
+
+const (
+	// RestartUnlessFailed restarts commands that exit cleanly or are killed
+	// by a signal. It is the default.
+	RestartUnlessFailed RestartMode = "unless-failed"
+	RestartAlways       RestartMode = "always"
+	RestartOnFailure    RestartMode = "on-failure"
+	RestartNever        RestartMode = "never"
+)
+
+const (
+	// ExitHistory is the number of exits kept for Exits.
+	ExitHistory = 10
+	// StderrTailSize is the number of bytes of standard error output kept
+	// for each exit.
+	StderrTailSize = 4 * 1024
+)
+
+// RestartPolicy describes when and how fast a command is restarted after it
+// exits on its own.
+type RestartPolicy struct {
+	Mode RestartMode
+
+	// MinBackoff is the delay before a restart. It doubles for each crash in
+	// a row up to MaxBackoff.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+
+	// Jitter randomly changes the delay by up to this fraction of it.
+	Jitter float64
+
+	// CrashWindow is the time a command has to run for its exit not to count
+	// as a crash. Zero disables crash detection.
+	CrashWindow time.Duration
+
+	// CrashLoop is the number of crashes in a row after which the command is
+	// not restarted and gets StatusCrashLooping. Zero for no limit.
+	CrashLoop int
+}
+
+// Exit describes an exit of a command.
+type Exit struct {
+	Time   int64 // Unix time of the exit
+	Code   int   // exit code, -1 if killed by a signal
+	Uptime time.Duration
+	Stderr string // tail of the standard error output
+}
+
+// SetRestartPolicy sets the policy for restarts after the command exits on
+// its own. The limit of SetMaxRestarts still applies.
+func (sc *Subcmd) SetRestartPolicy(p RestartPolicy) {
+	sc.restartMu.Lock()
+	defer sc.restartMu.Unlock()
+	sc.policy = p
+}
+
+// Exits returns the last exits of the command, oldest first.
+func (sc *Subcmd) Exits() []Exit {
+	sc.lastMu.Lock()
+	defer sc.lastMu.Unlock()
+	return append([]Exit(nil), sc.exits...)
+}
+
+func (sc *Subcmd) resetCrashes() {
+	sc.restartMu.Lock()
+	sc.crashes = 0
+	sc.restartMu.Unlock()
+}
+
+// nextRestart returns the delay before restarting the command after a run
+// that exited with err, or false if it is not restarted. The command gets
+// StatusCrashLooping if it crashed too often in a row.
+func (sc *Subcmd) nextRestart(err error, uptime time.Duration) (time.Duration, bool) {
+	sc.restartMu.Lock()
+	defer sc.restartMu.Unlock()
+	if sc.maxRestarts >= 0 && sc.restarts >= sc.maxRestarts {
+		return 0, false
+	}
+	if sc.restartReq {
+		sc.restartReq = false
+		sc.pending = true
+		return 0, true
+	}
+
+	p := sc.policy
+	switch p.Mode {
+	case RestartNever:
+		return 0, false
+	case RestartOnFailure:
+		if err == nil {
+			return 0, false
+		}
+	case RestartAlways:
+	default:
+		if err != nil && exitStatus(err) != -1 {
+			return 0, false
+		}
+	}
+
+	if p.CrashWindow > 0 && uptime < p.CrashWindow {
+		sc.crashes++
+	} else {
+		sc.crashes = 0
+	}
+	if p.CrashLoop > 0 && sc.crashes >= p.CrashLoop {
+		logging.Debug(sc.Log, "crash looping after ", sc.crashes, " crashes")
+		sc.setStatus(StatusCrashLooping)
+		return 0, false
+	}
+	sc.pending = true
+	return p.backoff(sc.crashes), true
+}
+
+// backoff returns the delay before a restart after the number of crashes in
+// a row.
+func (p RestartPolicy) backoff(crashes int) time.Duration {
+	d := p.MinBackoff
+	for i := 1; i < crashes && d < p.MaxBackoff; i++ {
+		d *= 2
+	}
+	if d > p.MaxBackoff && p.MaxBackoff > p.MinBackoff {
+		d = p.MaxBackoff
+	}
+	if p.Jitter > 0 {
+		d += time.Duration(float64(d) * p.Jitter * (2*rand.Float64() - 1))
+	}
+	return d
+}
+
+// tailWriter keeps the last bytes written to it.
+type tailWriter struct {
+	size int
+	buf  []byte
+	mu   sync.Mutex
+}
+
+func (w *tailWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.buf = append(w.buf, p...)
+	if len(w.buf) > w.size {
+		w.buf = append(w.buf[:0], w.buf[len(w.buf)-w.size:]...)
+	}
+	return len(p), nil
+}
+
+func (w *tailWriter) String() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return string(w.buf)
+}
@@ -2,6 +2,7 @@ package subcmd
 
 import (
 	"errors"
+	"io"
 	"os"
 	"os/exec"
 	"sync"
@@ -20,20 +21,23 @@ const (
 	StatusStarted  Status = "Started"
 	StatusExited   Status = "Exited"
 	StatusStopped  Status = "Stopped"
+
+	// StatusCrashLooping is set instead of restarting a command that keeps
+	// exiting shortly after it started, see RestartPolicy.
+	StatusCrashLooping Status = "CrashLooping"
 )
 
 var (
 	ErrStarted    = errors.New("already started")
 	ErrStarting   = errors.New("already starting")
 	ErrNotRunning = errors.New("not running")
-	ErrWaiting    = errors.New("already waiting")
 )
 
 func (s Status) Icon() []byte {
 	switch s {
 	case StatusStarted:
 		return icons.Available
-	case StatusStopped:
+	case StatusStopped, StatusCrashLooping:
 		return icons.Unavailable
 	default:
 		return icons.Partially
@@ -55,8 +59,11 @@ type Observer func(*Subcmd, Status)
 type Subcmd struct {
 	*exec.Cmd
 
+	// Setup is called before each start of the command. It must not call
+	// StderrPipe, as the standard error output is also kept for Exits.
 	Setup       func(*exec.Cmd) error
 	maxRestarts int
+	policy      RestartPolicy
 
 	Log logging.DebugLogger
 
@@ -64,14 +71,19 @@ type Subcmd struct {
 
 	callbacks []Observer
 
-	current *exec.Cmd
-	status  Status
+	current   *exec.Cmd
+	status    Status
+	startedAt time.Time
 
 	lastErr    error
 	lastStatus int
+	exits      []Exit
 	restarts   int
+	crashes    int  // exits in a row shortly after starting
+	restartReq bool // if Restart terminated the command
+	pending    bool // if a restart is waiting for its backoff
 
-	waitCh chan error
+	done chan struct{} // closed when the current run exits
 
 	cbMu      sync.Mutex
 	runMu     sync.Mutex
@@ -86,6 +98,7 @@ func New(name string, arg ...string) *Subcmd {
 	return &Subcmd{
 		Cmd:         exec.Command(name, arg...),
 		maxRestarts: -1,
+		policy:      RestartPolicy{Mode: RestartUnlessFailed},
 		status:      StatusStopped,
 	}
 }
@@ -113,22 +126,38 @@ func (sc *Subcmd) Start() error {
 	if sc.Status() == StatusStarting || sc.Status() == StatusStarted {
 		return ErrStarted
 	}
+	sc.resetCrashes()
 	return sc.start()
 }
 
+// Restart terminates the command and starts it again regardless of the
+// restart policy, or starts it if it is not running.
 func (sc *Subcmd) Restart() error {
 	if sc.Status() == StatusStarting {
 		return ErrStarting
 	}
+	sc.resetCrashes()
 	if !Running(sc) {
 		return sc.start()
 	}
+	sc.restartMu.Lock()
+	sc.restartReq = true
+	sc.restartMu.Unlock()
 	return sc.terminate()
 }
 
+// Stop terminates the command, or cancels a restart waiting for its backoff.
 func (sc *Subcmd) Stop() error {
 	if !Running(sc) {
-		return ErrNotRunning
+		sc.restartMu.Lock()
+		pending := sc.pending
+		sc.pending = false
+		sc.restartMu.Unlock()
+		if !pending {
+			return ErrNotRunning
+		}
+		sc.setStatus(StatusStopped)
+		return nil
 	}
 	sc.setStatus(StatusStopped)
 	return sc.terminate()
@@ -167,15 +196,17 @@ func (sc *Subcmd) terminate() error {
 	}
 }
 
+// Wait waits for the current run of the command to exit and returns its
+// error. It returns right away if the run already exited.
 func (sc *Subcmd) Wait() error {
 	sc.waitMu.Lock()
-	if sc.waitCh != nil {
-		sc.waitMu.Unlock()
-		return ErrWaiting
-	}
-	sc.waitCh = make(chan error)
+	done := sc.done
 	sc.waitMu.Unlock()
-	return <-sc.waitCh
+	if done == nil {
+		return ErrNotRunning
+	}
+	<-done
+	return sc.Error()
 }
 
 func (sc *Subcmd) setStatus(s Status) {
@@ -200,16 +231,49 @@ func (sc *Subcmd) Error() error {
 	return sc.lastErr
 }
 
+// Pid returns the process ID of the running command, or zero if it is not
+// running.
+func (sc *Subcmd) Pid() int {
+	if !Running(sc) {
+		return 0
+	}
+	sc.pidMu.Lock()
+	defer sc.pidMu.Unlock()
+	if sc.current == nil || sc.current.Process == nil {
+		return 0
+	}
+	return sc.current.Process.Pid
+}
+
+// StartedAt returns when the running command was started, or the zero time if
+// it is not running.
+func (sc *Subcmd) StartedAt() time.Time {
+	if !Running(sc) {
+		return time.Time{}
+	}
+	sc.pidMu.Lock()
+	defer sc.pidMu.Unlock()
+	return sc.startedAt
+}
+
 func (sc *Subcmd) ExitStatus() int {
 	sc.lastMu.Lock()
 	defer sc.lastMu.Unlock()
 	return sc.lastStatus
 }
 
-func (sc *Subcmd) start() (err error) {
+func (sc *Subcmd) start() error {
 	startErr := make(chan error)
 	go func() {
 		sc.runMu.Lock()
+		done := make(chan struct{})
+		sc.waitMu.Lock()
+		sc.done = done
+		sc.waitMu.Unlock()
+
+		sc.restartMu.Lock()
+		sc.pending = false
+		sc.restartMu.Unlock()
 		sc.setStatus(StatusStarting)
 
 		sc.pidMu.Lock()
@@ -225,71 +289,81 @@ func (sc *Subcmd) start() (err error) {
 
 		if sc.Setup != nil {
 			if err := sc.Setup(sc.current); err != nil {
+				sc.setStatus(StatusStopped)
+				close(done)
 				sc.runMu.Unlock()
 				startErr <- err
 				return
 			}
 		}
+		tail := &tailWriter{size: StderrTailSize}
+		if sc.current.Stderr != nil {
+			sc.current.Stderr = io.MultiWriter(sc.current.Stderr, tail)
+		} else {
+			sc.current.Stderr = tail
+		}
 
-		err = sc.current.Start()
+		sc.pidMu.Lock()
+		err := sc.current.Start()
+		started := time.Now()
+		sc.startedAt = started
+		sc.pidMu.Unlock()
 		if err != nil {
 			sc.setStatus(StatusStopped)
+			close(done)
 			sc.runMu.Unlock()
 			startErr <- err
 			return
 		}
 
-		startErr <- nil
-
-		// process died too quickly?
-		if sc.current.Process == nil {
-			sc.setStatus(StatusStopped)
-			sc.runMu.Unlock()
-			return
-		}
-
+		// set before Start returns so that a following Stop comes after it
 		sc.setStatus(StatusStarted)
+		startErr <- nil
 		if sc.Started != nil {
 			sc.Started <- sc.current
 		}
 
+		waitErr := sc.current.Wait()
+		uptime := time.Since(started)
 		sc.lastMu.Lock()
-		sc.lastErr = sc.current.Wait()
-		sc.lastStatus = exitStatus(sc.lastErr)
+		sc.lastErr = waitErr
+		sc.lastStatus = exitStatus(waitErr)
+		sc.exits = append(sc.exits, Exit{
+			Time:   time.Now().Unix(),
+			Code:   sc.lastStatus,
+			Uptime: uptime,
+			Stderr: tail.String(),
+		})
+		if len(sc.exits) > ExitHistory {
+			sc.exits = sc.exits[len(sc.exits)-ExitHistory:]
+		}
 		sc.lastMu.Unlock()
-		if sc.Status() != StatusStopped {
+		stopped := sc.Status() == StatusStopped
+		if !stopped {
 			sc.setStatus(StatusExited)
 		}
-
-		sc.waitMu.Lock()
-		if sc.waitCh != nil {
-			sc.waitCh <- sc.lastErr
-			sc.waitCh = nil
+		close(done)
+		sc.runMu.Unlock()
+		if stopped {
+			return
 		}
-		sc.waitMu.Unlock()
 
-		if sc.lastErr != nil && sc.lastStatus != -1 { // was not SIGKILLED
-			sc.runMu.Unlock()
+		delay, ok := sc.nextRestart(waitErr, uptime)
+		if !ok {
 			return
 		}
-		sc.runMu.Unlock()
-
-		sc.restartMu.Lock()
-		if sc.maxRestarts >= 0 && sc.restarts >= sc.maxRestarts {
-			sc.restartMu.Unlock()
+		time.Sleep(delay)
+		if sc.Status() != StatusExited {
+			// stopped or started while waiting
 			return
 		}
-		sc.restartMu.Unlock()
-
-		if sc.Status() != StatusStopped {
-			if err := sc.start(); err != nil {
-				panic(err)
-			}
-			sc.restartMu.Lock()
-			sc.restarts++
-			sc.restartMu.Unlock()
+		if err := sc.start(); err != nil {
+			logging.Debug(sc.Log, "unable to restart: ", err)
+			return
 		}
-
+		sc.restartMu.Lock()
+		sc.restarts++
+		sc.restartMu.Unlock()
 	}()
 	return <-startErr
 }
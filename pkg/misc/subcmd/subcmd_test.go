@@ -3,6 +3,7 @@ package subcmd
 import (
 	"os/exec"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -153,3 +154,118 @@ func TestLRPMaxRestarts(t *testing.T) {
 	assert.Equal(t, StatusExited, <-status)
 	assert.False(t, Running(cmd))
 }
+
+func TestExits(t *testing.T) {
+	status, cmd := setupCmd(false, "sh", "-c", "echo boom >&2; exit 3")
+	assert.Nil(t, cmd.Start())
+	assert.NotNil(t, cmd.Wait())
+	assert.Equal(t, StatusStarting, <-status)
+	assert.Equal(t, StatusStarted, <-status)
+	assert.Equal(t, StatusExited, <-status)
+
+	exits := cmd.Exits()
+	require.Len(t, exits, 1)
+	assert.Equal(t, 3, exits[0].Code)
+	assert.Equal(t, "boom\n", exits[0].Stderr)
+	assert.NotZero(t, exits[0].Time)
+}
+
+func TestRestartPolicy(t *testing.T) {
+	for _, tt := range []struct {
+		mode    RestartMode
+		prog    string
+		restart bool
+	}{
+		{RestartNever, "false", false},
+		{RestartOnFailure, "true", false},
+		{RestartOnFailure, "false", true},
+		{RestartAlways, "true", true},
+		{RestartUnlessFailed, "true", true},
+		{RestartUnlessFailed, "false", false},
+	} {
+		status := make(chan Status, 10)
+		cmd := New(tt.prog)
+		cmd.SetMaxRestarts(1)
+		cmd.SetRestartPolicy(RestartPolicy{Mode: tt.mode})
+		cmd.Observe(func(sc *Subcmd, newStatus Status) {
+			status <- newStatus
+		})
+		assert.Nil(t, cmd.Start())
+		assert.Equal(t, StatusStarting, <-status)
+		assert.Equal(t, StatusStarted, <-status)
+		assert.Equal(t, StatusExited, <-status)
+		if tt.restart {
+			assert.Equal(t, StatusStarting, <-status, tt)
+			assert.Equal(t, StatusStarted, <-status, tt)
+			assert.Equal(t, StatusExited, <-status, tt)
+		} else {
+			select {
+			case s := <-status:
+				t.Errorf("%v: unexpected status %s", tt, s)
+			case <-time.After(50 * time.Millisecond):
+			}
+		}
+	}
+}
+
+func TestCrashLoop(t *testing.T) {
+	status := make(chan Status, 10)
+	cmd := New("sh", "-c", "exit 1")
+	cmd.SetRestartPolicy(RestartPolicy{
+		Mode:        RestartOnFailure,
+		MinBackoff:  time.Millisecond,
+		MaxBackoff:  10 * time.Millisecond,
+		CrashWindow: time.Minute,
+		CrashLoop:   3,
+	})
+	cmd.Observe(func(sc *Subcmd, newStatus Status) {
+		status <- newStatus
+	})
+
+	assert.Nil(t, cmd.Start())
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, StatusStarting, <-status)
+		assert.Equal(t, StatusStarted, <-status)
+		assert.Equal(t, StatusExited, <-status)
+	}
+	assert.Equal(t, StatusCrashLooping, <-status)
+	assert.False(t, Running(cmd))
+	assert.Len(t, cmd.Exits(), 3)
+
+	// starting again resets the crashes
+	assert.Nil(t, cmd.Start())
+	assert.Equal(t, StatusStarting, <-status)
+}
+
+func TestStopBackoff(t *testing.T) {
+	status, cmd := setupCmd(true, "false")
+	cmd.SetRestartPolicy(RestartPolicy{Mode: RestartAlways, MinBackoff: time.Minute})
+
+	assert.Nil(t, cmd.Start())
+	assert.Equal(t, StatusStarting, <-status)
+	assert.Equal(t, StatusStarted, <-status)
+	assert.Equal(t, StatusExited, <-status)
+
+	// the restart waits for its backoff, which Stop cancels
+	for i := 0; i < 100 && cmd.Stop() == ErrNotRunning; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(t, StatusStopped, <-status)
+	assert.Equal(t, ErrNotRunning, cmd.Stop())
+}
+
+func TestBackoff(t *testing.T) {
+	p := RestartPolicy{MinBackoff: time.Second, MaxBackoff: 5 * time.Second}
+	assert.Equal(t, time.Second, p.backoff(0))
+	assert.Equal(t, time.Second, p.backoff(1))
+	assert.Equal(t, 2*time.Second, p.backoff(2))
+	assert.Equal(t, 4*time.Second, p.backoff(3))
+	assert.Equal(t, 5*time.Second, p.backoff(4))
+	assert.Equal(t, 5*time.Second, p.backoff(50))
+
+	p.Jitter = 0.5
+	for i := 0; i < 10; i++ {
+		d := p.backoff(2)
+		assert.True(t, d >= time.Second && d <= 3*time.Second, d)
+	}
+}
@@ -9,9 +9,15 @@ import (
 	"github.com/urfave/negroni"
 )
 
+// Server serves HTTP requests from a Listener using a Handler.
 type Server struct {
+	_ struct{} `tractor:"category=HTTP,icon=server,name=HTTP Server"`
+
+	// Listener accepts the connections to serve.
 	Listener net.Listener `com:"singleton"`
-	Handler  http.Handler `com:"singleton"`
+
+	// Handler responds to the HTTP requests.
+	Handler http.Handler `com:"singleton"`
 	// Middleware []negroni.Handler `com:"extpoint"`
 
 	s *http.Server
@@ -3,10 +3,10 @@ package irc
 import (
 	"fmt"
 	"log"
-	"os"
 	"reflect"
 
 	"github.com/manifold/tractor/pkg/manifold"
+	"github.com/manifold/tractor/pkg/workspace/env"
 	ircx "github.com/nickvanw/ircx/v2"
 	"gopkg.in/sorcix/irc.v2"
 )
@@ -21,13 +21,14 @@ type IRCClient struct {
 	User   string
 	pass   string
 
-	Handler Handler `com:"singleton"`
+	Handler Handler  `com:"singleton"`
+	Env     *env.Env `tractor:"hidden"`
 
 	bot *ircx.Bot
 }
 
 func (c *IRCClient) Initialize() error {
-	c.pass = os.Getenv("TWITCH_IRC_TOKEN")
+	c.pass = c.Env.String("TWITCH_IRC_TOKEN", "")
 	c.bot = ircx.WithLogin(c.Server, c.Nick, c.User, c.pass)
 	if err := c.bot.Connect(); err != nil {
 		return err
@@ -1,6 +1,7 @@
 package stdlib
 
 import (
+	"log"
 	"path"
 	"runtime"
 
@@ -18,28 +19,34 @@ func filepath(subpath string) string {
 	return path.Join(path.Dir(filename), subpath)
 }
 
+func register(v interface{}, subpath string, tags ...string) {
+	if err := library.Register(v, "", filepath(subpath), tags...); err != nil {
+		log.Print(err)
+	}
+}
+
 func Load() {
 	// file
-	library.Register(&file.Local{}, "", filepath("file/local.go"))
-	library.Register(&file.Path{}, "", filepath("file/path.go"))
-	library.Register(&file.Explorer{}, "", filepath("file/explorer.go"))
+	register(&file.Local{}, "file/local.go", "file")
+	register(&file.Path{}, "file/path.go", "file")
+	register(&file.Explorer{}, "file/explorer.go", "file")
 
 	// http
-	library.Register(&http.SingleUserBasicAuth{}, "", filepath("http/basicauth.go"))
-	library.Register(&http.FileServer{}, "", filepath("http/fileserver.go"))
-	library.Register(&http.Logger{}, "", filepath("http/logger.go"))
-	library.Register(&http.Mux{}, "", filepath("http/mux.go"))
-	library.Register(&http.Server{}, "", filepath("http/server.go"))
-	library.Register(&http.TemplateRenderer{}, "", filepath("http/templaterenderer.go"))
+	register(&http.SingleUserBasicAuth{}, "http/basicauth.go", "http", "middleware")
+	register(&http.FileServer{}, "http/fileserver.go", "http", "handler")
+	register(&http.Logger{}, "http/logger.go", "http", "middleware")
+	register(&http.Mux{}, "http/mux.go", "http", "handler")
+	register(&http.Server{}, "http/server.go", "http")
+	register(&http.TemplateRenderer{}, "http/templaterenderer.go", "http", "handler")
 
 	// net
-	library.Register(&net.TCPListener{}, "", filepath("net/listener.go"))
+	register(&net.TCPListener{}, "net/listener.go", "net")
 
 	// net/irc
-	library.Register(&irc.IRCClient{}, "", filepath("net/irc/irc.go"))
-	library.Register(&irc.BangMux{}, "", filepath("net/irc/irc.go"))
+	register(&irc.IRCClient{}, "net/irc/irc.go", "net", "irc")
+	register(&irc.BangMux{}, "net/irc/irc.go", "net", "irc", "handler")
 
 	// time
-	library.Register(&time.CronManager{}, "", filepath("time/cron.go"))
+	register(&time.CronManager{}, "time/cron.go", "time")
 
 }
@@ -5,19 +5,27 @@ import (
 	"flag"
 	"log"
 	"os"
+	"os/user"
+	"path/filepath"
 
 	"github.com/manifold/tractor/pkg/manifold"
 	"github.com/manifold/tractor/pkg/manifold/object"
+	"github.com/manifold/tractor/pkg/misc/auth"
+	"github.com/manifold/tractor/pkg/misc/config"
 	"github.com/manifold/tractor/pkg/misc/daemon"
 	"github.com/manifold/tractor/pkg/misc/logging/std"
 	"github.com/manifold/tractor/pkg/stdlib"
+	"github.com/manifold/tractor/pkg/workspace/env"
+	"github.com/manifold/tractor/pkg/workspace/remote"
 	"github.com/manifold/tractor/pkg/workspace/rpc"
 	"github.com/manifold/tractor/pkg/workspace/state"
 )
 
 var (
-	addr  = flag.String("addr", "localhost:4243", "server listener address")
-	proto = flag.String("proto", "websocket", "server listener protocol")
+	addr     = flag.String("addr", "", "server listener address (default is daemon.addr of tractor.toml, or localhost:4243)")
+	proto    = flag.String("proto", "", "server listener protocol (default is daemon.proto of tractor.toml, or websocket)")
+	httpAddr = flag.String("http", "", "REST gateway listener address (default is daemon.http of tractor.toml), disabled if empty")
+	key      = flag.String("authkey", os.Getenv(auth.KeyFileEnv), "path to the key tokens are verified with (default is ~/.tractor/auth.key)")
 )
 
 func init() {
@@ -27,23 +35,90 @@ func init() {
 func Run() {
 	flag.Parse()
 	logger := std.NewLogger("", os.Stdout)
+	keyFile, err := authKeyFile()
+	fatal(err)
+	cfg, err := daemonConfig()
+	fatal(err)
 	rpcSvc := &rpc.Service{
-		Protocol:   *proto,
-		ListenAddr: *addr,
-		Log:        logger,
+		Protocol:    cfg.Proto,
+		ListenAddr:  cfg.Addr,
+		AuthKeyFile: keyFile,
+		Workspace:   workspaceName(),
+		Log:         logger,
 	}
+	// components read the variables of the profile through the registry
+	envSvc := env.New(os.Environ())
 	object.RegistryPreloader = func(o manifold.Object) []interface{} {
-		return []interface{}{o, rpcSvc}
+		return []interface{}{o, rpcSvc, envSvc}
 	}
-	dm := daemon.New([]daemon.Service{
+	services := []daemon.Service{
+		&remote.Service{
+			Log: logger,
+		},
 		&state.Service{
 			Log: logger,
 		},
 		rpcSvc,
-	}...)
+	}
+	if cfg.HTTP != "" {
+		services = append(services, &gatewayService{
+			ListenAddr: cfg.HTTP,
+			Log:        logger,
+			RPC:        rpcSvc,
+		})
+	}
+	dm := daemon.New(services...)
 	fatal(dm.Run(context.Background()))
 }
 
+// daemonConfig returns the daemon configuration of the tractor.toml in the
+// working directory, overridden by the flags that were set.
+func daemonConfig() (config.Daemon, error) {
+	wd, err := os.Getwd()
+	if err != nil {
+		return config.Daemon{}, err
+	}
+	c, err := config.LoadWorkspace(wd)
+	if err != nil {
+		return config.Daemon{}, err
+	}
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "addr":
+			c.Daemon.Addr = *addr
+		case "proto":
+			c.Daemon.Proto = *proto
+		case "http":
+			c.Daemon.HTTP = *httpAddr
+		}
+	})
+	return c.Daemon, nil
+}
+
+func authKeyFile() (string, error) {
+	if *key != "" {
+		return *key, nil
+	}
+	if path := os.Getenv(config.PathEnv); path != "" {
+		return filepath.Join(path, "auth.key"), nil
+	}
+	usr, err := user.Current()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(usr.HomeDir, ".tractor", "auth.key"), nil
+}
+
+// workspaceName returns the name set by the agent, or the name of the working
+// directory for daemons started without it.
+func workspaceName() string {
+	if name := os.Getenv(auth.WorkspaceEnv); name != "" {
+		return name
+	}
+	wd, _ := os.Getwd()
+	return filepath.Base(wd)
+}
+
 func fatal(err error) {
 	if err != nil {
 		log.Fatal(err)
@@ -0,0 +1,40 @@
+package daemon
+
+import (
+	"context"
+	"net"
+	"net/http"
+
+	"github.com/manifold/tractor/pkg/misc/logging"
+	"github.com/manifold/tractor/pkg/workspace/rpc"
+)
+
+// gatewayService serves the REST gateway of the RPC service over HTTP.
+type gatewayService struct {
+	ListenAddr string
+
+	Log logging.Logger
+	RPC *rpc.Service
+
+	l   net.Listener
+	srv *http.Server
+}
+
+func (s *gatewayService) InitializeDaemon() (err error) {
+	if s.l, err = net.Listen("tcp", s.ListenAddr); err != nil {
+		return err
+	}
+	s.srv = &http.Server{Handler: s.RPC.Gateway()}
+	return nil
+}
+
+func (s *gatewayService) Serve(ctx context.Context) {
+	s.Log.Infof("[gateway] http://%s%s", s.ListenAddr, rpc.OpenAPIPath)
+	if err := s.srv.Serve(s.l); err != nil && err != http.ErrServerClosed {
+		s.Log.Info("[gateway]", err)
+	}
+}
+
+func (s *gatewayService) TerminateDaemon() error {
+	return s.srv.Shutdown(context.Background())
+}
@@ -0,0 +1,116 @@
+// Package env gives components typed access to the environment of the
+// workspace daemon, which includes the variables of the profile the agent
+// started it with. Components get the environment from the registry with an
+// exported field instead of calling os.Getenv:
+//
+//	type Client struct {
+//		Env *env.Env `tractor:"hidden"`
+//	}
+//
+//	func (c *Client) Initialize() error {
+//		token, err := c.Env.Require("CLIENT_TOKEN")
+//		...
+//	}
+package env
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ProfileEnv is the environment variable with the name of the profile of the
+// workspace.
+const ProfileEnv = "TRACTOR_PROFILE"
+
+// Env is a set of environment variables. A nil Env reads the environment of
+// the process.
+type Env struct {
+	vars map[string]string
+}
+
+// New returns the Env of variables in the form "key=value", like those of
+// os.Environ.
+func New(environ []string) *Env {
+	e := &Env{vars: make(map[string]string, len(environ))}
+	for _, kv := range environ {
+		if i := strings.Index(kv, "="); i > 0 {
+			e.vars[kv[:i]] = kv[i+1:]
+		}
+	}
+	return e
+}
+
+// Profile returns the name of the profile of the workspace, empty if none.
+func (e *Env) Profile() string {
+	return e.String(ProfileEnv, "")
+}
+
+// Lookup returns the value of the variable and whether it is set.
+func (e *Env) Lookup(name string) (string, bool) {
+	if e == nil {
+		return os.LookupEnv(name)
+	}
+	v, ok := e.vars[name]
+	return v, ok
+}
+
+// String returns the value of the variable, or def if it is not set.
+func (e *Env) String(name, def string) string {
+	if v, ok := e.Lookup(name); ok {
+		return v
+	}
+	return def
+}
+
+// Require returns the value of the variable, or an error if it is not set or
+// empty.
+func (e *Env) Require(name string) (string, error) {
+	v, _ := e.Lookup(name)
+	if v == "" {
+		return "", fmt.Errorf("env: %s is not set", name)
+	}
+	return v, nil
+}
+
+// Int returns the variable as an integer, or def if it is not set.
+func (e *Env) Int(name string, def int) (int, error) {
+	v, ok := e.Lookup(name)
+	if !ok {
+		return def, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return def, fmt.Errorf("env: %s is not an integer: %q", name, v)
+	}
+	return n, nil
+}
+
+// Bool returns the variable as a boolean, or def if it is not set.
+func (e *Env) Bool(name string, def bool) (bool, error) {
+	v, ok := e.Lookup(name)
+	if !ok {
+		return def, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return def, fmt.Errorf("env: %s is not a boolean: %q", name, v)
+	}
+	return b, nil
+}
+
+// Duration returns the variable as a duration like "1m30s", or def if it is
+// not set.
+func (e *Env) Duration(name string, def time.Duration) (time.Duration, error) {
+	v, ok := e.Lookup(name)
+	if !ok {
+		return def, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return def, fmt.Errorf("env: %s is not a duration: %q", name, v)
+	}
+	return d, nil
+}
@@ -0,0 +1,63 @@
+package env
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnv(t *testing.T) {
+	e := New([]string{
+		"TRACTOR_PROFILE=prod",
+		"TOKEN=a=b",
+		"EMPTY=",
+		"PORT=8080",
+		"DEBUG=true",
+		"TIMEOUT=1m30s",
+		"BAD=x",
+		"invalid",
+	})
+	assert.Equal(t, "prod", e.Profile())
+
+	v, ok := e.Lookup("TOKEN")
+	assert.True(t, ok)
+	assert.Equal(t, "a=b", v)
+	assert.Equal(t, "", e.String("EMPTY", "default"))
+	assert.Equal(t, "default", e.String("MISSING", "default"))
+
+	v, err := e.Require("TOKEN")
+	require.NoError(t, err)
+	assert.Equal(t, "a=b", v)
+	_, err = e.Require("EMPTY")
+	assert.EqualError(t, err, "env: EMPTY is not set")
+
+	n, err := e.Int("PORT", 0)
+	require.NoError(t, err)
+	assert.Equal(t, 8080, n)
+	n, err = e.Int("MISSING", 3)
+	require.NoError(t, err)
+	assert.Equal(t, 3, n)
+	_, err = e.Int("BAD", 0)
+	assert.EqualError(t, err, "env: BAD is not an integer: \"x\"")
+
+	b, err := e.Bool("DEBUG", false)
+	require.NoError(t, err)
+	assert.True(t, b)
+	_, err = e.Bool("BAD", false)
+	assert.Error(t, err)
+
+	d, err := e.Duration("TIMEOUT", 0)
+	require.NoError(t, err)
+	assert.Equal(t, 90*time.Second, d)
+	_, err = e.Duration("BAD", 0)
+	assert.Error(t, err)
+
+	// a nil Env reads the environment of the process
+	os.Setenv("TRACTOR_TEST_ENV", "process")
+	defer os.Unsetenv("TRACTOR_TEST_ENV")
+	var nilEnv *Env
+	assert.Equal(t, "process", nilEnv.String("TRACTOR_TEST_ENV", ""))
+}
@@ -0,0 +1,166 @@
+package remote
+
+import (
+	"fmt"
+	"sync"
+
+	qrpc "github.com/manifold/qtalk/golang/rpc"
+	"github.com/manifold/tractor/pkg/manifold"
+	"github.com/manifold/tractor/pkg/manifold/library"
+	"github.com/manifold/tractor/pkg/misc/subcmd"
+)
+
+// process is a remote component process. It is the library.Proxy for all the
+// components it registers.
+type process struct {
+	name string
+	path string
+	cmd  *subcmd.Subcmd
+
+	caller     qrpc.Caller
+	schemas    map[string]Schema
+	registered chan struct{}
+	connected  bool // if the process has registered once
+
+	mu sync.Mutex
+}
+
+func (p *process) qualifiedName(name string) string {
+	return fmt.Sprintf("remote/%s.%s", p.name, name)
+}
+
+// register adds the component of the schema to the library. Restarted
+// processes register their components again, and a component whose fields
+// changed replaces the registered one, which is returned so existing
+// components can be swapped to it.
+func (p *process) register(schema Schema) (*library.RegisteredComponent, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	typ, err := schema.structType()
+	if err != nil {
+		return nil, err
+	}
+	name := p.qualifiedName(schema.Name)
+	var replaced *library.RegisteredComponent
+	if _, exists := p.schemas[schema.Name]; !exists {
+		err = library.RegisterProxy(name, typ, p, p.path, "remote")
+	} else if library.Lookup(name).Type.Type != typ {
+		var rcs []*library.RegisteredComponent
+		rcs, err = library.Replace(func() error {
+			return library.RegisterProxy(name, typ, p, p.path, "remote")
+		})
+		if len(rcs) > 0 {
+			replaced = rcs[0]
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	rc := library.Lookup(name)
+	rc.Metadata.Description = schema.Description
+	for _, f := range schema.Fields {
+		fm := rc.Metadata.Field(f.Name)
+		fm.Description = f.Description
+		rc.Metadata.Fields[f.Name] = fm
+	}
+	p.schemas[schema.Name] = schema
+	return replaced, nil
+}
+
+func (p *process) lookup(component string) (Schema, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	schema, ok := p.schemas[component]
+	return schema, ok
+}
+
+// owns returns whether com is a component registered by the process, as
+// other components can have the same name.
+func (p *process) owns(com manifold.Component) bool {
+	if _, ok := p.lookup(com.Name()); !ok {
+		return false
+	}
+	return library.TypeName(com) == p.qualifiedName(com.Name())
+}
+
+// connect sets the caller used to call into the process and returns true if
+// the process had been connected before.
+func (p *process) connect(caller qrpc.Caller) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	reconnected := p.connected
+	p.caller = caller
+	if !p.connected {
+		p.connected = true
+		close(p.registered)
+	}
+	return reconnected
+}
+
+func (p *process) disconnect() {
+	p.mu.Lock()
+	p.caller = nil
+	p.mu.Unlock()
+}
+
+func (p *process) call(method string, params, reply interface{}) error {
+	p.mu.Lock()
+	caller := p.caller
+	p.mu.Unlock()
+	if caller == nil {
+		return fmt.Errorf("remote process %s is not connected", p.name)
+	}
+	_, err := caller.Call(method, params, reply)
+	return err
+}
+
+func objectID(com manifold.Component) string {
+	if obj := com.Container(); obj != nil {
+		return obj.ID()
+	}
+	return ""
+}
+
+func (p *process) SetField(com manifold.Component, path string, value interface{}) error {
+	return p.call("setField", FieldParams{
+		ObjectID:  objectID(com),
+		Component: com.Name(),
+		Path:      path,
+		Value:     value,
+	}, nil)
+}
+
+func (p *process) CallMethod(com manifold.Component, method string, args []interface{}, reply interface{}) error {
+	schema, ok := p.lookup(com.Name())
+	if !ok || !schema.hasMethod(method) {
+		return fmt.Errorf("remote component %s has no method: %s", com.Name(), method)
+	}
+	return p.call("callMethod", MethodParams{
+		ObjectID:  objectID(com),
+		Component: com.Name(),
+		Method:    method,
+		Args:      args,
+	}, reply)
+}
+
+func (p *process) Enable(com manifold.Component) error {
+	return p.call("enable", ComponentParams{
+		ObjectID:  objectID(com),
+		Component: com.Name(),
+		Value:     fieldValues(com.Pointer()),
+	}, nil)
+}
+
+func (p *process) Disable(com manifold.Component) error {
+	p.mu.Lock()
+	connected := p.caller != nil
+	p.mu.Unlock()
+	if !connected {
+		// components of an exited process are already gone with it
+		return nil
+	}
+	return p.call("disable", ComponentParams{
+		ObjectID:  objectID(com),
+		Component: com.Name(),
+	}, nil)
+}
@@ -0,0 +1,187 @@
+package remote
+
+import (
+	"reflect"
+	"testing"
+
+	qrpc "github.com/manifold/qtalk/golang/rpc"
+	"github.com/manifold/tractor/pkg/manifold/library"
+	"github.com/manifold/tractor/pkg/manifold/object"
+	"github.com/manifold/tractor/pkg/misc/logging/null"
+	"github.com/manifold/tractor/pkg/workspace/state"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type call struct {
+	method string
+	params interface{}
+}
+
+type testCaller struct {
+	calls []call
+}
+
+func (c *testCaller) Call(path string, args, reply interface{}) (*qrpc.Response, error) {
+	c.calls = append(c.calls, call{path, args})
+	if r, ok := reply.(*interface{}); ok {
+		*r = "pong"
+	}
+	return nil, nil
+}
+
+var greeter = Schema{
+	Name:        "Greeter",
+	Description: "Greets people",
+	Fields: []FieldSchema{
+		{Name: "Greeting", Type: "string", Description: "Greeting to use"},
+		{Name: "Times", Type: "number"},
+		{Name: "Loud", Type: "boolean"},
+	},
+	Methods: []string{"Ping"},
+}
+
+func TestSchema(t *testing.T) {
+	typ, err := greeter.structType()
+	require.NoError(t, err)
+	assert.Equal(t, 3, typ.NumField())
+	assert.Equal(t, reflect.Float64, typ.Field(1).Type.Kind())
+
+	for _, schema := range []Schema{
+		{Name: "lower"},
+		{Name: "Bad", Fields: []FieldSchema{{Name: "x", Type: "string"}}},
+		{Name: "Bad", Fields: []FieldSchema{{Name: "X", Type: "complex"}}},
+		{Name: "Bad", Fields: []FieldSchema{{Name: "X", Type: "string"}, {Name: "X", Type: "string"}}},
+	} {
+		_, err := schema.structType()
+		assert.Error(t, err, schema)
+	}
+}
+
+func TestProcess(t *testing.T) {
+	defer library.Isolate()()
+	p := &process{
+		name:       "greeter",
+		path:       "/remote/greeter",
+		schemas:    make(map[string]Schema),
+		registered: make(chan struct{}),
+	}
+	replaced, err := p.register(greeter)
+	require.NoError(t, err)
+	assert.Nil(t, replaced)
+
+	rc := library.Lookup("remote/greeter.Greeter")
+	require.NotNil(t, rc)
+	assert.Equal(t, rc, library.Lookup("Greeter"))
+	assert.Equal(t, "remote/greeter", rc.Package())
+	assert.Equal(t, "Greets people", rc.Metadata.Description)
+	assert.Equal(t, "Greeting to use", rc.Metadata.Field("Greeting").Description)
+
+	obj := object.New("obj")
+	com := rc.New()
+	obj.AppendComponent(com)
+
+	assert.Error(t, com.SetField("Greeting", "hello"), "not connected")
+	v, _, _ := com.GetField("Greeting")
+	assert.Equal(t, "", v, "failed remote change is not kept")
+
+	caller := &testCaller{}
+	assert.False(t, p.connect(caller))
+	assert.True(t, p.connect(caller))
+
+	require.NoError(t, com.SetField("Greeting", "hi"))
+	require.NoError(t, com.Reload())
+	var reply interface{}
+	require.NoError(t, com.CallMethod("Ping", nil, &reply))
+	assert.Equal(t, "pong", reply)
+	assert.Error(t, com.CallMethod("Missing", nil, nil))
+
+	require.Len(t, caller.calls, 3)
+	assert.Equal(t, "setField", caller.calls[0].method)
+	assert.Equal(t, FieldParams{
+		ObjectID:  obj.ID(),
+		Component: "Greeter",
+		Path:      "Greeting",
+		Value:     "hi",
+	}, caller.calls[0].params)
+	assert.Equal(t, "enable", caller.calls[1].method)
+	assert.Equal(t, "hi", caller.calls[1].params.(ComponentParams).Value["Greeting"])
+	assert.Equal(t, "callMethod", caller.calls[2].method)
+
+	snapshot := com.Snapshot()
+	assert.Equal(t, "remote/greeter.Greeter", snapshot.Type)
+
+	t.Run("reregister", func(t *testing.T) {
+		p.disconnect()
+		replaced, err := p.register(greeter)
+		require.NoError(t, err)
+		assert.Nil(t, replaced)
+
+		changed := greeter
+		changed.Fields = append(changed.Fields, FieldSchema{Name: "Name", Type: "string"})
+		replaced, err = p.register(changed)
+		require.NoError(t, err)
+		require.NotNil(t, replaced)
+		assert.Equal(t, replaced, library.Lookup("remote/greeter.Greeter"))
+		assert.Equal(t, 4, replaced.Type.NumField())
+
+		require.NoError(t, library.Swap(com, replaced))
+		p.connect(caller)
+		require.NoError(t, com.SetField("Name", "world"))
+		v, _, err := com.GetField("Greeting")
+		require.NoError(t, err)
+		assert.Equal(t, "hi", v)
+	})
+}
+
+// Greeter is a Go component with the name of a remote component.
+type Greeter struct {
+	Greeting string
+}
+
+func TestServiceSameName(t *testing.T) {
+	defer library.Isolate()()
+	require.NoError(t, library.Register(&Greeter{}, "", ""))
+	p := &process{
+		name:       "other",
+		path:       "/remote/other",
+		schemas:    make(map[string]Schema),
+		registered: make(chan struct{}),
+	}
+	_, err := p.register(greeter)
+	require.NoError(t, err)
+	caller := &testCaller{}
+	p.connect(caller)
+
+	root := object.New("root")
+	goObj := object.New("go")
+	goCom := library.LookupType(reflect.TypeOf(Greeter{})).New()
+	goObj.AppendComponent(goCom)
+	root.AppendChild(goObj)
+	remoteObj := object.New("remote")
+	remoteCom := library.Lookup("remote/other.Greeter").New()
+	remoteObj.AppendComponent(remoteCom)
+	root.AppendChild(remoteObj)
+	s := &Service{
+		Log:       &null.Logger{},
+		State:     &state.Service{Root: root},
+		processes: map[string]*process{p.name: p},
+	}
+
+	changed := greeter
+	changed.Fields = append(changed.Fields, FieldSchema{Name: "Name", Type: "string"})
+	replaced, err := p.register(changed)
+	require.NoError(t, err)
+	require.NotNil(t, replaced)
+	s.swap(map[string]*library.RegisteredComponent{replaced.Name: replaced})
+	assert.IsType(t, &Greeter{}, goCom.Pointer())
+	assert.Equal(t, "remote/other.Greeter", library.TypeName(remoteCom))
+	_, _, err = remoteCom.GetField("Name")
+	assert.NoError(t, err)
+
+	caller.calls = nil
+	s.resync(p)
+	require.Len(t, caller.calls, 1)
+	assert.Equal(t, "enable", caller.calls[0].method)
+	assert.Equal(t, remoteObj.ID(), caller.calls[0].params.(ComponentParams).ObjectID)
+}
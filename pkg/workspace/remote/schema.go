@@ -0,0 +1,90 @@
+package remote
+
+import (
+	"fmt"
+	"reflect"
+	"unicode"
+)
+
+// Schema describes a component type provided by a remote process.
+type Schema struct {
+	Name        string
+	Description string
+	Fields      []FieldSchema
+	Methods     []string
+}
+
+// FieldSchema describes a field of a remote component. Type is one of
+// "string", "number" or "boolean".
+type FieldSchema struct {
+	Name        string
+	Type        string
+	Description string
+}
+
+var fieldTypes = map[string]reflect.Type{
+	"string":  reflect.TypeOf(""),
+	"number":  reflect.TypeOf(float64(0)),
+	"boolean": reflect.TypeOf(false),
+}
+
+// structType returns the struct type used for values of the remote component.
+func (s Schema) structType() (reflect.Type, error) {
+	if !isExported(s.Name) {
+		return nil, fmt.Errorf("component name must be an exported identifier: %q", s.Name)
+	}
+	var fields []reflect.StructField
+	seen := make(map[string]bool)
+	for _, f := range s.Fields {
+		if !isExported(f.Name) {
+			return nil, fmt.Errorf("%s: field name must be an exported identifier: %q", s.Name, f.Name)
+		}
+		if seen[f.Name] {
+			return nil, fmt.Errorf("%s: duplicate field: %s", s.Name, f.Name)
+		}
+		seen[f.Name] = true
+		typ, ok := fieldTypes[f.Type]
+		if !ok {
+			return nil, fmt.Errorf("%s: unsupported type for field %s: %q", s.Name, f.Name, f.Type)
+		}
+		fields = append(fields, reflect.StructField{
+			Name: f.Name,
+			Type: typ,
+		})
+	}
+	return reflect.StructOf(fields), nil
+}
+
+func (s Schema) hasMethod(name string) bool {
+	for _, m := range s.Methods {
+		if m == name {
+			return true
+		}
+	}
+	return false
+}
+
+func isExported(name string) bool {
+	for i, r := range name {
+		if i == 0 && !unicode.IsUpper(r) {
+			return false
+		}
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' {
+			return false
+		}
+	}
+	return name != ""
+}
+
+// fieldValues returns the field values of a remote component value.
+func fieldValues(v interface{}) map[string]interface{} {
+	values := make(map[string]interface{})
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if rv.Kind() != reflect.Struct {
+		return values
+	}
+	for i := 0; i < rv.NumField(); i++ {
+		values[rv.Type().Field(i).Name] = rv.Field(i).Interface()
+	}
+	return values
+}
@@ -0,0 +1,275 @@
+// Package remote runs components in separate processes. A remote process is
+// any executable in the workspace remote directory. It is started with the
+// TRACTOR_REMOTE_SOCKET and TRACTOR_REMOTE_NAME environment variables, dials
+// the unix socket with qtalk and calls "register" with its name and component
+// schemas. The workspace then calls back into the process on the same session:
+//
+//	enable      ComponentParams  component was (re)loaded, with all field values
+//	disable     ComponentParams  component is about to be enabled again
+//	setField    FieldParams      a field was changed
+//	callMethod  MethodParams     a method was called, returning its reply
+//
+// Components are registered in the library as remote/<process>.<name>.
+package remote
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/manifold/qtalk/golang/mux"
+	qrpc "github.com/manifold/qtalk/golang/rpc"
+	"github.com/manifold/tractor/pkg/manifold"
+	"github.com/manifold/tractor/pkg/manifold/library"
+	"github.com/manifold/tractor/pkg/misc/logging"
+	"github.com/manifold/tractor/pkg/misc/subcmd"
+	"github.com/manifold/tractor/pkg/workspace/state"
+)
+
+const (
+	DefaultPath = "remote"
+
+	RegisterTimeout = 5 * time.Second
+	RestartDelay    = time.Second
+)
+
+type RegisterParams struct {
+	Name       string
+	Components []Schema
+}
+
+type ComponentParams struct {
+	ObjectID  string
+	Component string
+	Value     map[string]interface{}
+}
+
+type FieldParams struct {
+	ObjectID  string
+	Component string
+	Path      string
+	Value     interface{}
+}
+
+type MethodParams struct {
+	ObjectID  string
+	Component string
+	Method    string
+	Args      []interface{}
+}
+
+// Service supervises remote component processes and proxies their components.
+// It must be initialized before the state service so remote components are
+// registered before the image is loaded.
+type Service struct {
+	Path       string // directory of remote executables (default: ./remote)
+	SocketPath string // unix socket remote processes connect to
+
+	Log   logging.Logger
+	State *state.Service
+
+	processes map[string]*process
+	api       qrpc.API
+	l         mux.Listener
+	stopping  bool
+	mu        sync.Mutex
+}
+
+func (s *Service) InitializeDaemon() (err error) {
+	s.processes = make(map[string]*process)
+	if s.Path == "" {
+		s.Path = DefaultPath
+	}
+	paths, err := executables(s.Path)
+	if err != nil || len(paths) == 0 {
+		return err
+	}
+
+	if s.SocketPath == "" {
+		s.SocketPath = filepath.Join(os.TempDir(), fmt.Sprintf("tractor-remote-%d.sock", os.Getpid()))
+	}
+	if s.l, err = mux.ListenUnix(s.SocketPath); err != nil {
+		return err
+	}
+	s.api = qrpc.NewAPI()
+	s.api.HandleFunc("register", s.Register())
+	go func() {
+		server := &qrpc.Server{}
+		if err := server.Serve(s.l, s.api); err != nil {
+			s.Log.Debug("[remote]", err)
+		}
+	}()
+
+	for _, path := range paths {
+		p := s.newProcess(path)
+		s.processes[p.name] = p
+		if err := p.cmd.Start(); err != nil {
+			s.Log.Info("[remote]", p.name, err)
+		}
+	}
+	timeout := time.After(RegisterTimeout)
+	for _, p := range s.processes {
+		select {
+		case <-p.registered:
+		case <-timeout:
+			s.Log.Info("[remote]", p.name, "did not register in time")
+		}
+	}
+	return nil
+}
+
+func (s *Service) Serve(ctx context.Context) {
+	<-ctx.Done()
+}
+
+func (s *Service) TerminateDaemon() error {
+	s.mu.Lock()
+	s.stopping = true
+	s.mu.Unlock()
+	for _, p := range s.processes {
+		p.cmd.Stop()
+	}
+	if s.l != nil {
+		s.l.Close()
+		os.Remove(s.SocketPath)
+	}
+	return nil
+}
+
+// Register handles the registration of a remote process and its components.
+func (s *Service) Register() func(qrpc.Responder, *qrpc.Call) {
+	return func(r qrpc.Responder, c *qrpc.Call) {
+		var params RegisterParams
+		if err := c.Decode(&params); err != nil {
+			r.Return(err)
+			return
+		}
+		p, ok := s.processes[params.Name]
+		if !ok {
+			r.Return(fmt.Errorf("unknown remote process: %s", params.Name))
+			return
+		}
+		replaced := make(map[string]*library.RegisteredComponent)
+		for _, schema := range params.Components {
+			rc, err := p.register(schema)
+			if err != nil {
+				r.Return(err)
+				return
+			}
+			if rc != nil {
+				replaced[rc.Name] = rc
+			}
+		}
+		// swapped before connecting so the old values aren't disabled in the
+		// new process
+		s.swap(replaced)
+		reconnected := p.connect(c.Caller)
+		if reconnected {
+			s.Log.Info("[remote]", p.name, "reconnected")
+			s.resync(p)
+		}
+		r.Return(nil)
+	}
+}
+
+// swap swaps existing components to the registered components that replaced
+// theirs, by the qualified name of their type.
+func (s *Service) swap(replaced map[string]*library.RegisteredComponent) {
+	if len(replaced) == 0 || s.State == nil || s.State.Root == nil {
+		return
+	}
+	manifold.Walk(s.State.Root, func(o manifold.Object) {
+		for _, com := range o.Components() {
+			rc, ok := replaced[library.TypeName(com)]
+			if !ok {
+				continue
+			}
+			if err := library.Swap(com, rc); err != nil {
+				s.Log.Info("[remote]", com.Name(), err)
+			}
+		}
+	})
+}
+
+// resync enables existing components of a process after it was restarted.
+func (s *Service) resync(p *process) {
+	if s.State == nil || s.State.Root == nil {
+		return
+	}
+	manifold.Walk(s.State.Root, func(o manifold.Object) {
+		for _, com := range o.Components() {
+			if !p.owns(com) || !com.Enabled() {
+				continue
+			}
+			if err := p.Enable(com); err != nil {
+				s.Log.Info("[remote]", p.name, err)
+			}
+		}
+	})
+}
+
+func (s *Service) newProcess(path string) *process {
+	name := filepath.Base(path)
+	p := &process{
+		name:       name,
+		path:       path,
+		schemas:    make(map[string]Schema),
+		registered: make(chan struct{}),
+		cmd:        subcmd.New(path),
+	}
+	p.cmd.Setup = func(cmd *exec.Cmd) error {
+		cmd.Env = append(os.Environ(),
+			"TRACTOR_REMOTE_SOCKET="+s.SocketPath,
+			"TRACTOR_REMOTE_NAME="+name)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		return nil
+	}
+	p.cmd.Observe(func(cmd *subcmd.Subcmd, status subcmd.Status) {
+		if status != subcmd.StatusExited {
+			return
+		}
+		p.disconnect()
+		// subcmd only restarts processes that exit cleanly
+		if err := cmd.Error(); err != nil {
+			s.Log.Info("[remote]", name, "exited:", err)
+			go func() {
+				time.Sleep(RestartDelay)
+				s.mu.Lock()
+				stopping := s.stopping
+				s.mu.Unlock()
+				if !stopping {
+					cmd.Start()
+				}
+			}()
+		}
+	})
+	return p
+}
+
+func executables(dir string) ([]string, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, entry := range entries {
+		if entry.IsDir() || entry.Mode()&0111 == 0 {
+			continue
+		}
+		path, err := filepath.Abs(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
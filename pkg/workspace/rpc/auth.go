@@ -0,0 +1,80 @@
+package rpc
+
+import (
+	"time"
+
+	qrpc "github.com/manifold/qtalk/golang/rpc"
+	"github.com/manifold/tractor/pkg/misc/auth"
+)
+
+// handle registers a handler that is only served to callers authenticated
+// with a role allowing the required role.
+func (s *Service) handle(name string, required auth.Role, h func(qrpc.Responder, *qrpc.Call)) {
+	s.api.HandleFunc(name, handler(s.authorize(required, h)))
+}
+
+// authorize wraps a handler to check the role of the caller. All calls are
+// allowed if the service has no auth key.
+func (s *Service) authorize(required auth.Role, h func(qrpc.Responder, *qrpc.Call)) func(qrpc.Responder, *qrpc.Call) {
+	return func(r qrpc.Responder, c *qrpc.Call) {
+		if s.authKey != nil {
+			s.mu.Lock()
+			cl := s.clients[c.Caller]
+			var claims auth.Claims
+			if cl != nil {
+				claims = cl.claims
+			}
+			s.mu.Unlock()
+			if !claims.Role.Valid() {
+				r.Return(errorf(Unauthenticated, "authenticate before calling %s", c.Destination))
+				return
+			}
+			if claims.Expires != 0 && time.Now().Unix() >= claims.Expires {
+				r.Return(errorf(Unauthenticated, "%v", auth.ErrExpiredToken))
+				return
+			}
+			if !claims.Role.Allows(required) {
+				r.Return(errorf(PermissionDenied, "%s requires the %s role", c.Destination, required))
+				return
+			}
+		}
+		h(r, c)
+	}
+}
+
+// Authenticate verifies the token of the caller, which has to be the first
+// call of a client. It replies with the role granted by the token.
+func (s *Service) Authenticate() func(qrpc.Responder, *qrpc.Call) {
+	return func(r qrpc.Responder, c *qrpc.Call) {
+		var token string
+		if err := c.Decode(&token); err != nil {
+			r.Return(decodeError(err))
+			return
+		}
+		role, err := s.authenticate(c.Caller, token)
+		if err != nil {
+			r.Return(err)
+			return
+		}
+		r.Return(role)
+	}
+}
+
+func (s *Service) authenticate(caller qrpc.Caller, token string) (auth.Role, error) {
+	claims := auth.Claims{Role: auth.Editor}
+	if s.authKey != nil {
+		var err error
+		claims, err = auth.Verify(s.authKey, token)
+		if err != nil {
+			return "", errorf(Unauthenticated, "%v", err)
+		}
+		if !claims.AllowsWorkspace(s.Workspace) {
+			return "", errorf(PermissionDenied, "token is not valid for workspace %q", s.Workspace)
+		}
+	}
+	cl := s.client(caller)
+	s.mu.Lock()
+	cl.claims = claims
+	s.mu.Unlock()
+	return claims.Role, nil
+}
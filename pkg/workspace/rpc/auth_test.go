@@ -0,0 +1,73 @@
+package rpc
+
+import (
+	"testing"
+	"time"
+
+	qrpc "github.com/manifold/qtalk/golang/rpc"
+	"github.com/manifold/tractor/pkg/misc/auth"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testCaller struct{}
+
+func (c *testCaller) Call(path string, args, reply interface{}) (*qrpc.Response, error) {
+	return &qrpc.Response{}, nil
+}
+
+func TestAuthorize(t *testing.T) {
+	key := []byte("0123456789abcdef0123456789abcdef")
+	s := &Service{
+		Workspace: "ws",
+		authKey:   key,
+		clients:   make(map[qrpc.Caller]*client),
+	}
+	caller := &testCaller{}
+	call := func(required auth.Role) interface{} {
+		r := &testResponder{}
+		h := s.authorize(required, func(r qrpc.Responder, c *qrpc.Call) {
+			r.Return("ok")
+		})
+		handler(h)(r, &qrpc.Call{Destination: "test", Caller: caller})
+		require.Len(t, r.replies, 1)
+		return r.replies[0]
+	}
+	authenticate := func(claims auth.Claims) interface{} {
+		token, err := auth.Issue(key, claims)
+		require.NoError(t, err)
+		role, err := s.authenticate(caller, token)
+		if err != nil {
+			return err
+		}
+		return role
+	}
+	code := func(v interface{}) ErrorCode {
+		err, ok := v.(*Error)
+		require.True(t, ok, "expected error, got %v", v)
+		return err.Code
+	}
+
+	assert.Equal(t, Unauthenticated, code(call(auth.Viewer)))
+
+	assert.Equal(t, PermissionDenied, code(authenticate(auth.Claims{Role: auth.Editor, Workspace: "other"})))
+	assert.Equal(t, Unauthenticated, code(call(auth.Viewer)))
+
+	assert.Equal(t, auth.Viewer, authenticate(auth.Claims{Role: auth.Viewer, Workspace: "ws"}))
+	assert.Equal(t, "ok", call(auth.Viewer))
+	assert.Equal(t, PermissionDenied, code(call(auth.Invoker)))
+	assert.Equal(t, PermissionDenied, code(call(auth.Editor)))
+
+	assert.Equal(t, auth.Invoker, authenticate(auth.Claims{Role: auth.Invoker}))
+	assert.Equal(t, "ok", call(auth.Invoker))
+	assert.Equal(t, PermissionDenied, code(call(auth.Editor)))
+
+	assert.Equal(t, auth.Editor, authenticate(auth.Claims{Role: auth.Editor, Expires: time.Now().Add(time.Minute).Unix()}))
+	assert.Equal(t, "ok", call(auth.Editor))
+
+	s.clients[caller].claims.Expires = time.Now().Add(-time.Second).Unix()
+	assert.Equal(t, Unauthenticated, code(call(auth.Viewer)))
+
+	s.authKey = nil
+	assert.Equal(t, "ok", call(auth.Editor))
+}
@@ -0,0 +1,101 @@
+package rpc
+
+import (
+	"sort"
+
+	qrpc "github.com/manifold/qtalk/golang/rpc"
+	"github.com/manifold/tractor/pkg/misc/auth"
+	"github.com/manifold/tractor/pkg/workspace/view"
+)
+
+// client is a connection to the workspace with its own view session. Clients
+// that subscribed get the full view state with the "state" callback. Clients
+// subscribed to patches then get updates with the "patch" callback, others
+// the full state again. Claims are set once the client authenticated.
+type client struct {
+	caller     qrpc.Caller
+	session    *view.Session
+	claims     auth.Claims
+	subscribed bool
+	patches    bool
+}
+
+// client returns the client of a caller, adding it if it is new. The client
+// is removed when the session of the caller ends.
+func (s *Service) client(caller qrpc.Caller) *client {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.clients[caller]
+	if !ok {
+		c = &client{
+			caller:  caller,
+			session: view.NewSession(""),
+		}
+		s.clients[caller] = c
+		if closed := CallerClosed(caller); closed != nil {
+			go func() {
+				<-closed
+				s.removeClient(caller)
+				// update the presence of the remaining clients
+				s.updateView()
+			}()
+		}
+	}
+	return c
+}
+
+// CallerClosed returns a channel that is closed when the session of the
+// caller ends. It returns nil for callers without a session.
+func CallerClosed(caller qrpc.Caller) <-chan struct{} {
+	cl, ok := caller.(*qrpc.Client)
+	if !ok || cl.Session == nil {
+		return nil
+	}
+	closed := make(chan struct{})
+	go func() {
+		cl.Session.Wait()
+		close(closed)
+	}()
+	return closed
+}
+
+func (s *Service) removeClient(caller qrpc.Caller) {
+	s.mu.Lock()
+	delete(s.clients, caller)
+	s.mu.Unlock()
+}
+
+// subscribed returns the subscribed clients. It must be called with mu held.
+func (s *Service) subscribed() []*client {
+	var clients []*client
+	for _, c := range s.clients {
+		if c.subscribed {
+			clients = append(clients, c)
+		}
+	}
+	return clients
+}
+
+// currentPresence returns the presence of the subscribed clients sorted by
+// session. It must be called with mu held.
+func (s *Service) currentPresence() []view.Presence {
+	presence := []view.Presence{}
+	for _, c := range s.subscribed() {
+		presence = append(presence, c.session.Presence())
+	}
+	sort.Slice(presence, func(i, j int) bool {
+		return presence[i].Session < presence[j].Session
+	})
+	return presence
+}
+
+// sendState sends the full view state to a client.
+func (s *Service) sendState(c *client) error {
+	s.updateMu.Lock()
+	defer s.updateMu.Unlock()
+	s.mu.Lock()
+	presence := s.currentPresence()
+	s.mu.Unlock()
+	_, err := c.caller.Call("state", s.viewState.ClientState(c.session, presence), nil)
+	return err
+}
@@ -0,0 +1,80 @@
+package rpc
+
+import (
+	"fmt"
+	"log"
+	"runtime/debug"
+
+	"github.com/manifold/qtalk/golang/mux"
+	qrpc "github.com/manifold/qtalk/golang/rpc"
+)
+
+// ErrorCode classifies errors returned by handlers.
+type ErrorCode string
+
+const (
+	NotFound        ErrorCode = "not_found"
+	InvalidArgument ErrorCode = "invalid_argument"
+	Conflict        ErrorCode = "conflict"
+	Internal        ErrorCode = "internal"
+
+	Unauthenticated  ErrorCode = "unauthenticated"
+	PermissionDenied ErrorCode = "permission_denied"
+)
+
+// Error is returned by handlers for failed calls. Clients receive the string
+// "<code>: <message>" as the error of the call.
+type Error struct {
+	Code    ErrorCode `json:"code"`
+	Message string    `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+func errorf(code ErrorCode, format string, args ...interface{}) *Error {
+	return &Error{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// responder makes sure a call is replied to exactly once and that errors
+// replied with are an *Error.
+type responder struct {
+	qrpc.Responder
+	done bool
+}
+
+func (r *responder) Return(v interface{}) error {
+	if r.done {
+		return nil
+	}
+	r.done = true
+	if err, ok := v.(error); ok {
+		if _, ok := err.(*Error); !ok {
+			v = &Error{Code: Internal, Message: err.Error()}
+		}
+	}
+	return r.Responder.Return(v)
+}
+
+func (r *responder) Hijack(v interface{}) (mux.Channel, error) {
+	r.done = true
+	return r.Responder.Hijack(v)
+}
+
+// handler wraps a handler to recover from panics, replying with an internal
+// error, and to reply to calls the handler did not reply to.
+func handler(h func(qrpc.Responder, *qrpc.Call)) func(qrpc.Responder, *qrpc.Call) {
+	return func(r qrpc.Responder, c *qrpc.Call) {
+		resp := &responder{Responder: r}
+		defer func() {
+			if v := recover(); v != nil {
+				log.Printf("panic in %s: %v\n%s", c.Destination, v, debug.Stack())
+				resp.Return(errorf(Internal, "%v", v))
+				return
+			}
+			resp.Return(nil)
+		}()
+		h(resp, c)
+	}
+}
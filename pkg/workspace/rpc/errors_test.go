@@ -0,0 +1,95 @@
+package rpc
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/manifold/qtalk/golang/mux"
+	qrpc "github.com/manifold/qtalk/golang/rpc"
+	"github.com/manifold/tractor/pkg/manifold/library"
+	"github.com/manifold/tractor/pkg/manifold/object"
+	"github.com/manifold/tractor/pkg/workspace/state"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testResponder struct {
+	replies []interface{}
+}
+
+func (r *testResponder) Header() *qrpc.ResponseHeader {
+	return &qrpc.ResponseHeader{}
+}
+
+func (r *testResponder) Return(v interface{}) error {
+	r.replies = append(r.replies, v)
+	return nil
+}
+
+func (r *testResponder) Hijack(v interface{}) (mux.Channel, error) {
+	return nil, nil
+}
+
+func serve(h func(qrpc.Responder, *qrpc.Call)) []interface{} {
+	r := &testResponder{}
+	handler(h)(r, &qrpc.Call{Destination: "test"})
+	return r.replies
+}
+
+func TestHandler(t *testing.T) {
+	replies := serve(func(r qrpc.Responder, c *qrpc.Call) {})
+	assert.Equal(t, []interface{}{nil}, replies)
+
+	replies = serve(func(r qrpc.Responder, c *qrpc.Call) {
+		r.Return("ok")
+		r.Return("again")
+	})
+	assert.Equal(t, []interface{}{"ok"}, replies)
+
+	replies = serve(func(r qrpc.Responder, c *qrpc.Call) {
+		r.Return(errors.New("failed"))
+	})
+	assert.Equal(t, []interface{}{&Error{Code: Internal, Message: "failed"}}, replies)
+
+	replies = serve(func(r qrpc.Responder, c *qrpc.Call) {
+		r.Return(errorf(NotFound, "unable to find node: %s", "x"))
+	})
+	require.Len(t, replies, 1)
+	assert.EqualError(t, replies[0].(error), "not_found: unable to find node: x")
+
+	replies = serve(func(r qrpc.Responder, c *qrpc.Call) {
+		panic("boom")
+	})
+	assert.Equal(t, []interface{}{&Error{Code: Internal, Message: "boom"}}, replies)
+}
+
+func TestFindPath(t *testing.T) {
+	root := object.New("::root")
+	node := object.New("Node")
+	root.AppendChild(node)
+	node.AppendComponent(library.NewComponent("Comp", &struct{ Field string }{}, ""))
+	s := &Service{State: &state.Service{Root: root}}
+
+	n, localPath, err := s.findPath("/Node/Comp/Field")
+	require.NoError(t, err)
+	assert.Equal(t, node, n)
+	assert.Equal(t, "Comp/Field", localPath)
+
+	root.AppendComponent(library.NewComponent("Root", &struct{ Field string }{}, ""))
+	n, localPath, err = s.findPath("/Root/Field")
+	require.NoError(t, err)
+	assert.Equal(t, root, n)
+	assert.Equal(t, "Root/Field", localPath)
+
+	for path, code := range map[string]ErrorCode{
+		"":                InvalidArgument,
+		"/Missing/Comp/X": NotFound,
+		"/Node/Other/X":   NotFound,
+		"/Node/Comp":      InvalidArgument,
+		"/Node":           InvalidArgument,
+	} {
+		_, _, err := s.findPath(path)
+		require.Error(t, err, path)
+		assert.Equal(t, code, err.(*Error).Code, path)
+	}
+}
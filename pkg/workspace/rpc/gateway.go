@@ -0,0 +1,491 @@
+package rpc
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"reflect"
+	"runtime/debug"
+	"strings"
+
+	"github.com/manifold/tractor/pkg/manifold"
+	"github.com/manifold/tractor/pkg/misc/auth"
+	"github.com/manifold/tractor/pkg/workspace/state"
+	"github.com/manifold/tractor/pkg/workspace/view"
+)
+
+// OpenAPIPath is the path the gateway serves its OpenAPI document at.
+const OpenAPIPath = "/openapi.json"
+
+type CreateNodeRequest struct {
+	Name string `json:"name"`
+}
+
+// UpdateNodeRequest renames a node or moves it to Index among its siblings.
+// With Parent it moves the node to Index among the children of the parent,
+// or appends it when Index is not set.
+type UpdateNodeRequest struct {
+	Name   *string `json:"name,omitempty"`
+	Index  *int    `json:"index,omitempty"`
+	Parent *string `json:"parent,omitempty"`
+}
+
+type AddComponentRequest struct {
+	Name string `json:"name"`
+}
+
+// SetFieldRequest sets a field to Value, or to the component or object value
+// at the path of Ref.
+type SetFieldRequest struct {
+	Value json.RawMessage `json:"value,omitempty"`
+	Ref   *string         `json:"ref,omitempty"`
+}
+
+// route is a REST resource of the gateway. The last segment of path matches
+// the rest of the request path if its parameter name ends with "...".
+type route struct {
+	method  string
+	path    string
+	summary string
+	role    auth.Role
+	body    interface{} // request body type, nil if none
+	reply   interface{} // reply type, nil if none
+	serve   func(s *Service, params map[string]string, decode func(interface{}) error) (interface{}, error)
+}
+
+var routes = []route{
+	{
+		method:  "GET",
+		path:    "/health",
+		summary: "Get the health of the workspace",
+		role:    auth.Viewer,
+		reply:   state.Health{},
+		serve: func(s *Service, p map[string]string, decode func(interface{}) error) (interface{}, error) {
+			return s.State.Health(), nil
+		},
+	},
+	{
+		method:  "GET",
+		path:    "/nodes",
+		summary: "List all nodes in tree order",
+		role:    auth.Viewer,
+		reply:   []view.Node{},
+		serve: func(s *Service, p map[string]string, decode func(interface{}) error) (interface{}, error) {
+			nodes := []view.Node{}
+			manifold.Walk(s.State.Root, func(n manifold.Object) {
+				nodes = append(nodes, view.ExportNode(n))
+			})
+			return nodes, nil
+		},
+	},
+	{
+		method:  "GET",
+		path:    "/nodes/{id}",
+		summary: "Get a node",
+		role:    auth.Viewer,
+		reply:   view.Node{},
+		serve: func(s *Service, p map[string]string, decode func(interface{}) error) (interface{}, error) {
+			n, err := s.findNode(p["id"])
+			if err != nil {
+				return nil, err
+			}
+			return view.ExportNode(n), nil
+		},
+	},
+	{
+		method:  "GET",
+		path:    "/nodes/{id}/children",
+		summary: "List the children of a node",
+		role:    auth.Viewer,
+		reply:   []NodeInfo{},
+		serve: func(s *Service, p map[string]string, decode func(interface{}) error) (interface{}, error) {
+			if _, err := s.findNode(p["id"]); err != nil {
+				return nil, err
+			}
+			return s.listChildren(p["id"])
+		},
+	},
+	{
+		method:  "GET",
+		path:    "/nodes/{id}/components",
+		summary: "List the components of a node",
+		role:    auth.Viewer,
+		reply:   []ComponentInfo{},
+		serve: func(s *Service, p map[string]string, decode func(interface{}) error) (interface{}, error) {
+			if _, err := s.findNode(p["id"]); err != nil {
+				return nil, err
+			}
+			return s.listComponents(p["id"])
+		},
+	},
+	{
+		method:  "GET",
+		path:    "/nodes/{id}/snapshot",
+		summary: "Get snapshots of a node and its descendants",
+		role:    auth.Viewer,
+		reply:   []manifold.ObjectSnapshot{},
+		serve: func(s *Service, p map[string]string, decode func(interface{}) error) (interface{}, error) {
+			if _, err := s.findNode(p["id"]); err != nil {
+				return nil, err
+			}
+			return s.getSnapshot(p["id"])
+		},
+	},
+	{
+		method:  "GET",
+		path:    "/nodes/{id}/components/{component}/fields/{field...}",
+		summary: "Get a field of a component, nested fields are separated by slashes",
+		role:    auth.Viewer,
+		reply:   FieldValue{},
+		serve: func(s *Service, p map[string]string, decode func(interface{}) error) (interface{}, error) {
+			n, err := s.findNode(p["id"])
+			if err != nil {
+				return nil, err
+			}
+			return s.getField(n, p["component"], p["field"])
+		},
+	},
+	{
+		method:  "GET",
+		path:    "/components",
+		summary: "List the registered component types",
+		role:    auth.Viewer,
+		reply:   []view.ComponentType{},
+		serve: func(s *Service, p map[string]string, decode func(interface{}) error) (interface{}, error) {
+			return view.RegisteredComponents(), nil
+		},
+	},
+	{
+		method:  "GET",
+		path:    "/paths/{path...}",
+		summary: "Resolve a path to a node and the path of a component, field or method of it",
+		role:    auth.Viewer,
+		reply:   ResolvedPath{},
+		serve: func(s *Service, p map[string]string, decode func(interface{}) error) (interface{}, error) {
+			return s.resolvePath(p["path"])
+		},
+	},
+	{
+		method:  "PATCH",
+		path:    "/nodes/{id}",
+		summary: "Rename a node or move it to another index or parent",
+		role:    auth.Editor,
+		body:    UpdateNodeRequest{},
+		reply:   view.Node{},
+		serve: func(s *Service, p map[string]string, decode func(interface{}) error) (interface{}, error) {
+			var req UpdateNodeRequest
+			if err := decode(&req); err != nil {
+				return nil, err
+			}
+			if err := s.updateNode(NodeParams{ID: p["id"], Name: req.Name}); err != nil {
+				return nil, err
+			}
+			if req.Parent != nil && req.Index == nil {
+				n, err := s.findNode(p["id"])
+				if err != nil {
+					return nil, err
+				}
+				parent, err := s.findParent(*req.Parent)
+				if err != nil {
+					return nil, err
+				}
+				index := len(parent.Children())
+				if n.Parent() == parent {
+					index--
+				}
+				req.Index = &index
+			}
+			if req.Index != nil {
+				if err := s.moveNode(p["id"], req.Parent, *req.Index); err != nil {
+					return nil, err
+				}
+			}
+			return nodeReply(s, p["id"])
+		},
+	},
+	{
+		method:  "DELETE",
+		path:    "/nodes/{id}",
+		summary: "Delete a node",
+		role:    auth.Editor,
+		serve: func(s *Service, p map[string]string, decode func(interface{}) error) (interface{}, error) {
+			return nil, s.deleteNode(p["id"])
+		},
+	},
+	{
+		method:  "POST",
+		path:    "/nodes/{id}/children",
+		summary: "Append a new node to a node",
+		role:    auth.Editor,
+		body:    CreateNodeRequest{},
+		reply:   view.Node{},
+		serve: func(s *Service, p map[string]string, decode func(interface{}) error) (interface{}, error) {
+			var req CreateNodeRequest
+			if err := decode(&req); err != nil {
+				return nil, err
+			}
+			if _, err := s.findNode(p["id"]); err != nil {
+				return nil, err
+			}
+			n, err := s.appendNode(p["id"], req.Name)
+			if err != nil {
+				return nil, err
+			}
+			return view.ExportNode(n), nil
+		},
+	},
+	{
+		method:  "POST",
+		path:    "/nodes/{id}/components",
+		summary: "Add a registered component to a node",
+		role:    auth.Editor,
+		body:    AddComponentRequest{},
+		reply:   view.Node{},
+		serve: func(s *Service, p map[string]string, decode func(interface{}) error) (interface{}, error) {
+			var req AddComponentRequest
+			if err := decode(&req); err != nil {
+				return nil, err
+			}
+			if _, err := s.findNode(p["id"]); err != nil {
+				return nil, err
+			}
+			if err := s.appendComponent(p["id"], req.Name); err != nil {
+				return nil, err
+			}
+			return nodeReply(s, p["id"])
+		},
+	},
+	{
+		method:  "DELETE",
+		path:    "/nodes/{id}/components/{component}",
+		summary: "Remove a component from a node",
+		role:    auth.Editor,
+		serve: func(s *Service, p map[string]string, decode func(interface{}) error) (interface{}, error) {
+			return nil, s.removeComponent(p["id"], p["component"])
+		},
+	},
+	{
+		method:  "POST",
+		path:    "/nodes/{id}/components/{component}/reload",
+		summary: "Reload a component",
+		role:    auth.Invoker,
+		serve: func(s *Service, p map[string]string, decode func(interface{}) error) (interface{}, error) {
+			return nil, s.reloadComponent(p["id"], p["component"])
+		},
+	},
+	{
+		method:  "PATCH",
+		path:    "/nodes/{id}/components/{component}/fields/{field...}",
+		summary: "Set a field of a component, nested fields are separated by slashes",
+		role:    auth.Editor,
+		body:    SetFieldRequest{},
+		reply:   view.Node{},
+		serve: func(s *Service, p map[string]string, decode func(interface{}) error) (interface{}, error) {
+			var req SetFieldRequest
+			if err := decode(&req); err != nil {
+				return nil, err
+			}
+			if err := s.setField(p["id"], p["component"], p["field"], req); err != nil {
+				return nil, err
+			}
+			return nodeReply(s, p["id"])
+		},
+	},
+	{
+		method:  "POST",
+		path:    "/nodes/{id}/components/{component}/methods/{method}",
+		summary: "Call a method of a component",
+		role:    auth.Invoker,
+		serve: func(s *Service, p map[string]string, decode func(interface{}) error) (interface{}, error) {
+			n, err := s.findNode(p["id"])
+			if err != nil {
+				return nil, err
+			}
+			if n.Component(p["component"]) == nil {
+				return nil, errorf(NotFound, "unable to find component: %s", p["component"])
+			}
+			return nil, s.callMethod(n, p["component"]+"/"+p["method"])
+		},
+	},
+}
+
+func nodeReply(s *Service, id string) (interface{}, error) {
+	n, err := s.findNode(id)
+	if err != nil {
+		return nil, err
+	}
+	return view.ExportNode(n), nil
+}
+
+// setField sets a field to the JSON value or reference of a request.
+func (s *Service) setField(id, component, field string, req SetFieldRequest) error {
+	n, err := s.findNode(id)
+	if err != nil {
+		return err
+	}
+	com := n.Component(component)
+	if com == nil {
+		return errorf(NotFound, "unable to find component: %s", component)
+	}
+	localPath := component + "/" + field
+	if req.Ref != nil {
+		return s.setValue(n, localPath, SetValueParams{RefValue: req.Ref})
+	}
+	if len(req.Value) == 0 {
+		return errorf(InvalidArgument, "value or ref is required")
+	}
+	value := string(req.Value)
+	return s.setValue(n, localPath, SetValueParams{JSONValue: &value})
+}
+
+func fieldType(com manifold.Component, field string) (typ reflect.Type, err error) {
+	defer func() {
+		// nested fields of unknown fields panic
+		if recover() != nil {
+			typ, err = nil, errorf(NotFound, "unable to find field: %s", field)
+		}
+	}()
+	typ = com.FieldType(field)
+	if typ == nil {
+		return nil, errorf(NotFound, "unable to find field: %s", field)
+	}
+	return typ, nil
+}
+
+// Gateway returns an HTTP handler that exposes the object tree as REST
+// resources. Resources are served by the same operations as the RPC handlers
+// and documented by an OpenAPI document at OpenAPIPath. Clients authenticate
+// with a bearer token.
+func (s *Service) Gateway() http.Handler {
+	return http.HandlerFunc(s.serveHTTP)
+}
+
+func (s *Service) serveHTTP(w http.ResponseWriter, req *http.Request) {
+	defer func() {
+		if v := recover(); v != nil {
+			log.Printf("panic in %s %s: %v\n%s", req.Method, req.URL.Path, v, debug.Stack())
+			writeError(w, errorf(Internal, "%v", v))
+		}
+	}()
+
+	if req.Method == "GET" && req.URL.Path == OpenAPIPath {
+		writeJSON(w, http.StatusOK, openAPI())
+		return
+	}
+
+	var pathFound bool
+	for _, rt := range routes {
+		params, ok := matchPath(rt.path, req.URL.Path)
+		if !ok {
+			continue
+		}
+		pathFound = true
+		if rt.method != req.Method {
+			continue
+		}
+		if err := s.authorizeRequest(req, rt.role); err != nil {
+			writeError(w, err)
+			return
+		}
+		reply, err := rt.serve(s, params, func(v interface{}) error {
+			b, err := ioutil.ReadAll(req.Body)
+			if err != nil {
+				return errorf(InvalidArgument, "unable to read body: %v", err)
+			}
+			if err := json.Unmarshal(b, v); err != nil {
+				return errorf(InvalidArgument, "unable to decode body: %v", err)
+			}
+			return nil
+		})
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		if reply == nil {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		writeJSON(w, http.StatusOK, reply)
+		return
+	}
+	if pathFound {
+		writeJSON(w, http.StatusMethodNotAllowed, &Error{Code: "method_not_allowed", Message: req.Method})
+		return
+	}
+	writeError(w, errorf(NotFound, "unknown resource: %s", req.URL.Path))
+}
+
+// authorizeRequest checks the bearer token of a request.
+func (s *Service) authorizeRequest(req *http.Request, required auth.Role) error {
+	if s.authKey == nil {
+		return nil
+	}
+	token := strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")
+	if token == "" {
+		return errorf(Unauthenticated, "bearer token is required")
+	}
+	claims, err := auth.Verify(s.authKey, token)
+	if err != nil {
+		return errorf(Unauthenticated, "%v", err)
+	}
+	if !claims.AllowsWorkspace(s.Workspace) {
+		return errorf(PermissionDenied, "token is not valid for workspace %q", s.Workspace)
+	}
+	if !claims.Role.Allows(required) {
+		return errorf(PermissionDenied, "%s %s requires the %s role", req.Method, req.URL.Path, required)
+	}
+	return nil
+}
+
+// matchPath matches a request path to a route path and returns the values of
+// its parameters.
+func matchPath(pattern, path string) (map[string]string, bool) {
+	patterns := strings.Split(strings.Trim(pattern, "/"), "/")
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	params := make(map[string]string)
+	for i, p := range patterns {
+		if i >= len(parts) || parts[i] == "" {
+			return nil, false
+		}
+		if !strings.HasPrefix(p, "{") {
+			if p != parts[i] {
+				return nil, false
+			}
+			continue
+		}
+		name := strings.Trim(p, "{}")
+		if strings.HasSuffix(name, "...") {
+			params[strings.TrimSuffix(name, "...")] = strings.Join(parts[i:], "/")
+			return params, true
+		}
+		params[name] = parts[i]
+	}
+	return params, len(parts) == len(patterns)
+}
+
+var statusCodes = map[ErrorCode]int{
+	NotFound:         http.StatusNotFound,
+	InvalidArgument:  http.StatusBadRequest,
+	Conflict:         http.StatusConflict,
+	Internal:         http.StatusInternalServerError,
+	Unauthenticated:  http.StatusUnauthorized,
+	PermissionDenied: http.StatusForbidden,
+}
+
+// writeError writes an error as JSON with the status code of its ErrorCode.
+func writeError(w http.ResponseWriter, err error) {
+	e, ok := err.(*Error)
+	if !ok {
+		e = &Error{Code: Internal, Message: err.Error()}
+	}
+	writeJSON(w, statusCodes[e.Code], e)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Println(err)
+	}
+}
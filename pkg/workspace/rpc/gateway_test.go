@@ -0,0 +1,329 @@
+package rpc
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	qrpc "github.com/manifold/qtalk/golang/rpc"
+	"github.com/manifold/tractor/pkg/manifold/library"
+	"github.com/manifold/tractor/pkg/manifold/object"
+	"github.com/manifold/tractor/pkg/misc/auth"
+	"github.com/manifold/tractor/pkg/workspace/state"
+	"github.com/manifold/tractor/pkg/workspace/view"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type counter struct {
+	Count int
+	Label string
+}
+
+func (c *counter) Increment() {
+	c.Count++
+}
+
+func newGatewayService() (*Service, *counter, string) {
+	root := object.New("::root")
+	node := object.New("Node")
+	root.AppendChild(node)
+	c := &counter{}
+	node.AppendComponent(library.NewComponent("Counter", c, ""))
+	return &Service{
+		State:     &state.Service{Root: root},
+		viewState: view.New(root),
+		clients:   make(map[qrpc.Caller]*client),
+	}, c, node.ID()
+}
+
+func request(s *Service, method, path, body, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	s.Gateway().ServeHTTP(w, req)
+	return w
+}
+
+func TestGateway(t *testing.T) {
+	s, c, id := newGatewayService()
+
+	w := request(s, "GET", "/nodes/"+id, "", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var node view.Node
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &node))
+	assert.Equal(t, "Node", node.Name)
+	require.Len(t, node.Components, 1)
+	assert.Equal(t, "Counter", node.Components[0].Name)
+
+	w = request(s, "PATCH", "/nodes/"+id+"/components/Counter/fields/Count", `{"value": 5}`, "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, 5, c.Count)
+
+	w = request(s, "PATCH", "/nodes/"+id+"/components/Counter/fields/Label", `{"value": 5}`, "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = request(s, "PATCH", "/nodes/"+id+"/components/Counter/fields/Missing", `{"value": 5}`, "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = request(s, "POST", "/nodes/"+id+"/components/Counter/methods/Increment", "", "")
+	require.Equal(t, http.StatusNoContent, w.Code, w.Body.String())
+	assert.Equal(t, 6, c.Count)
+
+	w = request(s, "POST", "/nodes/"+id+"/children", `{"name": "Child"}`, "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &node))
+	assert.Equal(t, "/Node/Child", node.Path)
+
+	w = request(s, "DELETE", "/nodes/"+node.ID, "", "")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	w = request(s, "GET", "/nodes/"+node.ID, "", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	var e Error
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &e))
+	assert.Equal(t, NotFound, e.Code)
+
+	w = request(s, "PUT", "/nodes/"+id, "", "")
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	w = request(s, "GET", "/unknown", "", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestSetValueJSON(t *testing.T) {
+	s, c, id := newGatewayService()
+	n := s.State.Root.FindID(id)
+
+	v := `"label"`
+	require.NoError(t, s.setValue(n, "Counter/Label", SetValueParams{JSONValue: &v}))
+	assert.Equal(t, "label", c.Label)
+
+	v = `7`
+	require.NoError(t, s.setValue(n, "Counter/Count", SetValueParams{JSONValue: &v}))
+	assert.Equal(t, 7, c.Count)
+
+	v = `"seven"`
+	err := s.setValue(n, "Counter/Count", SetValueParams{JSONValue: &v})
+	assert.Equal(t, InvalidArgument, err.(*Error).Code)
+	err = s.setValue(n, "Counter", SetValueParams{JSONValue: &v})
+	assert.Equal(t, InvalidArgument, err.(*Error).Code)
+	err = s.setValue(n, "Other/Count", SetValueParams{JSONValue: &v})
+	assert.Equal(t, NotFound, err.(*Error).Code)
+	n7 := 7
+	err = s.setValue(n, "Other/Count", SetValueParams{IntValue: &n7})
+	assert.Equal(t, NotFound, err.(*Error).Code)
+	err = s.setValue(n, "Other/Count", SetValueParams{Value: 7})
+	assert.Equal(t, NotFound, err.(*Error).Code)
+}
+
+type level string
+
+func (level) EnumValues() []interface{} {
+	return []interface{}{level("low"), level("high")}
+}
+
+type settings struct {
+	Time    time.Time
+	Timeout time.Duration
+	Data    []byte
+	Level   level
+	Items   []counter
+	Options *counter
+}
+
+func TestSetValueKinds(t *testing.T) {
+	s, _, id := newGatewayService()
+	n := s.State.Root.FindID(id)
+	v := &settings{Items: []counter{{}}}
+	n.AppendComponent(library.NewComponent("Settings", v, ""))
+
+	for path, value := range map[string]string{
+		"Settings/Time":          `"2020-01-02T03:04:05Z"`,
+		"Settings/Timeout":       `"1m30s"`,
+		"Settings/Data":          `"aGk="`,
+		"Settings/Level":         `"high"`,
+		"Settings/Items/0/Count": `2`,
+		"Settings/Options":       `{"Label": "o"}`,
+	} {
+		value := value
+		require.NoError(t, s.setValue(n, path, SetValueParams{JSONValue: &value}), path)
+	}
+	assert.Equal(t, time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC), v.Time)
+	assert.Equal(t, 90*time.Second, v.Timeout)
+	assert.Equal(t, []byte("hi"), v.Data)
+	assert.Equal(t, level("high"), v.Level)
+	assert.Equal(t, 2, v.Items[0].Count)
+	assert.Equal(t, &counter{Label: "o"}, v.Options)
+
+	// decoded values from clients are converted to the field type
+	require.NoError(t, s.setValue(n, "Settings/Timeout", SetValueParams{Value: "2s"}))
+	assert.Equal(t, 2*time.Second, v.Timeout)
+	require.NoError(t, s.setValue(n, "Settings/Items/0/Count", SetValueParams{Value: int64(3)}))
+	assert.Equal(t, 3, v.Items[0].Count)
+	require.NoError(t, s.setValue(n, "Settings/Options/Count", SetValueParams{Value: int64(4)}))
+	assert.Equal(t, 4, v.Options.Count)
+
+	err := s.setValue(n, "Settings/Level", SetValueParams{Value: "medium"})
+	assert.Equal(t, InvalidArgument, err.(*Error).Code)
+	err = s.setValue(n, "Settings/Timeout", SetValueParams{Value: "soon"})
+	assert.Equal(t, InvalidArgument, err.(*Error).Code)
+	err = s.setValue(n, "Settings/Items/0/Missing", SetValueParams{Value: 1})
+	assert.Equal(t, NotFound, err.(*Error).Code)
+	err = s.setValue(n, "Settings/Items/5/Count", SetValueParams{Value: int64(1)})
+	assert.Equal(t, InvalidArgument, err.(*Error).Code)
+}
+
+type tagged struct {
+	Mode    string `tractor:"enum=fast|slow"`
+	Percent int    `tractor:"range=0:100"`
+	Color   string `tractor:"color"`
+	ID      string `tractor:"readonly"`
+}
+
+func TestSetValueTags(t *testing.T) {
+	s, _, id := newGatewayService()
+	n := s.State.Root.FindID(id)
+	v := &tagged{}
+	n.AppendComponent(library.NewComponent("Tagged", v, ""))
+
+	require.NoError(t, s.setValue(n, "Tagged/Mode", SetValueParams{Value: "slow"}))
+	percent := 50
+	require.NoError(t, s.setValue(n, "Tagged/Percent", SetValueParams{IntValue: &percent}))
+	color := `"#ff0000"`
+	require.NoError(t, s.setValue(n, "Tagged/Color", SetValueParams{JSONValue: &color}))
+	assert.Equal(t, tagged{Mode: "slow", Percent: 50, Color: "#ff0000"}, *v)
+
+	err := s.setValue(n, "Tagged/Mode", SetValueParams{Value: "medium"})
+	assert.Equal(t, InvalidArgument, err.(*Error).Code)
+	err = s.setValue(n, "Tagged/Percent", SetValueParams{Value: int64(101)})
+	assert.Equal(t, InvalidArgument, err.(*Error).Code)
+	err = s.setValue(n, "Tagged/Color", SetValueParams{Value: "red"})
+	assert.Equal(t, InvalidArgument, err.(*Error).Code)
+	err = s.setValue(n, "Tagged/ID", SetValueParams{Value: "x"})
+	assert.Equal(t, PermissionDenied, err.(*Error).Code)
+	assert.Equal(t, tagged{Mode: "slow", Percent: 50, Color: "#ff0000"}, *v)
+}
+
+type holder struct {
+	Target *counter
+}
+
+func TestSetValueRef(t *testing.T) {
+	s, c, id := newGatewayService()
+	n := s.State.Root.FindID(id)
+	v := &holder{}
+	n.AppendComponent(library.NewComponent("Holder", v, ""))
+
+	ref := "/Node/Counter"
+	require.NoError(t, s.setValue(n, "Holder/Target", SetValueParams{RefValue: &ref}))
+	assert.Equal(t, c, v.Target)
+
+	for _, ref := range []string{"x", "Node/Counter"} {
+		ref := ref
+		err := s.setValue(n, "Holder/Target", SetValueParams{RefValue: &ref})
+		require.Error(t, err, ref)
+		assert.Equal(t, InvalidArgument, err.(*Error).Code, ref)
+	}
+}
+
+func TestMoveNode(t *testing.T) {
+	s, _, id := newGatewayService()
+	root := s.State.Root
+	node := root.FindID(id)
+	other, err := s.appendNode("", "Other")
+	require.NoError(t, err)
+	child, err := s.appendNode(id, "Child")
+	require.NoError(t, err)
+
+	require.NoError(t, s.moveNode(other.ID(), nil, 0))
+	assert.Equal(t, 0, other.SiblingIndex())
+
+	parent := other.ID()
+	require.NoError(t, s.moveNode(child.ID(), &parent, 0))
+	assert.Equal(t, "/Other/Child", child.Path())
+	assert.Empty(t, node.Children())
+
+	for _, target := range []string{id, child.ID()} {
+		parent = target
+		err = s.moveNode(other.ID(), &parent, 0)
+		if target == id {
+			require.NoError(t, err)
+			assert.Equal(t, "/Node/Other", other.Path())
+			continue
+		}
+		assert.Equal(t, InvalidArgument, err.(*Error).Code)
+	}
+	parent = other.ID()
+	err = s.moveNode(id, &parent, 0)
+	assert.Equal(t, InvalidArgument, err.(*Error).Code)
+	err = s.moveNode(child.ID(), &parent, 2)
+	assert.Equal(t, InvalidArgument, err.(*Error).Code)
+	parent = "missing"
+	err = s.moveNode(id, &parent, 0)
+	assert.Equal(t, NotFound, err.(*Error).Code)
+
+	root2 := ""
+	require.NoError(t, s.moveNode(child.ID(), &root2, 1))
+	assert.Equal(t, "/Child", child.Path())
+	assert.Equal(t, 1, child.SiblingIndex())
+
+	w := request(s, "PATCH", "/nodes/"+child.ID(), `{"parent": "`+other.ID()+`"}`, "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "/Node/Other/Child", child.Path())
+}
+
+func TestGatewayAuth(t *testing.T) {
+	s, _, id := newGatewayService()
+	s.authKey = []byte("0123456789abcdef0123456789abcdef")
+	viewer, err := auth.Issue(s.authKey, auth.Claims{Role: auth.Viewer})
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusUnauthorized, request(s, "GET", "/nodes/"+id, "", "").Code)
+	assert.Equal(t, http.StatusUnauthorized, request(s, "GET", "/nodes/"+id, "", "invalid").Code)
+	assert.Equal(t, http.StatusOK, request(s, "GET", "/nodes/"+id, "", viewer).Code)
+	assert.Equal(t, http.StatusForbidden, request(s, "DELETE", "/nodes/"+id, "", viewer).Code)
+	assert.Equal(t, http.StatusOK, request(s, "GET", OpenAPIPath, "", "").Code)
+}
+
+func TestGatewayHealth(t *testing.T) {
+	s, _, _ := newGatewayService()
+	w := request(s, "GET", "/health", "", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var h state.Health
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &h))
+	assert.False(t, h.ImageLoaded)
+	assert.Empty(t, h.Errors)
+}
+
+func TestOpenAPI(t *testing.T) {
+	b, err := json.Marshal(openAPI())
+	require.NoError(t, err)
+	var doc struct {
+		Paths      map[string]map[string]interface{}
+		Components struct {
+			Schemas map[string]interface{}
+		}
+	}
+	require.NoError(t, json.Unmarshal(b, &doc))
+	for _, rt := range routes {
+		path := strings.Replace(rt.path, "...}", "}", 1)
+		assert.Contains(t, doc.Paths[path], strings.ToLower(rt.method), path)
+	}
+	assert.Contains(t, doc.Components.Schemas, "Node")
+	assert.Contains(t, doc.Components.Schemas, "Field")
+	assert.Contains(t, doc.Components.Schemas, "SetFieldRequest")
+}
+
+func TestMatchPath(t *testing.T) {
+	params, ok := matchPath("/nodes/{id}/components/{component}/fields/{field...}", "/nodes/1/components/C/fields/A/B")
+	require.True(t, ok)
+	assert.Equal(t, map[string]string{"id": "1", "component": "C", "field": "A/B"}, params)
+
+	_, ok = matchPath("/nodes/{id}", "/nodes/1/children")
+	assert.False(t, ok)
+	_, ok = matchPath("/nodes/{id}", "/nodes/")
+	assert.False(t, ok)
+}
@@ -1,14 +1,19 @@
 package rpc
 
 import (
+	"encoding/json"
 	"fmt"
 	"path/filepath"
 	"reflect"
 	"strings"
+	"time"
 
 	qrpc "github.com/manifold/qtalk/golang/rpc"
+	"github.com/manifold/tractor/pkg/manifold"
 	"github.com/manifold/tractor/pkg/manifold/library"
 	"github.com/manifold/tractor/pkg/manifold/object"
+	"github.com/manifold/tractor/pkg/misc/jsonpointer"
+	"github.com/manifold/tractor/pkg/workspace/view"
 )
 
 type AppendNodeParams struct {
@@ -17,10 +22,11 @@ type AppendNodeParams struct {
 }
 
 type SetValueParams struct {
-	Path     string
-	Value    interface{}
-	IntValue *int
-	RefValue *string
+	Path      string
+	Value     interface{}
+	IntValue  *int
+	RefValue  *string
+	JSONValue *string // decoded into the type of the field
 }
 
 type RemoveComponentParams struct {
@@ -37,101 +43,207 @@ type NodeParams struct {
 type DelegateParams struct {
 	ID       string
 	Contents string
+	Template string
+}
+
+type DelegateTemplate struct {
+	Name        string
+	Description string
 }
 
 type MoveNodeParams struct {
-	ID    string
-	Index int
+	ID     string
+	Index  int
+	Parent *string // ID of a new parent, empty for the root object
+}
+
+type SubscribeParams struct {
+	Patches bool
+	Name    string
+}
+
+type LoadPluginParams struct {
+	Path string
 }
 
 func (s *Service) Reload() func(qrpc.Responder, *qrpc.Call) {
 	return func(r qrpc.Responder, c *qrpc.Call) {
+		s.viewState.Invalidate()
 		s.updateView()
 		r.Return(nil)
 	}
 }
 
-// api.HandleFunc("repl", func(r qrpc.Responder, c *qrpc.Call) {
-// 	var params DelegateParams
-// 	_ = c.Decode(&params)
-// 	// ^^ TODO: make sure this isn't necessary before hijacking
-// 	ch, err := r.Hijack(nil)
-// 	if err != nil {
-// 		log.Println(err)
-// 	}
-// 	repl := repl.NewREPL(func(v interface{}) {
-// 		fmt.Fprintf(ch, "%s\n", v)
-// 	})
-// 	repl.Run(ch, ch, map[string]interface{}{
-// 		"Root": root,
-// 	})
-// })
+// findNode returns the object with the ID or a not_found error.
+func (s *Service) findNode(id string) (manifold.Object, error) {
+	n := s.State.Root.FindID(id)
+	if n == nil {
+		return nil, errorf(NotFound, "unable to find node: %s", id)
+	}
+	return n, nil
+}
+
+// findParent returns the object with the ID, or the root object if the ID is
+// empty.
+func (s *Service) findParent(id string) (manifold.Object, error) {
+	if id == "" {
+		return s.State.Root, nil
+	}
+	return s.findNode(id)
+}
+
+// findPath returns the object of a path to a component field or method and
+// the path relative to the object, starting with the component name.
+func (s *Service) findPath(path string) (manifold.Object, string, error) {
+	if path == "" {
+		return nil, "", errorf(InvalidArgument, "path is required")
+	}
+	n := s.State.Root.FindChild(path)
+	if n == nil {
+		return nil, "", errorf(NotFound, "unable to find node for path: %s", path)
+	}
+	localPath := strings.TrimPrefix(strings.TrimPrefix(path, n.Path()), "/")
+	if localPath == "" {
+		return nil, "", errorf(InvalidArgument, "path has no component: %s", path)
+	}
+	parts := strings.SplitN(localPath, "/", 2)
+	if len(parts) < 2 || parts[1] == "" {
+		return nil, "", errorf(InvalidArgument, "path has no field or method: %s", path)
+	}
+	if n.Component(parts[0]) == nil {
+		return nil, "", errorf(NotFound, "unable to find component: %s", parts[0])
+	}
+	return n, localPath, nil
+}
+
+func decodeError(err error) error {
+	return errorf(InvalidArgument, "unable to decode params: %v", err)
+}
 
 func (s *Service) RemoveComponent() func(qrpc.Responder, *qrpc.Call) {
 	return func(r qrpc.Responder, c *qrpc.Call) {
 		var params RemoveComponentParams
 		err := c.Decode(&params)
 		if err != nil {
-			r.Return(err)
+			r.Return(decodeError(err))
 			return
 		}
-		n := s.State.Root.FindID(params.ID)
-		if n == nil {
-			r.Return(fmt.Errorf("unable to find node: %s", params.ID))
-			return
-		}
-		com := n.Component(params.Component)
-		n.RemoveComponent(com)
-		if com.ID() == n.ID() {
-			if err := s.State.Image.DestroyObjectPackage(n); err != nil {
-				fmt.Println(err)
-			}
+		r.Return(s.removeComponent(params.ID, params.Component))
+	}
+}
+
+func (s *Service) removeComponent(id, name string) error {
+	n, err := s.findNode(id)
+	if err != nil {
+		return err
+	}
+	com := n.Component(name)
+	if com == nil {
+		return errorf(NotFound, "unable to find component: %s", name)
+	}
+	n.RemoveComponent(com)
+	if com.ID() == n.ID() {
+		if err := s.State.Image.DestroyObjectPackage(n); err != nil {
+			fmt.Println(err)
 		}
-		s.updateView()
-		r.Return(nil)
 	}
+	s.updateView()
+	return nil
 }
 
 func (s *Service) ReloadComponent() func(qrpc.Responder, *qrpc.Call) {
 	return func(r qrpc.Responder, c *qrpc.Call) {
 		var params RemoveComponentParams
 		err := c.Decode(&params)
+		if err != nil {
+			r.Return(decodeError(err))
+			return
+		}
+		r.Return(s.reloadComponent(params.ID, params.Component))
+	}
+}
+
+func (s *Service) reloadComponent(id, name string) error {
+	n, err := s.findNode(id)
+	if err != nil {
+		return err
+	}
+	com := n.Component(name)
+	if com == nil {
+		return errorf(NotFound, "unable to find component: %s", name)
+	}
+	if err := com.Reload(); err != nil {
+		return err
+	}
+	n.UpdateRegistry()
+	s.viewState.Touch(n.ID())
+	s.updateView()
+	return nil
+}
+
+func (s *Service) AddDelegate() func(qrpc.Responder, *qrpc.Call) {
+	return func(r qrpc.Responder, c *qrpc.Call) {
+		var params DelegateParams
+		err := c.Decode(&params)
+		if err != nil {
+			r.Return(decodeError(err))
+			return
+		}
+		obj, err := s.findNode(params.ID)
 		if err != nil {
 			r.Return(err)
 			return
 		}
-		n := s.State.Root.FindID(params.ID)
-		if n == nil {
-			r.Return(fmt.Errorf("unable to find node: %s", params.ID))
+		if _, err := s.State.Image.DelegateTemplate(params.Template); err != nil {
+			r.Return(errorf(InvalidArgument, "%v", err))
 			return
 		}
-		com := n.Component(params.Component)
-		if com != nil {
-			if err := com.Reload(); err != nil {
-				r.Return(err)
-				return
-			}
-		}		
-		n.UpdateRegistry()
-		s.updateView()
-		r.Return(nil)
+		r.Return(s.State.Image.CreateObjectPackage(obj, params.Template))
 	}
 }
 
-func (s *Service) AddDelegate() func(qrpc.Responder, *qrpc.Call) {
+// DelegateTemplates replies with the templates addDelegate can create delegate
+// packages from.
+func (s *Service) DelegateTemplates() func(qrpc.Responder, *qrpc.Call) {
 	return func(r qrpc.Responder, c *qrpc.Call) {
-		var params NodeParams
-		err := c.Decode(&params)
+		ts, err := s.State.Image.DelegateTemplates()
 		if err != nil {
 			r.Return(err)
 			return
 		}
-		obj := s.State.Root.FindID(params.ID)
-		if obj == nil {
-			r.Return(nil)
+		var templates []DelegateTemplate
+		for _, t := range ts {
+			templates = append(templates, DelegateTemplate{
+				Name:        t.Name,
+				Description: t.Description,
+			})
+		}
+		r.Return(templates)
+	}
+}
+
+// LoadPlugin hot reloads delegate packages built as a Go plugin by the agent.
+// It replies with the IDs of the reloaded delegates.
+func (s *Service) LoadPlugin() func(qrpc.Responder, *qrpc.Call) {
+	return func(r qrpc.Responder, c *qrpc.Call) {
+		var params LoadPluginParams
+		err := c.Decode(&params)
+		if err != nil {
+			r.Return(decodeError(err))
+			return
+		}
+		if params.Path == "" {
+			r.Return(errorf(InvalidArgument, "path is required"))
+			return
+		}
+		ids, err := s.State.LoadPlugin(params.Path)
+		if err != nil {
+			r.Return(err)
 			return
 		}
-		r.Return(s.State.Image.CreateObjectPackage(obj))
+		s.viewState.Invalidate()
+		s.updateView()
+		r.Return(ids)
 	}
 }
 
@@ -140,10 +252,16 @@ func (s *Service) SelectNode() func(qrpc.Responder, *qrpc.Call) {
 		var id string
 		err := c.Decode(&id)
 		if err != nil {
-			r.Return(err)
+			r.Return(decodeError(err))
 			return
 		}
-		s.viewState.SelectedNode = id
+		if id != "" {
+			if _, err := s.findNode(id); err != nil {
+				r.Return(err)
+				return
+			}
+		}
+		s.client(c.Caller).session.SelectNode(id)
 		s.updateView()
 		r.Return(nil)
 	}
@@ -154,22 +272,29 @@ func (s *Service) UpdateNode() func(qrpc.Responder, *qrpc.Call) {
 		var params NodeParams
 		err := c.Decode(&params)
 		if err != nil {
-			r.Return(err)
-			return
-		}
-		n := s.State.Root.FindID(params.ID)
-		if n == nil {
+			r.Return(decodeError(err))
 			return
 		}
-		if params.Name != nil {
-			n.SetName(*params.Name)
+		r.Return(s.updateNode(params))
+	}
+}
+
+func (s *Service) updateNode(params NodeParams) error {
+	n, err := s.findNode(params.ID)
+	if err != nil {
+		return err
+	}
+	if params.Name != nil {
+		if *params.Name == "" {
+			return errorf(InvalidArgument, "name must not be empty")
 		}
-		// if params.Active != nil {
-		// 	n.Active = *params.Active
-		// }
-		s.updateView()
-		r.Return(nil)
+		n.SetName(*params.Name)
 	}
+	// if params.Active != nil {
+	// 	n.Active = *params.Active
+	// }
+	s.updateView()
+	return nil
 }
 
 func (s *Service) CallMethod() func(qrpc.Responder, *qrpc.Call) {
@@ -177,19 +302,26 @@ func (s *Service) CallMethod() func(qrpc.Responder, *qrpc.Call) {
 		var path string
 		err := c.Decode(&path)
 		if err != nil {
-			r.Return(err)
+			r.Return(decodeError(err))
 			return
 		}
-		if path == "" {
+		n, localPath, err := s.findPath(path)
+		if err != nil {
+			r.Return(err)
 			return
 		}
-		n := s.State.Root.FindChild(path)
-		localPath := path[len(n.Path())+1:]
-		// TODO: support args+ret
-		n.CallMethod(localPath, nil, nil)
-		s.updateView()
-		r.Return(nil)
+		r.Return(s.callMethod(n, localPath))
+	}
+}
+
+func (s *Service) callMethod(n manifold.Object, localPath string) error {
+	// TODO: support args+ret
+	if err := n.CallMethod(localPath, nil, nil); err != nil {
+		return err
 	}
+	s.viewState.Touch(n.ID())
+	s.updateView()
+	return nil
 }
 
 // func (s *Service) SetExpression() func(qrpc.Responder, *qrpc.Call) {
@@ -212,40 +344,197 @@ func (s *Service) SetValue() func(qrpc.Responder, *qrpc.Call) {
 	return func(r qrpc.Responder, c *qrpc.Call) {
 		var params SetValueParams
 		err := c.Decode(&params)
+		if err != nil {
+			r.Return(decodeError(err))
+			return
+		}
+		n, localPath, err := s.findPath(params.Path)
 		if err != nil {
 			r.Return(err)
 			return
 		}
-		n := s.State.Root.FindChild(params.Path)
-		//fmt.Println(n, params)
-		localPath := params.Path[len(n.Path())+1:]
-		switch {
-		case params.IntValue != nil:
-			n.SetField(localPath, *params.IntValue)
-		case params.RefValue != nil:
-			refPath := filepath.Dir(*params.RefValue) // TODO: support subfields
-			refNode := s.State.Root.FindChild(refPath)
-			parts := strings.SplitN(localPath, "/", 2)
-			refType := n.Component(parts[0]).FieldType(parts[1])
-			if refNode != nil {
-				typeSelector := (*params.RefValue)[len(refNode.Path())+1:]
-				c := refNode.Component(typeSelector)
-				if c != nil {
-					n.SetField(localPath, c)
-				} else {
-					// interface reference
-					ptr := reflect.New(refType)
-					refNode.ValueTo(ptr)
-					if ptr.IsValid() {
-						n.SetField(localPath, reflect.Indirect(ptr).Interface())
-					}
-				}
+		r.Return(s.setValue(n, localPath, params))
+	}
+}
+
+// setValue sets the field at localPath to the value of params. The Path of
+// params is ignored. Read-only fields and values that don't fit the editor
+// declared by the field tags are rejected.
+func (s *Service) setValue(n manifold.Object, localPath string, params SetValueParams) error {
+	parts := strings.SplitN(localPath, "/", 2)
+	if len(parts) < 2 {
+		return errorf(InvalidArgument, "not a field path: %s", localPath)
+	}
+	com := n.Component(parts[0])
+	if com == nil {
+		return errorf(NotFound, "unable to find component: %s", parts[0])
+	}
+	var v interface{}
+	var err error
+	switch {
+	case params.JSONValue != nil:
+		if v, err = decodeField(com, parts[1], []byte(*params.JSONValue)); err != nil {
+			return err
+		}
+	case params.IntValue != nil:
+		v = *params.IntValue
+	case params.RefValue != nil:
+		if !strings.HasPrefix(*params.RefValue, "/") {
+			return errorf(InvalidArgument, "reference must be an absolute path: %s", *params.RefValue)
+		}
+		refPath := filepath.Dir(*params.RefValue) // TODO: support subfields
+		refNode := s.State.Root.FindChild(refPath)
+		if refNode == nil {
+			return errorf(NotFound, "unable to find node for reference: %s", *params.RefValue)
+		}
+		prefix := strings.TrimSuffix(refNode.Path(), "/") + "/"
+		if !strings.HasPrefix(*params.RefValue, prefix) {
+			return errorf(InvalidArgument, "reference is not a path below %s: %s", refNode.Path(), *params.RefValue)
+		}
+		refType := com.FieldType(parts[1])
+		typeSelector := strings.TrimPrefix(*params.RefValue, prefix)
+		c := refNode.Component(typeSelector)
+		if c != nil {
+			// fields either reference the component or its value
+			v = c
+			if refType != nil && reflect.TypeOf(c.Pointer()).AssignableTo(refType) {
+				v = c.Pointer()
+			}
+		} else {
+			// interface reference
+			ptr := reflect.New(refType)
+			refNode.ValueTo(ptr)
+			if ptr.Elem().IsZero() {
+				return errorf(NotFound, "no value for reference: %s", *params.RefValue)
 			}
-		default:
-			n.SetField(localPath, params.Value)
+			v = reflect.Indirect(ptr).Interface()
 		}
-		s.updateView()
-		r.Return(nil)
+	default:
+		if v, err = convertField(com, parts[1], params.Value); err != nil {
+			return err
+		}
+	}
+	if err := checkField(com, parts[1], v); err != nil {
+		return err
+	}
+	if err := n.SetField(localPath, v); err != nil {
+		if se, ok := err.(*jsonpointer.SetError); ok {
+			return errorf(InvalidArgument, "%v", se)
+		}
+		return err
+	}
+	s.updateView()
+	return nil
+}
+
+// checkField returns an error if a component field is read-only or the value
+// doesn't fit the editor declared by its tags.
+func checkField(com manifold.Component, field string, value interface{}) error {
+	e, err := view.FieldEditor(reflect.TypeOf(com.Pointer()), field)
+	if err != nil {
+		return errorf(InvalidArgument, "%v", err)
+	}
+	if e.ReadOnly {
+		return errorf(PermissionDenied, "field is read-only: %s", field)
+	}
+	if err := e.Check(reflect.ValueOf(value)); err != nil {
+		return errorf(InvalidArgument, "invalid value for %s: %v", field, err)
+	}
+	return nil
+}
+
+// convertField converts a decoded value into the type of a component field,
+// like numbers into the integer type of a field or strings into times. Values
+// that are not assignable are converted through JSON.
+func convertField(com manifold.Component, field string, value interface{}) (interface{}, error) {
+	typ, err := fieldType(com, field)
+	if err != nil {
+		return nil, err
+	}
+	if value == nil || reflect.TypeOf(value).AssignableTo(typ) && !typ.Implements(enumType) {
+		return value, nil
+	}
+	data, err := json.Marshal(jsonValue(value))
+	if err != nil {
+		return nil, errorf(InvalidArgument, "invalid value for %s: %v", field, err)
+	}
+	return decodeField(com, field, data)
+}
+
+var (
+	durationType = reflect.TypeOf(time.Duration(0))
+	enumType     = reflect.TypeOf((*view.Enum)(nil)).Elem()
+)
+
+// decodeField decodes a JSON value into the type of a component field.
+// Durations can also be decoded from strings like "1m30s", and enums from the
+// labels of their values. Enums have to be one of their values.
+func decodeField(com manifold.Component, field string, data []byte) (interface{}, error) {
+	typ, err := fieldType(com, field)
+	if err != nil {
+		return nil, err
+	}
+	v := reflect.New(typ)
+	if err := json.Unmarshal(data, v.Interface()); err != nil {
+		var s string
+		if json.Unmarshal(data, &s) != nil || !parseField(v.Elem(), s) {
+			return nil, errorf(InvalidArgument, "invalid value for %s: %v", field, err)
+		}
+	}
+	if e, ok := v.Elem().Interface().(view.Enum); ok && typ.Kind() != reflect.Interface {
+		if _, ok := enumValue(e, func(ev interface{}) bool { return ev == v.Elem().Interface() }); !ok {
+			return nil, errorf(InvalidArgument, "invalid value for %s: %v is not one of its values", field, e)
+		}
+	}
+	return v.Elem().Interface(), nil
+}
+
+// parseField sets v to the duration or enum value of a string.
+func parseField(v reflect.Value, s string) bool {
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return false
+		}
+		v.SetInt(int64(d))
+		return true
+	}
+	if e, ok := v.Interface().(view.Enum); ok {
+		ev, ok := enumValue(e, func(ev interface{}) bool { return fmt.Sprint(ev) == s })
+		if ok {
+			v.Set(reflect.ValueOf(ev))
+		}
+		return ok
+	}
+	return false
+}
+
+func enumValue(e view.Enum, match func(interface{}) bool) (interface{}, bool) {
+	for _, ev := range e.EnumValues() {
+		if match(ev) {
+			return ev, true
+		}
+	}
+	return nil, false
+}
+
+// jsonValue converts maps decoded from msgpack into maps with string keys.
+func jsonValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			m[fmt.Sprint(k)] = jsonValue(e)
+		}
+		return m
+	case []interface{}:
+		s := make([]interface{}, len(v))
+		for i, e := range v {
+			s[i] = jsonValue(e)
+		}
+		return s
+	default:
+		return v
 	}
 }
 
@@ -254,21 +543,35 @@ func (s *Service) AppendComponent() func(qrpc.Responder, *qrpc.Call) {
 		var params AppendNodeParams
 		err := c.Decode(&params)
 		if err != nil {
-			r.Return(err)
-			return
-		}
-		if params.Name == "" {
+			r.Return(decodeError(err))
 			return
 		}
-		p := s.State.Root.FindID(params.ID)
-		if p == nil {
-			p = s.State.Root
+		r.Return(s.appendComponent(params.ID, params.Name))
+	}
+}
+
+func (s *Service) appendComponent(id, name string) error {
+	if name == "" {
+		return errorf(InvalidArgument, "component name is required")
+	}
+	p, err := s.findParent(id)
+	if err != nil {
+		return err
+	}
+	rc := library.Lookup(name)
+	if rc == nil {
+		if names := library.Ambiguous(name); len(names) > 1 {
+			return errorf(InvalidArgument, "ambiguous component name %s: one of %s", name, strings.Join(names, ", "))
 		}
-		v := library.Lookup(params.Name).New()
-		p.AppendComponent(v)
-		s.updateView()
-		r.Return(nil)
+		return errorf(NotFound, "unable to find registered component: %s", name)
 	}
+	v := rc.New()
+	if p.Component(v.Name()) != nil {
+		return errorf(Conflict, "node already has component: %s", v.Name())
+	}
+	p.AppendComponent(v)
+	s.updateView()
+	return nil
 }
 
 func (s *Service) DeleteNode() func(qrpc.Responder, *qrpc.Call) {
@@ -276,75 +579,148 @@ func (s *Service) DeleteNode() func(qrpc.Responder, *qrpc.Call) {
 		var id string
 		err := c.Decode(&id)
 		if err != nil {
-			r.Return(err)
-			return
-		}
-		if id == "" {
+			r.Return(decodeError(err))
 			return
 		}
-		s.State.Root.RemoveID(id)
-		s.updateView()
-		r.Return(nil)
+		r.Return(s.deleteNode(id))
 	}
 }
 
+func (s *Service) deleteNode(id string) error {
+	if id == "" {
+		return errorf(InvalidArgument, "node ID is required")
+	}
+	if s.State.Root.RemoveID(id) == nil {
+		return errorf(NotFound, "unable to find node: %s", id)
+	}
+	s.updateView()
+	return nil
+}
+
 func (s *Service) AppendNode() func(qrpc.Responder, *qrpc.Call) {
 	return func(r qrpc.Responder, c *qrpc.Call) {
 		var params AppendNodeParams
 		err := c.Decode(&params)
 		if err != nil {
-			r.Return(err)
+			r.Return(decodeError(err))
 			return
 		}
-		if params.Name == "" {
+		if _, err := s.appendNode(params.ID, params.Name); err != nil {
+			r.Return(err)
 			return
 		}
-		p := s.State.Root.FindID(params.ID)
-		if p == nil {
-			p = s.State.Root
-		}
-		n := object.New(params.Name)
-		p.AppendChild(n)
-		s.updateView()
 		r.Return(nil)
 	}
 }
 
+// appendNode appends a new object to the parent with the ID, or to the root
+// object if the ID is empty.
+func (s *Service) appendNode(id, name string) (manifold.Object, error) {
+	if name == "" {
+		return nil, errorf(InvalidArgument, "node name is required")
+	}
+	p, err := s.findParent(id)
+	if err != nil {
+		return nil, err
+	}
+	n := object.New(name)
+	p.AppendChild(n)
+	s.updateView()
+	return n, nil
+}
+
 func (s *Service) MoveNode() func(qrpc.Responder, *qrpc.Call) {
 	return func(r qrpc.Responder, c *qrpc.Call) {
 		var params MoveNodeParams
 		err := c.Decode(&params)
 		if err != nil {
-			r.Return(err)
+			r.Return(decodeError(err))
 			return
 		}
-		n := s.State.Root.FindID(params.ID)
-		if n == nil {
-			return
+		r.Return(s.moveNode(params.ID, params.Parent, params.Index))
+	}
+}
+
+// moveNode moves the object with the ID to the index among its siblings. With
+// a parent ID it moves the object to the index among the children of the
+// parent instead, which must not be the object or one of its descendants.
+func (s *Service) moveNode(id string, parentID *string, index int) error {
+	n, err := s.findNode(id)
+	if err != nil {
+		return err
+	}
+	if parentID == nil {
+		if err := n.SetSiblingIndex(index); err != nil {
+			return errorf(InvalidArgument, "%v", err)
 		}
-		n.SetSiblingIndex(params.Index)
 		s.updateView()
-		r.Return(nil)
+		return nil
+	}
+
+	p, err := s.findParent(*parentID)
+	if err != nil {
+		return err
+	}
+	if p == n.Parent() {
+		return s.moveNode(id, nil, index)
+	}
+	for a := p; a != nil; a = a.Parent() {
+		if a == n {
+			return errorf(InvalidArgument, "cannot move node into itself or its descendants: %s", id)
+		}
+	}
+	if ls := len(p.Children()); index < 0 || index > ls {
+		return errorf(InvalidArgument, "index must be >= 0 and <= %d child(ren), got: %d", ls, index)
 	}
+	n.Parent().RemoveChild(n)
+	p.InsertChildAt(index, n)
+	s.updateView()
+	return nil
 }
 
+// Subscribe subscribes the caller to view updates and sends it the full view
+// state. With Patches set the caller gets patches instead of the full state
+// on later updates. Name is shown to other clients in their presence.
 func (s *Service) Subscribe() func(qrpc.Responder, *qrpc.Call) {
 	return func(r qrpc.Responder, c *qrpc.Call) {
-		s.clients[c.Caller] = "state"
+		var params SubscribeParams
+		// older clients subscribe without params
+		_ = c.Decode(&params)
+		cl := s.client(c.Caller)
+		cl.session.SetName(params.Name)
+		s.mu.Lock()
+		cl.subscribed = true
+		cl.patches = params.Patches
+		s.mu.Unlock()
+		if err := s.sendState(cl); err != nil {
+			r.Return(err)
+			return
+		}
 		s.updateView()
 		r.Return(nil)
 	}
 }
 
+// Resync sends the full view state to a caller that missed patches.
+func (s *Service) Resync() func(qrpc.Responder, *qrpc.Call) {
+	return func(r qrpc.Responder, c *qrpc.Call) {
+		if err := s.sendState(s.client(c.Caller)); err != nil {
+			r.Return(err)
+			return
+		}
+		r.Return(nil)
+	}
+}
+
 func (s *Service) SelectProject() func(qrpc.Responder, *qrpc.Call) {
 	return func(r qrpc.Responder, c *qrpc.Call) {
 		var name string
 		err := c.Decode(&name)
 		if err != nil {
-			r.Return(err)
+			r.Return(decodeError(err))
 			return
 		}
-		s.viewState.CurrentProject = name
+		s.client(c.Caller).session.SelectProject(name)
 		s.updateView()
 		r.Return(nil)
 	}
@@ -0,0 +1,158 @@
+package rpc
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+var rawMessageType = reflect.TypeOf(json.RawMessage{})
+
+// openAPI generates the OpenAPI document of the gateway routes.
+func openAPI() map[string]interface{} {
+	schemas := make(map[string]interface{})
+	errorSchema := schemaOf(reflect.TypeOf(Error{}), schemas)
+	paths := make(map[string]map[string]interface{})
+	for _, rt := range routes {
+		path := strings.Replace(rt.path, "...}", "}", 1)
+		if paths[path] == nil {
+			paths[path] = make(map[string]interface{})
+		}
+
+		var params []interface{}
+		for _, part := range strings.Split(rt.path, "/") {
+			if !strings.HasPrefix(part, "{") {
+				continue
+			}
+			params = append(params, map[string]interface{}{
+				"name":     strings.TrimSuffix(strings.Trim(part, "{}"), "..."),
+				"in":       "path",
+				"required": true,
+				"schema":   map[string]interface{}{"type": "string"},
+			})
+		}
+
+		responses := map[string]interface{}{
+			"default": map[string]interface{}{
+				"description": "Error",
+				"content":     jsonContent(errorSchema),
+			},
+		}
+		if rt.reply != nil {
+			responses["200"] = map[string]interface{}{
+				"description": "OK",
+				"content":     jsonContent(schemaOf(reflect.TypeOf(rt.reply), schemas)),
+			}
+		} else {
+			responses["204"] = map[string]interface{}{
+				"description": "No Content",
+			}
+		}
+
+		op := map[string]interface{}{
+			"summary":     rt.summary,
+			"description": "Requires the " + string(rt.role) + " role.",
+			"responses":   responses,
+		}
+		if len(params) > 0 {
+			op["parameters"] = params
+		}
+		if rt.body != nil {
+			op["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content":  jsonContent(schemaOf(reflect.TypeOf(rt.body), schemas)),
+			}
+		}
+		paths[path][strings.ToLower(rt.method)] = op
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":   "Tractor Workspace",
+			"version": "1.0.0",
+		},
+		"servers": []interface{}{
+			map[string]interface{}{"url": "/"},
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": schemas,
+			"securitySchemes": map[string]interface{}{
+				"token": map[string]interface{}{
+					"type":   "http",
+					"scheme": "bearer",
+				},
+			},
+		},
+		"security": []interface{}{
+			map[string]interface{}{"token": []string{}},
+		},
+	}
+}
+
+func jsonContent(schema interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"application/json": map[string]interface{}{"schema": schema},
+	}
+}
+
+// schemaOf returns the JSON schema of a type. Named structs are added to
+// schemas and referenced.
+func schemaOf(t reflect.Type, schemas map[string]interface{}) map[string]interface{} {
+	if t == rawMessageType {
+		return map[string]interface{}{}
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		return schemaOf(t.Elem(), schemas)
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{
+			"type":  "array",
+			"items": schemaOf(t.Elem(), schemas),
+		}
+	case reflect.Map:
+		return map[string]interface{}{
+			"type":                 "object",
+			"additionalProperties": schemaOf(t.Elem(), schemas),
+		}
+	case reflect.Struct:
+		ref := map[string]interface{}{"$ref": "#/components/schemas/" + t.Name()}
+		if _, exists := schemas[t.Name()]; exists {
+			return ref
+		}
+		// added before its fields for recursive types
+		schemas[t.Name()] = nil
+		props := make(map[string]interface{})
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.PkgPath != "" {
+				continue
+			}
+			name := strings.Split(f.Tag.Get("json"), ",")[0]
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = f.Name
+			}
+			props[name] = schemaOf(f.Type, schemas)
+		}
+		schemas[t.Name()] = map[string]interface{}{
+			"type":       "object",
+			"properties": props,
+		}
+		return ref
+	default:
+		return map[string]interface{}{}
+	}
+}
@@ -0,0 +1,265 @@
+package rpc
+
+import (
+	"strings"
+
+	qrpc "github.com/manifold/qtalk/golang/rpc"
+	"github.com/manifold/tractor/pkg/manifold"
+	"github.com/manifold/tractor/pkg/manifold/library"
+	"github.com/manifold/tractor/pkg/workspace/view"
+)
+
+// NodeInfo identifies an object and its place in the tree.
+type NodeInfo struct {
+	ID       string `msgpack:"id" json:"id"`
+	Name     string `msgpack:"name" json:"name"`
+	Path     string `msgpack:"path" json:"path"`
+	Index    int    `msgpack:"index" json:"index"`
+	Children int    `msgpack:"children" json:"children"`
+}
+
+// ComponentInfo describes a component of an object.
+type ComponentInfo struct {
+	Name    string `msgpack:"name" json:"name"`
+	Type    string `msgpack:"type" json:"type"`
+	ID      string `msgpack:"id" json:"id"` // object ID for delegate components
+	Enabled bool   `msgpack:"enabled" json:"enabled"`
+}
+
+// FieldValue is the value of a component field.
+type FieldValue struct {
+	Path  string      `msgpack:"path" json:"path"`
+	Type  string      `msgpack:"type" json:"type"`
+	Value interface{} `msgpack:"value" json:"value"`
+}
+
+// ResolvedPath is a path split into the object it refers to and the path of
+// a component, field or method of the object.
+type ResolvedPath struct {
+	ID        string `msgpack:"id" json:"id"`
+	NodePath  string `msgpack:"nodePath" json:"nodePath"`
+	LocalPath string `msgpack:"localPath" json:"localPath"`
+}
+
+func nodeInfo(n manifold.Object) NodeInfo {
+	return NodeInfo{
+		ID:       n.ID(),
+		Name:     n.Name(),
+		Path:     n.Path(),
+		Index:    n.SiblingIndex(),
+		Children: len(n.Children()),
+	}
+}
+
+// GetNode replies with the view of the object with the ID, or of the root
+// object if the ID is empty.
+func (s *Service) GetNode() func(qrpc.Responder, *qrpc.Call) {
+	return func(r qrpc.Responder, c *qrpc.Call) {
+		var id string
+		if err := c.Decode(&id); err != nil {
+			r.Return(decodeError(err))
+			return
+		}
+		n, err := s.findParent(id)
+		if err != nil {
+			r.Return(err)
+			return
+		}
+		r.Return(view.ExportNode(n))
+	}
+}
+
+// GetField replies with the value of the component field at a path like
+// /{node path}/{component}/{field}.
+func (s *Service) GetField() func(qrpc.Responder, *qrpc.Call) {
+	return func(r qrpc.Responder, c *qrpc.Call) {
+		var path string
+		if err := c.Decode(&path); err != nil {
+			r.Return(decodeError(err))
+			return
+		}
+		n, localPath, err := s.findPath(path)
+		if err != nil {
+			r.Return(err)
+			return
+		}
+		parts := strings.SplitN(localPath, "/", 2)
+		v, err := s.getField(n, parts[0], parts[1])
+		if err != nil {
+			r.Return(err)
+			return
+		}
+		r.Return(v)
+	}
+}
+
+func (s *Service) getField(n manifold.Object, component, field string) (FieldValue, error) {
+	com := n.Component(component)
+	if com == nil {
+		return FieldValue{}, errorf(NotFound, "unable to find component: %s", component)
+	}
+	typ, err := fieldType(com, field)
+	if err != nil {
+		return FieldValue{}, err
+	}
+	v, _, err := com.GetField(field)
+	if err != nil {
+		return FieldValue{}, err
+	}
+	return FieldValue{
+		Path:  n.Path() + "/" + component + "/" + field,
+		Type:  typ.String(),
+		Value: v,
+	}, nil
+}
+
+// ListChildren replies with the children of the object with the ID, or of
+// the root object if the ID is empty.
+func (s *Service) ListChildren() func(qrpc.Responder, *qrpc.Call) {
+	return func(r qrpc.Responder, c *qrpc.Call) {
+		var id string
+		if err := c.Decode(&id); err != nil {
+			r.Return(decodeError(err))
+			return
+		}
+		children, err := s.listChildren(id)
+		if err != nil {
+			r.Return(err)
+			return
+		}
+		r.Return(children)
+	}
+}
+
+func (s *Service) listChildren(id string) ([]NodeInfo, error) {
+	n, err := s.findParent(id)
+	if err != nil {
+		return nil, err
+	}
+	children := []NodeInfo{}
+	for _, child := range n.Children() {
+		children = append(children, nodeInfo(child))
+	}
+	return children, nil
+}
+
+// ListComponents replies with the components of the object with the ID, or
+// of the root object if the ID is empty.
+func (s *Service) ListComponents() func(qrpc.Responder, *qrpc.Call) {
+	return func(r qrpc.Responder, c *qrpc.Call) {
+		var id string
+		if err := c.Decode(&id); err != nil {
+			r.Return(decodeError(err))
+			return
+		}
+		coms, err := s.listComponents(id)
+		if err != nil {
+			r.Return(err)
+			return
+		}
+		r.Return(coms)
+	}
+}
+
+func (s *Service) listComponents(id string) ([]ComponentInfo, error) {
+	n, err := s.findParent(id)
+	if err != nil {
+		return nil, err
+	}
+	coms := []ComponentInfo{}
+	for _, com := range n.Components() {
+		coms = append(coms, ComponentInfo{
+			Name:    com.Name(),
+			Type:    library.QualifiedName(com.Type()),
+			ID:      com.ID(),
+			Enabled: com.Enabled(),
+		})
+	}
+	return coms, nil
+}
+
+// ListRegisteredComponents replies with the component types that can be
+// added to objects.
+func (s *Service) ListRegisteredComponents() func(qrpc.Responder, *qrpc.Call) {
+	return func(r qrpc.Responder, c *qrpc.Call) {
+		r.Return(view.RegisteredComponents())
+	}
+}
+
+// GetSnapshot replies with the snapshots of the object with the ID, or of
+// the root object if the ID is empty, and of all its descendants. The object
+// comes first followed by its descendants in tree order.
+func (s *Service) GetSnapshot() func(qrpc.Responder, *qrpc.Call) {
+	return func(r qrpc.Responder, c *qrpc.Call) {
+		var id string
+		if err := c.Decode(&id); err != nil {
+			r.Return(decodeError(err))
+			return
+		}
+		snapshots, err := s.getSnapshot(id)
+		if err != nil {
+			r.Return(err)
+			return
+		}
+		r.Return(snapshots)
+	}
+}
+
+func (s *Service) getSnapshot(id string) ([]manifold.ObjectSnapshot, error) {
+	n, err := s.findParent(id)
+	if err != nil {
+		return nil, err
+	}
+	snapshots := []manifold.ObjectSnapshot{n.Snapshot()}
+	for _, child := range n.Children() {
+		manifold.Walk(child, func(o manifold.Object) {
+			snapshots = append(snapshots, o.Snapshot())
+		})
+	}
+	return snapshots, nil
+}
+
+// ResolvePath replies with the object a path refers to and the rest of the
+// path after the path of the object.
+func (s *Service) ResolvePath() func(qrpc.Responder, *qrpc.Call) {
+	return func(r qrpc.Responder, c *qrpc.Call) {
+		var path string
+		if err := c.Decode(&path); err != nil {
+			r.Return(decodeError(err))
+			return
+		}
+		resolved, err := s.resolvePath(path)
+		if err != nil {
+			r.Return(err)
+			return
+		}
+		r.Return(resolved)
+	}
+}
+
+func (s *Service) resolvePath(path string) (ResolvedPath, error) {
+	if path == "" {
+		return ResolvedPath{}, errorf(InvalidArgument, "path is required")
+	}
+	path = "/" + strings.Trim(path, "/")
+	n := s.State.Root
+	if path != "/" {
+		n = n.FindChild(path)
+	}
+	if n == nil {
+		return ResolvedPath{}, errorf(NotFound, "unable to find node for path: %s", path)
+	}
+	return ResolvedPath{
+		ID:        n.ID(),
+		NodePath:  n.Path(),
+		LocalPath: strings.TrimPrefix(strings.TrimPrefix(path, n.Path()), "/"),
+	}, nil
+}
+
+// Health replies with the health of the workspace, which the agent polls to
+// tell when the workspace is ready.
+func (s *Service) Health() func(qrpc.Responder, *qrpc.Call) {
+	return func(r qrpc.Responder, c *qrpc.Call) {
+		r.Return(s.State.Health())
+	}
+}
@@ -0,0 +1,83 @@
+package rpc
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueries(t *testing.T) {
+	s, c, id := newGatewayService()
+	c.Count = 3
+	root := s.State.Root
+
+	children, err := s.listChildren("")
+	require.NoError(t, err)
+	assert.Equal(t, []NodeInfo{{ID: id, Name: "Node", Path: "/Node"}}, children)
+
+	_, err = s.listChildren("missing")
+	assert.Equal(t, NotFound, err.(*Error).Code)
+
+	coms, err := s.listComponents(id)
+	require.NoError(t, err)
+	require.Len(t, coms, 1)
+	assert.Equal(t, "Counter", coms[0].Name)
+	assert.Equal(t, "github.com/manifold/tractor/pkg/workspace/rpc.counter", coms[0].Type)
+
+	n := root.FindID(id)
+	v, err := s.getField(n, "Counter", "Count")
+	require.NoError(t, err)
+	assert.Equal(t, FieldValue{Path: "/Node/Counter/Count", Type: "int", Value: 3}, v)
+
+	_, err = s.getField(n, "Counter", "Missing")
+	assert.Equal(t, NotFound, err.(*Error).Code)
+	_, err = s.getField(n, "Other", "Count")
+	assert.Equal(t, NotFound, err.(*Error).Code)
+
+	snapshots, err := s.getSnapshot("")
+	require.NoError(t, err)
+	require.Len(t, snapshots, 2)
+	assert.Equal(t, root.ID(), snapshots[0].ID)
+	assert.Equal(t, [][]string{{id, "Node"}}, snapshots[0].Children)
+	assert.Equal(t, id, snapshots[1].ID)
+	require.Len(t, snapshots[1].Components, 1)
+
+	for path, want := range map[string]ResolvedPath{
+		"/":                   {ID: root.ID(), NodePath: "/"},
+		"/Node":               {ID: id, NodePath: "/Node"},
+		"Node/":               {ID: id, NodePath: "/Node"},
+		"/Node/Counter/Count": {ID: id, NodePath: "/Node", LocalPath: "Counter/Count"},
+	} {
+		resolved, err := s.resolvePath(path)
+		require.NoError(t, err, path)
+		assert.Equal(t, want, resolved, path)
+	}
+	_, err = s.resolvePath("/Missing")
+	assert.Equal(t, NotFound, err.(*Error).Code)
+	_, err = s.resolvePath("")
+	assert.Equal(t, InvalidArgument, err.(*Error).Code)
+}
+
+func TestGatewayQueries(t *testing.T) {
+	s, c, id := newGatewayService()
+	c.Count = 3
+
+	w := request(s, "GET", "/nodes/"+id+"/components/Counter/fields/Count", "", "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var v FieldValue
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &v))
+	assert.Equal(t, float64(3), v.Value)
+
+	w = request(s, "GET", "/paths/Node/Counter", "", "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var resolved ResolvedPath
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resolved))
+	assert.Equal(t, ResolvedPath{ID: id, NodePath: "/Node", LocalPath: "Counter"}, resolved)
+
+	w = request(s, "GET", "/nodes/"+id+"/children", "", "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.JSONEq(t, "[]", w.Body.String())
+}
@@ -0,0 +1,113 @@
+package rpc
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	qrpc "github.com/manifold/qtalk/golang/rpc"
+	"github.com/manifold/tractor/pkg/manifold"
+	"github.com/manifold/tractor/pkg/misc/repl"
+	"github.com/manifold/tractor/pkg/workspace/view"
+)
+
+// REPL hijacks the call for an interactive JavaScript session on the live
+// object tree. Each line read from the channel is evaluated, and results and
+// errors are written back to it.
+func (s *Service) REPL() func(qrpc.Responder, *qrpc.Call) {
+	return func(r qrpc.Responder, c *qrpc.Call) {
+		ch, err := r.Hijack(nil)
+		if err != nil {
+			r.Return(err)
+			return
+		}
+		defer ch.Close()
+		s.newREPL(ch).Run(ch, ch, nil)
+	}
+}
+
+// newREPL returns a REPL that prints to w, with these globals:
+//
+//   root               the root object
+//   walk(obj, fn)      calls fn for the descendants of obj
+//   find(path)         the object at a path
+//   findID(id)         the object with an ID
+//   component(path)    the value of the component at a path
+//   get(path)          the value of the field at a path
+//   set(path, value)   sets the field at a path
+//   call(path)         calls the method at a path
+//   components()       the registered component types
+func (s *Service) newREPL(w io.Writer) *repl.REPL {
+	r := repl.NewREPL(func(v interface{}) {
+		fmt.Fprintln(w, repl.Format(v))
+	})
+	r.AfterEval = s.updateView
+	r.Set("root", s.State.Root)
+	r.Set("walk", manifold.Walk)
+	r.Set("find", func(path string) (manifold.Object, error) {
+		n, localPath, err := s.resolveObject(path)
+		if err == nil && localPath != "" {
+			err = errorf(NotFound, "unable to find node for path: %s", path)
+		}
+		return n, err
+	})
+	r.Set("findID", s.findNode)
+	r.Set("component", func(path string) (interface{}, error) {
+		n, localPath, err := s.resolveObject(path)
+		if err != nil {
+			return nil, err
+		}
+		com := n.Component(localPath)
+		if com == nil {
+			return nil, errorf(NotFound, "unable to find component: %s", localPath)
+		}
+		return com.Pointer(), nil
+	})
+	r.Set("get", func(path string) (interface{}, error) {
+		n, localPath, err := s.findPath(path)
+		if err != nil {
+			return nil, err
+		}
+		parts := strings.SplitN(localPath, "/", 2)
+		if len(parts) < 2 {
+			return nil, errorf(InvalidArgument, "not a field path: %s", path)
+		}
+		v, err := s.getField(n, parts[0], parts[1])
+		return v.Value, err
+	})
+	r.Set("set", func(path string, value interface{}) error {
+		n, localPath, err := s.findPath(path)
+		if err != nil {
+			return err
+		}
+		b, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		v := string(b)
+		return s.setValue(n, localPath, SetValueParams{JSONValue: &v})
+	})
+	r.Set("call", func(path string) error {
+		n, localPath, err := s.findPath(path)
+		if err != nil {
+			return err
+		}
+		return s.callMethod(n, localPath)
+	})
+	r.Set("components", view.RegisteredComponents)
+	return r
+}
+
+// resolveObject returns the object a path refers to and the rest of the path.
+func (s *Service) resolveObject(path string) (manifold.Object, string, error) {
+	resolved, err := s.resolvePath(path)
+	if err != nil {
+		return nil, "", err
+	}
+	if resolved.NodePath == "/" {
+		return s.State.Root, resolved.LocalPath, nil
+	}
+	n, err := s.findNode(resolved.ID)
+	return n, resolved.LocalPath, err
+}
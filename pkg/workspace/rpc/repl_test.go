@@ -0,0 +1,34 @@
+package rpc
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestREPL(t *testing.T) {
+	s, c, id := newGatewayService()
+	var out bytes.Buffer
+	r := s.newREPL(&out)
+
+	in := strings.NewReader(strings.Join([]string{
+		`call("/Node/Counter/Increment")`,
+		`component("/Node/Counter").Increment()`,
+		`set("/Node/Counter/Label", "label")`,
+		`get("/Node/Counter/Count")`,
+		`find("/Node").ID() == findID("` + id + `").ID()`,
+		`var names = []; walk(root, function(o) { names.push(o.Name()) }); names`,
+		`find("/Missing")`,
+	}, "\n"))
+	require.NoError(t, r.Run(in, &out, nil))
+
+	assert.Equal(t, 2, c.Count)
+	assert.Equal(t, "label", c.Label)
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 4)
+	assert.Equal(t, []string{"2", "true", `["Node"]`}, lines[:3])
+	assert.Contains(t, lines[3], "unable to find node")
+}
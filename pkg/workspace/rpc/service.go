@@ -5,9 +5,12 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"reflect"
+	"sync"
 
 	"github.com/manifold/qtalk/golang/mux"
 	qrpc "github.com/manifold/qtalk/golang/rpc"
+	"github.com/manifold/tractor/pkg/misc/auth"
 	"github.com/manifold/tractor/pkg/misc/logging"
 	"github.com/manifold/tractor/pkg/workspace/state"
 	"github.com/manifold/tractor/pkg/workspace/view"
@@ -17,32 +20,80 @@ type Service struct {
 	Protocol   string
 	ListenAddr string
 
+	// AuthKeyFile is the key tokens are verified with. Without it every
+	// client is an editor.
+	AuthKeyFile string
+	// Workspace is the name of the workspace, which tokens issued for a
+	// single workspace have to match.
+	Workspace string
+
 	Log   logging.Logger
 	State *state.Service
 
+	authKey   []byte
 	viewState *view.State
-	clients   map[qrpc.Caller]string
+	clients   map[qrpc.Caller]*client
+	presence  []view.Presence // last presence sent to clients
 	api       qrpc.API
 	l         mux.Listener
+	mu        sync.Mutex
+	updateMu  sync.Mutex // orders the versions of updates with their sends
 }
 
 func (s *Service) UpdateView() {
 	s.updateView()
 }
 
+// updateView sends the changes of the view to the subscribed clients. The
+// update lock is held until the last send so concurrent updates reach clients
+// in the order of their versions.
 func (s *Service) updateView() {
 	if s.viewState == nil || s.State == nil {
 		return
 	}
-	// TODO: mutex, etc
-	s.viewState.Update(s.State.Root)
-	for client, callback := range s.clients {
-		_, err := client.Call(callback, s.viewState, nil)
+	s.updateMu.Lock()
+	defer s.updateMu.Unlock()
+	patches := s.viewState.Changes(s.State.Root)
+	for {
+		s.mu.Lock()
+		presence := s.currentPresence()
+		if !reflect.DeepEqual(presence, s.presence) {
+			s.presence = presence
+			patches = append(patches, view.Patch{Op: "replace", Path: "/presence", Value: presence})
+		}
+		subscribed := s.subscribed()
+		s.mu.Unlock()
+
+		if !s.sendUpdates(subscribed, patches, presence) {
+			return
+		}
+		// update the presence of the remaining clients
+		patches = nil
+	}
+}
+
+// sendUpdates sends the patches to the clients and removes the clients they
+// couldn't be sent to. It returns whether any client was removed. It must be
+// called with updateMu held.
+func (s *Service) sendUpdates(clients []*client, patches []view.Patch, presence []view.Presence) (removed bool) {
+	for _, c := range clients {
+		u, ok := c.session.Update(patches)
+		if !ok {
+			continue
+		}
+		var err error
+		if c.patches {
+			_, err = c.caller.Call("patch", u, nil)
+		} else {
+			_, err = c.caller.Call("state", s.viewState.ClientState(c.session, presence), nil)
+		}
 		if err != nil {
-			delete(s.clients, client)
+			s.removeClient(c.caller)
+			removed = true
 			log.Println(err)
 		}
 	}
+	return removed
 }
 
 func (s *Service) InitializeDaemon() (err error) {
@@ -50,25 +101,44 @@ func (s *Service) InitializeDaemon() (err error) {
 		return err
 	}
 
-	s.clients = make(map[qrpc.Caller]string)
+	if s.AuthKeyFile != "" {
+		if s.authKey, err = auth.LoadKey(s.AuthKeyFile); err != nil {
+			return err
+		}
+	}
+
+	s.clients = make(map[qrpc.Caller]*client)
 	s.viewState = view.New(s.State.Root)
 
 	s.api = qrpc.NewAPI()
-	s.api.HandleFunc("reload", s.Reload())
-	s.api.HandleFunc("selectNode", s.SelectNode())
-	s.api.HandleFunc("removeComponent", s.RemoveComponent())
-	s.api.HandleFunc("reloadComponent", s.ReloadComponent())
-	s.api.HandleFunc("selectProject", s.SelectProject())
-	s.api.HandleFunc("moveNode", s.MoveNode())
-	s.api.HandleFunc("subscribe", s.Subscribe())
-	s.api.HandleFunc("appendNode", s.AppendNode())
-	s.api.HandleFunc("deleteNode", s.DeleteNode())
-	s.api.HandleFunc("appendComponent", s.AppendComponent())
-	s.api.HandleFunc("setValue", s.SetValue())
-	// s.api.HandleFunc("setExpression", s.SetExpression())
-	s.api.HandleFunc("callMethod", s.CallMethod())
-	s.api.HandleFunc("updateNode", s.UpdateNode())
-	s.api.HandleFunc("addDelegate", s.AddDelegate())
+	s.api.HandleFunc("authenticate", handler(s.Authenticate()))
+	s.handle("reload", auth.Viewer, s.Reload())
+	s.handle("selectNode", auth.Viewer, s.SelectNode())
+	s.handle("removeComponent", auth.Editor, s.RemoveComponent())
+	s.handle("reloadComponent", auth.Invoker, s.ReloadComponent())
+	s.handle("selectProject", auth.Viewer, s.SelectProject())
+	s.handle("moveNode", auth.Editor, s.MoveNode())
+	s.handle("subscribe", auth.Viewer, s.Subscribe())
+	s.handle("resync", auth.Viewer, s.Resync())
+	s.handle("appendNode", auth.Editor, s.AppendNode())
+	s.handle("deleteNode", auth.Editor, s.DeleteNode())
+	s.handle("appendComponent", auth.Editor, s.AppendComponent())
+	s.handle("setValue", auth.Editor, s.SetValue())
+	// s.handle("setExpression", auth.Editor, s.SetExpression())
+	s.handle("callMethod", auth.Invoker, s.CallMethod())
+	s.handle("updateNode", auth.Editor, s.UpdateNode())
+	s.handle("addDelegate", auth.Editor, s.AddDelegate())
+	s.handle("delegateTemplates", auth.Viewer, s.DelegateTemplates())
+	s.handle("loadPlugin", auth.Editor, s.LoadPlugin())
+	s.handle("getNode", auth.Viewer, s.GetNode())
+	s.handle("getField", auth.Viewer, s.GetField())
+	s.handle("listChildren", auth.Viewer, s.ListChildren())
+	s.handle("listComponents", auth.Viewer, s.ListComponents())
+	s.handle("listRegisteredComponents", auth.Viewer, s.ListRegisteredComponents())
+	s.handle("getSnapshot", auth.Viewer, s.GetSnapshot())
+	s.handle("health", auth.Viewer, s.Health())
+	s.handle("resolvePath", auth.Viewer, s.ResolvePath())
+	s.handle("repl", auth.Editor, s.REPL())
 
 	return nil
 }
@@ -85,8 +155,11 @@ func (s *Service) Serve(ctx context.Context) {
 }
 
 func (s *Service) TerminateDaemon() error {
-	for client, _ := range s.clients {
-		client.Call("shutdown", nil, nil)
+	s.mu.Lock()
+	clients := s.subscribed()
+	s.mu.Unlock()
+	for _, c := range clients {
+		c.caller.Call("shutdown", nil, nil)
 	}
 	if s.Protocol == "unix" {
 		os.Remove(s.ListenAddr)
@@ -0,0 +1,123 @@
+package rpc
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/manifold/qtalk/golang/mux"
+	qrpc "github.com/manifold/qtalk/golang/rpc"
+	"github.com/manifold/tractor/pkg/manifold/object"
+	"github.com/manifold/tractor/pkg/workspace/view"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// updateCaller records the updates sent to a client, or fails sending.
+type updateCaller struct {
+	fail    bool
+	updates []view.Update
+	mu      sync.Mutex
+}
+
+func (c *updateCaller) Call(path string, args, reply interface{}) (*qrpc.Response, error) {
+	if c.fail {
+		return nil, errors.New("closed")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if u, ok := args.(view.Update); ok {
+		c.updates = append(c.updates, u)
+	}
+	return &qrpc.Response{}, nil
+}
+
+func subscribeCaller(s *Service, caller qrpc.Caller) {
+	c := s.client(caller)
+	s.mu.Lock()
+	c.subscribed = true
+	c.patches = true
+	s.mu.Unlock()
+}
+
+func TestUpdateViewOrder(t *testing.T) {
+	s, _, _ := newGatewayService()
+	caller := &updateCaller{}
+	subscribeCaller(s, caller)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			s.State.Root.AppendChild(object.New(fmt.Sprintf("Child%d", i)))
+			s.updateView()
+		}(i)
+	}
+	wg.Wait()
+
+	require.NotEmpty(t, caller.updates)
+	for i, u := range caller.updates {
+		assert.Equal(t, i, u.BaseVersion)
+		assert.Equal(t, i+1, u.Version)
+	}
+}
+
+func TestUpdateViewRemovesClients(t *testing.T) {
+	s, _, _ := newGatewayService()
+	caller := &updateCaller{}
+	failing := &updateCaller{fail: true}
+	subscribeCaller(s, caller)
+	subscribeCaller(s, failing)
+
+	s.updateView()
+	assert.NotContains(t, s.clients, failing)
+	// the remaining client gets the presence without the removed client
+	require.Len(t, caller.updates, 2)
+	last := caller.updates[1].Patches
+	require.Len(t, last, 1)
+	assert.Equal(t, "/presence", last[0].Path)
+	assert.Len(t, last[0].Value, 1)
+}
+
+// testSession is a session that ends when it is closed.
+type testSession struct {
+	mux.Session
+	closed chan struct{}
+}
+
+func (s *testSession) Close() error {
+	close(s.closed)
+	return nil
+}
+
+func (s *testSession) Wait() error {
+	<-s.closed
+	return nil
+}
+
+func TestClientClosed(t *testing.T) {
+	s, _, _ := newGatewayService()
+	sess := &testSession{closed: make(chan struct{})}
+	caller := &qrpc.Client{Session: sess}
+	s.client(caller)
+	s.client(&testCaller{})
+	require.Len(t, s.clients, 2)
+
+	sess.Close()
+	for i := 0; i < 100; i++ {
+		s.mu.Lock()
+		n := len(s.clients)
+		s.mu.Unlock()
+		if n == 1 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	assert.NotContains(t, s.clients, caller)
+	assert.Len(t, s.clients, 1)
+}
@@ -0,0 +1,68 @@
+package state
+
+import (
+	"github.com/manifold/tractor/pkg/manifold"
+)
+
+// Health is the state of the workspace reported to the agent, which marks
+// the workspace available once it is ready and degraded if components have
+// errors.
+type Health struct {
+	ImageLoaded bool             `msgpack:"imageLoaded" json:"imageLoaded"`
+	Initialized bool             `msgpack:"initialized" json:"initialized"` // components were initialized
+	Errors      []ComponentError `msgpack:"errors" json:"errors"`
+}
+
+// Ready returns whether the workspace loaded its image and initialized its
+// components.
+func (h Health) Ready() bool {
+	return h.ImageLoaded && h.Initialized
+}
+
+// ComponentError is an error of a component, either returned by its
+// Initialize method or by its CheckHealth method.
+type ComponentError struct {
+	Node      string `msgpack:"node" json:"node"` // path of the object
+	Component string `msgpack:"component" json:"component"`
+	Error     string `msgpack:"error" json:"error"`
+}
+
+// healthChecker is implemented by components that can report problems while
+// the workspace is running.
+type healthChecker interface {
+	CheckHealth() error
+}
+
+// Health returns the health of the workspace. The errors of components are
+// their initialization errors followed by the errors of their current health
+// checks.
+func (s *Service) Health() Health {
+	s.mu.Lock()
+	h := Health{
+		ImageLoaded: s.loaded,
+		Initialized: s.initialized,
+		Errors:      append([]ComponentError{}, s.initErrors...),
+	}
+	s.mu.Unlock()
+	if s.Root == nil || !h.Initialized {
+		return h
+	}
+	manifold.Walk(s.Root, func(n manifold.Object) {
+		for _, com := range n.Components() {
+			if checker, ok := com.Pointer().(healthChecker); ok {
+				if err := checker.CheckHealth(); err != nil {
+					h.Errors = append(h.Errors, componentError(n, com, err))
+				}
+			}
+		}
+	})
+	return h
+}
+
+func componentError(n manifold.Object, com manifold.Component, err error) ComponentError {
+	return ComponentError{
+		Node:      n.Path(),
+		Component: com.Name(),
+		Error:     err.Error(),
+	}
+}
@@ -0,0 +1,45 @@
+package state
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/manifold/tractor/pkg/manifold/library"
+	"github.com/manifold/tractor/pkg/manifold/object"
+	"github.com/stretchr/testify/assert"
+)
+
+type checked struct {
+	Err string
+}
+
+func (c *checked) CheckHealth() error {
+	if c.Err != "" {
+		return errors.New(c.Err)
+	}
+	return nil
+}
+
+func TestHealth(t *testing.T) {
+	root := object.New("::root")
+	node := object.New("Node")
+	root.AppendChild(node)
+	c := &checked{}
+	node.AppendComponent(library.NewComponent("Checked", c, ""))
+	s := &Service{Root: root}
+
+	assert.False(t, s.Health().Ready())
+	assert.Empty(t, s.Health().Errors)
+
+	s.loaded, s.initialized = true, true
+	s.initErrors = []ComponentError{{Node: "/Other", Component: "Init", Error: "failed"}}
+	h := s.Health()
+	assert.True(t, h.Ready())
+	assert.Equal(t, s.initErrors, h.Errors)
+
+	c.Err = "unhealthy"
+	assert.Equal(t, []ComponentError{
+		{Node: "/Other", Component: "Init", Error: "failed"},
+		{Node: "/Node", Component: "Checked", Error: "unhealthy"},
+	}, s.Health().Errors)
+}
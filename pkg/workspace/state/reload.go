@@ -0,0 +1,80 @@
+package state
+
+import (
+	"plugin"
+
+	"github.com/manifold/tractor/pkg/manifold"
+	"github.com/manifold/tractor/pkg/manifold/image"
+	"github.com/manifold/tractor/pkg/manifold/library"
+)
+
+// LoadPlugin opens a Go plugin built from delegate packages and swaps the
+// components registered with the same IDs as the ones the plugin registers.
+// Swapped components keep their state and references and are reloaded. It
+// returns the IDs of the replaced registered components.
+func (s *Service) LoadPlugin(path string) ([]string, error) {
+	rcs, err := library.Replace(func() error {
+		_, err := plugin.Open(path)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	replaced := make(map[string]*library.RegisteredComponent)
+	var ids []string
+	for _, rc := range rcs {
+		replaced[rc.ID] = rc
+		ids = append(ids, rc.ID)
+	}
+
+	var swapped []manifold.Component
+	objects := make(map[string]manifold.Object)
+	manifold.Walk(s.Root, func(o manifold.Object) {
+		for _, com := range o.Components() {
+			if _, ok := replaced[com.ID()]; ok {
+				swapped = append(swapped, com)
+				objects[o.ID()] = o
+			}
+		}
+	})
+
+	// references from and to swapped components point at the old values
+	var refs []manifold.SnapshotRef
+	manifold.Walk(s.Root, func(o manifold.Object) {
+		for _, com := range o.Components() {
+			for _, ref := range com.Snapshot().Refs {
+				_, from := objects[ref.ObjectID]
+				_, to := objects[ref.TargetID]
+				if from || to {
+					refs = append(refs, ref)
+				}
+			}
+		}
+	})
+
+	for _, com := range swapped {
+		if err := library.Swap(com, replaced[com.ID()]); err != nil {
+			s.Log.Info("[reload]", com.Name(), err)
+		}
+	}
+	image.ResolveRefs(s.Root, refs)
+	for _, obj := range objects {
+		if err := obj.UpdateRegistry(); err != nil {
+			s.Log.Info("[reload]", obj.Name(), err)
+		}
+	}
+	for _, com := range swapped {
+		if initializer, ok := com.Pointer().(initializer); ok {
+			if err := initializer.Initialize(); err != nil {
+				s.Log.Info("[reload]", com.Name(), err)
+			}
+		}
+		if !com.Enabled() {
+			continue
+		}
+		if err := com.Reload(); err != nil {
+			s.Log.Info("[reload]", com.Name(), err)
+		}
+	}
+	return ids, nil
+}
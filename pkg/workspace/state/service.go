@@ -4,6 +4,7 @@ import (
 	"context"
 	"log"
 	"os"
+	"sync"
 	"time"
 
 	"github.com/manifold/tractor/pkg/manifold"
@@ -20,6 +21,11 @@ type Service struct {
 	Log   logging.Logger
 	Root  manifold.Object
 	Image *image.Image
+
+	loaded      bool
+	initialized bool
+	initErrors  []ComponentError
+	mu          sync.Mutex
 }
 
 func (s *Service) InitializeDaemon() (err error) {
@@ -33,16 +39,25 @@ func (s *Service) InitializeDaemon() (err error) {
 	if err != nil {
 		return err
 	}
+	s.mu.Lock()
+	s.loaded = true
+	s.mu.Unlock()
 
+	var initErrors []ComponentError
 	manifold.Walk(s.Root, func(n manifold.Object) {
 		for _, com := range n.Components() {
 			if initializer, ok := com.Pointer().(initializer); ok {
 				if err := initializer.Initialize(); err != nil {
 					log.Print(err)
+					initErrors = append(initErrors, componentError(n, com, err))
 				}
 			}
 		}
 	})
+	s.mu.Lock()
+	s.initialized = true
+	s.initErrors = initErrors
+	s.mu.Unlock()
 
 	debounce := debouncer.New(2 * time.Second)
 	notify.Observe(s.Root, notify.Func(func(event interface{}) {
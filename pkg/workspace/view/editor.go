@@ -0,0 +1,311 @@
+package view
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/manifold/tractor/pkg/manifold/library"
+	"github.com/manifold/tractor/pkg/misc/jsonpointer"
+)
+
+// Editor describes how the inspector edits a field. It is declared with these
+// `tractor` struct tag options:
+//
+//	enum=a|b|c       choices for strings and numbers
+//	range=0:100      minimum and maximum of numbers
+//	multiline        a text area for strings
+//	filepath         a file path for strings
+//	color            a hex color like #ff0000 for strings
+//	placeholder=...  text shown while a string is empty
+//	readonly         the field can't be set from the inspector
+//	section=...      the collapsible section the field is grouped in
+//
+// The options of a slice, array or map field apply to its elements.
+type Editor struct {
+	Kind        string // "multiline", "filepath", "color" or empty
+	Enum        []EnumValue
+	Range       *Range
+	Placeholder string
+	ReadOnly    bool
+	Section     string
+}
+
+// Range is the range of values of a number field.
+type Range struct {
+	Min float64 `msgpack:"min" json:"min"`
+	Max float64 `msgpack:"max" json:"max"`
+}
+
+// fieldOptions are the field options of `tractor` struct tags other than the
+// options of the Editor.
+var fieldOptions = map[string]bool{
+	"name":   true,
+	"help":   true,
+	"hidden": true,
+}
+
+var colorPattern = regexp.MustCompile(`^#([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+
+// ParseEditor returns the editor declared by the parsed `tractor` tag of a
+// field of type t. Unknown options and options that don't fit the type are
+// errors.
+func ParseEditor(t reflect.Type, tags map[string]string) (Editor, error) {
+	var e Editor
+	elem := elemType(t)
+	for key, value := range tags {
+		switch key {
+		case "enum":
+			if !isString(elem) && !isNumber(elem) {
+				return e, fmt.Errorf("enum needs a string or number field, not %s", t)
+			}
+			if value == "" {
+				return e, fmt.Errorf("enum needs at least one value")
+			}
+			for _, s := range strings.Split(value, "|") {
+				v, err := parseScalar(elem, s)
+				if err != nil {
+					return e, fmt.Errorf("invalid enum value %q: %v", s, err)
+				}
+				e.Enum = append(e.Enum, EnumValue{Label: s, Value: v.Interface()})
+			}
+		case "range":
+			if !isNumber(elem) {
+				return e, fmt.Errorf("range needs a number field, not %s", t)
+			}
+			parts := strings.SplitN(value, ":", 2)
+			if len(parts) != 2 {
+				return e, fmt.Errorf("invalid range %q: expected min:max", value)
+			}
+			min, err := strconv.ParseFloat(parts[0], 64)
+			if err != nil {
+				return e, fmt.Errorf("invalid range %q: %v", value, err)
+			}
+			max, err := strconv.ParseFloat(parts[1], 64)
+			if err != nil {
+				return e, fmt.Errorf("invalid range %q: %v", value, err)
+			}
+			if min > max {
+				return e, fmt.Errorf("invalid range %q: min is greater than max", value)
+			}
+			e.Range = &Range{Min: min, Max: max}
+		case "multiline", "filepath", "color":
+			if !isString(elem) {
+				return e, fmt.Errorf("%s needs a string field, not %s", key, t)
+			}
+			if e.Kind != "" {
+				return e, fmt.Errorf("%s can't be combined with %s", key, e.Kind)
+			}
+			e.Kind = key
+		case "placeholder":
+			if !isString(elem) {
+				return e, fmt.Errorf("placeholder needs a string field, not %s", t)
+			}
+			e.Placeholder = value
+		case "readonly":
+			e.ReadOnly = true
+		case "section":
+			if value == "" {
+				return e, fmt.Errorf("section needs a name")
+			}
+			e.Section = value
+		default:
+			if !fieldOptions[key] {
+				return e, fmt.Errorf("unknown option %q", key)
+			}
+		}
+	}
+	if e.Enum != nil && (e.Kind != "" || e.Range != nil) {
+		return e, fmt.Errorf("enum can't be combined with other editors")
+	}
+	return e, nil
+}
+
+// FieldEditor returns the editor of the field at path in a value of type t,
+// which is declared by the last struct field on the path. Fields under a
+// read-only field are read-only too.
+func FieldEditor(t reflect.Type, path string) (Editor, error) {
+	var e Editor
+	var readOnly bool
+	for _, part := range strings.Split(strings.Trim(path, "/"), "/") {
+		for t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		switch t.Kind() {
+		case reflect.Struct:
+			i, ok := jsonpointer.StructField(t, part)
+			if !ok {
+				return Editor{}, fmt.Errorf("unable to find field: %s", part)
+			}
+			sf := t.Field(i)
+			var err error
+			e, err = ParseEditor(sf.Type, library.ParseTag(sf.Tag.Get(library.TagKey)))
+			if err != nil {
+				return Editor{}, fmt.Errorf("invalid tag of %s: %v", sf.Name, err)
+			}
+			readOnly = readOnly || e.ReadOnly
+			t = sf.Type
+		case reflect.Slice, reflect.Array, reflect.Map:
+			t = t.Elem()
+		default:
+			e.ReadOnly = readOnly
+			return e, nil
+		}
+	}
+	e.ReadOnly = readOnly
+	return e, nil
+}
+
+// Check returns an error if v is not one of the choices of the editor, is out
+// of its range or is not a color. Slices, arrays and maps are checked by their
+// elements.
+func (e Editor) Check(v reflect.Value) error {
+	if !v.IsValid() {
+		return nil
+	}
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := e.Check(v.Index(i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Map:
+		for _, k := range v.MapKeys() {
+			if err := e.Check(v.MapIndex(k)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if e.Enum != nil {
+		for _, ev := range e.Enum {
+			if ev.Value == v.Interface() {
+				return nil
+			}
+			// numbers can be set from numbers of other types
+			et := reflect.TypeOf(ev.Value)
+			if isNumber(et) && isNumber(v.Type()) && v.Convert(et).Interface() == ev.Value {
+				return nil
+			}
+		}
+		return fmt.Errorf("%v is not one of its values", v.Interface())
+	}
+	if e.Range != nil && isNumber(v.Type()) {
+		f := toFloat(v)
+		if f < e.Range.Min || f > e.Range.Max {
+			return fmt.Errorf("%v is out of range %v:%v", v.Interface(), e.Range.Min, e.Range.Max)
+		}
+	}
+	if e.Kind == "color" && v.Kind() == reflect.String && v.String() != "" && !colorPattern.MatchString(v.String()) {
+		return fmt.Errorf("%q is not a color like #ff0000", v.String())
+	}
+	return nil
+}
+
+// apply sets the editor of f and the elements of f. Fields of read-only
+// structs are read-only too.
+func (e Editor) apply(f *Field) {
+	switch f.Type {
+	case "array", "map":
+		elems := e
+		elems.Section = ""
+		for i := range f.Fields {
+			elems.apply(&f.Fields[i])
+		}
+	case "struct":
+		for i := range f.Fields {
+			Editor{ReadOnly: e.ReadOnly}.apply(&f.Fields[i])
+		}
+	}
+	if e.Kind != "" {
+		f.Editor = e.Kind
+	}
+	if e.Placeholder != "" {
+		f.Placeholder = e.Placeholder
+	}
+	if e.Section != "" {
+		f.Section = e.Section
+	}
+	if e.Enum != nil {
+		f.Enum = e.Enum
+	}
+	if e.Range != nil {
+		r := *e.Range
+		f.Range = &r
+	}
+	f.ReadOnly = f.ReadOnly || e.ReadOnly
+}
+
+// elemType returns the type of the values held by a field of type t.
+func elemType(t reflect.Type) reflect.Type {
+	for {
+		switch t.Kind() {
+		case reflect.Ptr, reflect.Slice, reflect.Array, reflect.Map:
+			t = t.Elem()
+		default:
+			return t
+		}
+	}
+}
+
+func isString(t reflect.Type) bool {
+	return t.Kind() == reflect.String
+}
+
+func isNumber(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// parseScalar parses s into a value of the string or number type t.
+func parseScalar(t reflect.Type, s string) (reflect.Value, error) {
+	v := reflect.New(t).Elem()
+	switch t.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(s, 10, t.Bits())
+		if err != nil {
+			return v, err
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(s, 10, t.Bits())
+		if err != nil {
+			return v, err
+		}
+		v.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, t.Bits())
+		if err != nil {
+			return v, err
+		}
+		v.SetFloat(f)
+	}
+	return v, nil
+}
+
+func toFloat(v reflect.Value) float64 {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint())
+	}
+	return v.Float()
+}
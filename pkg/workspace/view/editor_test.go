@@ -0,0 +1,131 @@
+package view
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/manifold/tractor/pkg/manifold/library"
+	"github.com/manifold/tractor/pkg/manifold/object"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type display struct {
+	Title  string   `tractor:"placeholder=Untitled,section=General"`
+	Notes  string   `tractor:"multiline,section=General"`
+	Mode   string   `tractor:"enum=fast|slow"`
+	Steps  []int    `tractor:"enum=1|2|4"`
+	Volume float64  `tractor:"range=0:11"`
+	Color  string   `tractor:"color"`
+	Icon   string   `tractor:"filepath,help=Icon to show"`
+	ID     string   `tractor:"readonly"`
+	Stats  stats    `tractor:"readonly"`
+	Broken int      `tractor:"multiline"`
+	Tags   []string `tractor:"section=Extra"`
+}
+
+type stats struct {
+	Count int `tractor:"range=0:10"`
+	Max   int `json:"max" tractor:"range=0:100"`
+}
+
+func TestParseEditor(t *testing.T) {
+	stringType := reflect.TypeOf("")
+	intType := reflect.TypeOf(0)
+
+	e, err := ParseEditor(intType, library.ParseTag("enum=1|2,name=Size,help=How big"))
+	require.NoError(t, err)
+	assert.Equal(t, []EnumValue{{Label: "1", Value: 1}, {Label: "2", Value: 2}}, e.Enum)
+
+	e, err = ParseEditor(reflect.TypeOf([]float32{}), library.ParseTag("range=-1:1.5"))
+	require.NoError(t, err)
+	assert.Equal(t, &Range{Min: -1, Max: 1.5}, e.Range)
+
+	for tag, typ := range map[string]reflect.Type{
+		"enum=a|b":           intType,
+		"enum=":              stringType,
+		"range=0:100":        stringType,
+		"range=100":          intType,
+		"range=10:1":         intType,
+		"range=a:b":          intType,
+		"multiline":          intType,
+		"color,filepath":     stringType,
+		"placeholder=none":   intType,
+		"section=":           stringType,
+		"enum=1|2,range=0:2": intType,
+		"unknown":            stringType,
+	} {
+		_, err := ParseEditor(typ, library.ParseTag(tag))
+		assert.Error(t, err, tag)
+	}
+}
+
+func TestFieldEditor(t *testing.T) {
+	typ := reflect.TypeOf(&display{})
+
+	e, err := FieldEditor(typ, "Steps/0")
+	require.NoError(t, err)
+	assert.Len(t, e.Enum, 3)
+
+	e, err = FieldEditor(typ, "Stats/Count")
+	require.NoError(t, err)
+	assert.True(t, e.ReadOnly)
+	assert.Equal(t, &Range{Min: 0, Max: 10}, e.Range)
+
+	// fields are found by their JSON names like in jsonpointer paths
+	e, err = FieldEditor(typ, "Stats/max")
+	require.NoError(t, err)
+	assert.Equal(t, &Range{Min: 0, Max: 100}, e.Range)
+
+	_, err = FieldEditor(typ, "Broken")
+	assert.Error(t, err)
+	_, err = FieldEditor(typ, "Missing")
+	assert.Error(t, err)
+}
+
+func TestEditorCheck(t *testing.T) {
+	typ := reflect.TypeOf(&display{})
+	check := func(path string, v interface{}) error {
+		e, err := FieldEditor(typ, path)
+		require.NoError(t, err)
+		return e.Check(reflect.ValueOf(v))
+	}
+
+	assert.NoError(t, check("Mode", "fast"))
+	assert.Error(t, check("Mode", "medium"))
+	assert.NoError(t, check("Steps", []int{1, 4}))
+	assert.Error(t, check("Steps", []int{1, 3}))
+	assert.NoError(t, check("Steps/0", int64(2)))
+	assert.NoError(t, check("Volume", 11.0))
+	assert.Error(t, check("Volume", 11.5))
+	assert.NoError(t, check("Color", "#0af"))
+	assert.NoError(t, check("Color", ""))
+	assert.Error(t, check("Color", "red"))
+	assert.NoError(t, check("Mode", nil))
+}
+
+func TestExportEditors(t *testing.T) {
+	node := object.New("Node")
+	object.New("::root").AppendChild(node)
+	node.AppendComponent(library.NewComponent("Display", &display{Steps: []int{1}}, ""))
+
+	fields := make(map[string]Field)
+	for _, f := range ExportNode(node).Components[0].Fields {
+		fields[f.Name] = f
+	}
+
+	assert.Equal(t, "Untitled", fields["Title"].Placeholder)
+	assert.Equal(t, "General", fields["Title"].Section)
+	assert.Equal(t, "multiline", fields["Notes"].Editor)
+	assert.Equal(t, []EnumValue{{Label: "fast", Value: "fast"}, {Label: "slow", Value: "slow"}}, fields["Mode"].Enum)
+	assert.Equal(t, "string", fields["Mode"].Type)
+	assert.Len(t, fields["Steps"].Fields[0].Enum, 3)
+	assert.Equal(t, &Range{Min: 0, Max: 11}, fields["Volume"].Range)
+	assert.Equal(t, "color", fields["Color"].Editor)
+	assert.Equal(t, "filepath", fields["Icon"].Editor)
+	assert.True(t, fields["ID"].ReadOnly)
+	assert.True(t, fields["Stats"].Fields[0].ReadOnly)
+	assert.Equal(t, &Range{Min: 0, Max: 10}, fields["Stats"].Fields[0].Range)
+	assert.Contains(t, fields["Broken"].Error, "multiline needs a string field")
+	assert.Equal(t, "Extra", fields["Tags"].Section)
+}
@@ -1,13 +1,18 @@
 package view
 
 import (
+	"encoding"
+	"encoding/base64"
 	"fmt"
 	"reflect"
+	"sort"
 	"strconv"
 	"sync"
+	"time"
 
 	"github.com/manifold/tractor/pkg/manifold"
 	"github.com/manifold/tractor/pkg/manifold/library"
+	"github.com/manifold/tractor/pkg/misc/notify"
 
 	//"github.com/manifold/tractor/pkg/repl"
 
@@ -15,317 +20,424 @@ import (
 )
 
 type Field struct {
-	Type       string      `msgpack:"type"`
-	Name       string      `msgpack:"name"`
-	Path       string      `msgpack:"path"`
-	Value      interface{} `msgpack:"value"`
-	Expression *string     `msgpack:"expression"`
-	Fields     []Field     `msgpack:"fields"`
+	Type        string      `msgpack:"type" json:"type"`
+	Name        string      `msgpack:"name" json:"name"`
+	DisplayName string      `msgpack:"displayName" json:"displayName"`
+	Description string      `msgpack:"description" json:"description"`
+	Path        string      `msgpack:"path" json:"path"`
+	Value       interface{} `msgpack:"value" json:"value"`
+	Expression  *string     `msgpack:"expression" json:"expression"`
+	Fields      []Field     `msgpack:"fields" json:"fields"`
+	Enum        []EnumValue `msgpack:"enum" json:"enum"`
+	Range       *Range      `msgpack:"range" json:"range"`
+	Editor      string      `msgpack:"editor" json:"editor"`
+	Placeholder string      `msgpack:"placeholder" json:"placeholder"`
+	ReadOnly    bool        `msgpack:"readOnly" json:"readOnly"`
+	Section     string      `msgpack:"section" json:"section"`
+	Error       string      `msgpack:"error" json:"error"`
 }
 
 type Button struct {
-	Name    string `msgpack:"name"`
-	Path    string `msgpack:"path"`
-	OnClick string `msgpack:"onclick"`
+	Name    string `msgpack:"name" json:"name"`
+	Path    string `msgpack:"path" json:"path"`
+	OnClick string `msgpack:"onclick" json:"onclick"`
 }
 
 type Component struct {
-	Name     string   `msgpack:"name"`
-	Filepath string   `msgpack:"filepath"`
-	Fields   []Field  `msgpack:"fields"`
-	Buttons  []Button `msgpack:"buttons"`
-	Related  []string `msgpack:"related"`
+	Name        string   `msgpack:"name" json:"name"`
+	DisplayName string   `msgpack:"displayName" json:"displayName"`
+	Description string   `msgpack:"description" json:"description"`
+	Icon        string   `msgpack:"icon" json:"icon"`
+	Filepath    string   `msgpack:"filepath" json:"filepath"`
+	Fields      []Field  `msgpack:"fields" json:"fields"`
+	Buttons     []Button `msgpack:"buttons" json:"buttons"`
+	Related     []string `msgpack:"related" json:"related"`
 }
 
 type Node struct {
-	Name       string      `msgpack:"name"`
-	Path       string      `msgpack:"path"`
-	Dir        string      `msgpack:"dir"`
-	ID         string      `msgpack:"id"`
-	Index      int         `msgpack:"index"`
-	Active     bool        `msgpack:"active"`
-	Components []Component `msgpack:"components"`
+	Name       string      `msgpack:"name" json:"name"`
+	Path       string      `msgpack:"path" json:"path"`
+	Dir        string      `msgpack:"dir" json:"dir"`
+	ID         string      `msgpack:"id" json:"id"`
+	Index      int         `msgpack:"index" json:"index"`
+	Active     bool        `msgpack:"active" json:"active"`
+	Components []Component `msgpack:"components" json:"components"`
 }
 
 type Project struct {
-	Name string `msgpack:"name"`
-	Path string `msgpack:"path"`
+	Name string `msgpack:"name" json:"name"`
+	Path string `msgpack:"path" json:"path"`
 }
 
+// State is the view state shared by all sessions. Clients get it as part of
+// their ClientState.
 type State struct {
-	Projects       []Project         `msgpack:"projects"`
-	CurrentProject string            `msgpack:"currentProject"`
-	Components     []ComponentType   `msgpack:"components"`
-	Hierarchy      []string          `msgpack:"hierarchy"`
-	Nodes          map[string]Node   `msgpack:"nodes"`
-	NodePaths      map[string]string `msgpack:"nodePaths"`
-	SelectedNode   string            `msgpack:"selectedNode"`
+	Projects   []Project
+	Components []ComponentType
+	Hierarchy  []string
+	Nodes      map[string]Node
+	NodePaths  map[string]string
+
+	dirty      map[string]bool // IDs of objects changed since the last patches
+	structural bool            // if the hierarchy changed since the last patches
 
 	mu sync.Mutex
 }
 
-func exportElem(v reflected.Value, path string, idx int, n manifold.Object) (Field, bool) {
-	elemPath := path + "/" + strconv.Itoa(idx)
-	switch v.Type().Kind() {
-	case reflect.Bool:
-		return Field{
-			Path:  elemPath,
-			Type:  "boolean",
-			Value: v.Interface(),
-		}, true
-	case reflect.String:
-		return Field{
-			Path:  elemPath,
-			Type:  "string",
-			Value: v.Interface(),
-		}, true
-	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
-		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
-		reflect.Float32, reflect.Float64:
-		return Field{
-			Path:  elemPath,
-			Type:  "number",
-			Value: v.Interface(),
-		}, true
-	default:
-		return Field{}, false
-	}
+// Enum is implemented by named types with a fixed set of values, which the
+// inspector offers as choices. Values are labeled with their String method if
+// they have one.
+type Enum interface {
+	EnumValues() []interface{}
+}
+
+// EnumValue is a choice of an enum field.
+type EnumValue struct {
+	Label string      `msgpack:"label" json:"label"`
+	Value interface{} `msgpack:"value" json:"value"`
 }
 
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	durationType = reflect.TypeOf(time.Duration(0))
+	enumType     = reflect.TypeOf((*Enum)(nil)).Elem()
+	textType     = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
 func exportField(o reflected.Value, field, path string, n manifold.Object) Field {
-	var kind reflect.Kind
+	// the declared type is needed for nil pointers and interfaces
+	var t reflect.Type
 	if o.Type().Kind() == reflect.Struct {
-		kind = o.Type().FieldType(field).Kind()
-	} else {
-		if !o.Get(field).IsValid() {
-			kind = reflect.Invalid
-		} else {
-			kind = o.Get(field).Type().Kind()
+		t = o.Type().FieldType(field).Type
+	}
+	var v reflect.Value
+	if fv := o.Get(field); fv.IsValid() {
+		v = reflect.ValueOf(fv.Interface())
+		if t == nil {
+			t = v.Type()
 		}
 	}
-	fieldPath := path + "/" + field
-	// var expr *string
-	// exprPath := fieldPath[len(n.FullPath())+1:]
-	// if e := n.Expression(exprPath); e != "" {
-	// 	expr = &e
-	// }
-	switch kind {
-	case reflect.Invalid:
-		return Field{
-			Name: field,
-			Path: fieldPath,
-			// Expression: expr,
-			Type:  "string",
-			Value: "INVALID",
+	return exportValue(v, t, field, path+"/"+field, n, make(map[pointee]string))
+}
+
+// pointee identifies the value a pointer points to.
+type pointee struct {
+	addr uintptr
+	t    reflect.Type
+}
+
+// exportValue returns the field of the value v of type t. The value is invalid
+// for nil interfaces. Seen has the paths of the values pointed to by the
+// pointers followed to get to v, to stop at cycles.
+func exportValue(v reflect.Value, t reflect.Type, name, path string, n manifold.Object, seen map[pointee]string) Field {
+	f := Field{Name: name, Path: path}
+	if t == nil {
+		f.Type = "string"
+		f.Value = "INVALID"
+		return f
+	}
+	if !v.IsValid() {
+		v = reflect.Zero(t)
+	}
+	switch {
+	case t == timeType:
+		f.Type = "time"
+		f.Value = v.Interface().(time.Time).Format(time.RFC3339Nano)
+		return f
+	case t == durationType:
+		f.Type = "duration"
+		f.Value = v.Interface().(time.Duration).String()
+		return f
+	case t.Kind() != reflect.Ptr && t.Kind() != reflect.Interface && t.Implements(enumType):
+		f.Type = "enum"
+		f.Value = v.Interface()
+		for _, e := range v.Interface().(Enum).EnumValues() {
+			f.Enum = append(f.Enum, EnumValue{Label: fmt.Sprint(e), Value: e})
 		}
-	case reflect.Bool:
-		return Field{
-			Name: field,
-			Path: fieldPath,
-			// Expression: expr,
-			Type:  "boolean",
-			Value: o.Get(field).Interface(),
+		return f
+	case t.Kind() != reflect.Ptr && t.Kind() != reflect.Interface && reflect.PtrTo(t).Implements(textType):
+		// copy the value so that methods with pointer receivers can be called
+		p := reflect.New(t)
+		p.Elem().Set(v)
+		f.Type = "text"
+		b, err := p.Interface().(encoding.TextMarshaler).MarshalText()
+		if err != nil {
+			f.Value = err.Error()
+		} else {
+			f.Value = string(b)
 		}
+		return f
+	case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8:
+		f.Type = "bytes"
+		f.Value = base64.StdEncoding.EncodeToString(v.Bytes())
+		return f
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		f.Type = "boolean"
+		f.Value = v.Interface()
 	case reflect.String:
-		return Field{
-			Name: field,
-			Path: fieldPath,
-			// Expression: expr,
-			Type:  "string",
-			Value: o.Get(field).Interface(),
-		}
+		f.Type = "string"
+		f.Value = v.Interface()
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
 		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
 		reflect.Float32, reflect.Float64:
-		return Field{
-			Path: fieldPath,
-			Name: field,
-			// Expression: expr,
-			Type:  "number",
-			Value: o.Get(field).Interface(),
-		}
+		f.Type = "number"
+		f.Value = v.Interface()
 	case reflect.Struct:
-		var fields []Field
-		v := o.Get(field)
-		for _, f := range v.Type().Fields() {
-			fields = append(fields, exportField(v, f, fieldPath, n))
-		}
-		return Field{
-			Path: fieldPath,
-			Name: field,
-			// Expression: expr,
-			Type:   "struct",
-			Fields: fields,
+		f.Type = "struct"
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			if sf.PkgPath != "" || sf.Name == "_" {
+				continue
+			}
+			field := exportValue(v.Field(i), sf.Type, sf.Name, path+"/"+sf.Name, n, seen)
+			setEditor(&field, sf)
+			f.Fields = append(f.Fields, field)
 		}
 	case reflect.Map:
-		var fields []Field
-		v := o.Get(field)
-		for _, f := range v.Keys() {
-			fields = append(fields, exportField(v, f, fieldPath, n))
+		f.Type = "map"
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+		})
+		for _, k := range keys {
+			key := fmt.Sprint(k.Interface())
+			f.Fields = append(f.Fields, exportValue(v.MapIndex(k), t.Elem(), key, path+"/"+key, n, seen))
 		}
-		return Field{
-			Path: fieldPath,
-			Name: field,
-			// Expression: expr,
-			Type:   "map",
-			Fields: fields,
+	case reflect.Slice, reflect.Array:
+		f.Type = "array"
+		for i := 0; i < v.Len(); i++ {
+			elem := exportValue(v.Index(i), t.Elem(), "", path+"/"+strconv.Itoa(i), n, seen)
+			f.Fields = append(f.Fields, elem)
 		}
-	case reflect.Slice:
-		var fields []Field
-		v := o.Get(field)
-		for idx, e := range v.Iter() {
-			f, ok := exportElem(e, fieldPath, idx, n)
-			if !ok {
-				return Field{
-					Name: field,
-					Path: fieldPath,
-					// Expression: expr,
-					Type:  "string",
-					Value: "UNSUPPORTED SLICE",
+	case reflect.Ptr, reflect.Interface:
+		if isReference(v, t, n) {
+			var path string
+			if !v.IsNil() {
+				if refNode := n.Root().FindPointer(v.Interface()); refNode != nil {
+					path = refNode.Path()
 				}
 			}
-			fields = append(fields, f)
-		}
-		return Field{
-			Path: fieldPath,
-			Name: field,
-			// Expression: expr,
-			Type:   "array",
-			Fields: fields,
-		}
-	case reflect.Ptr, reflect.Interface:
-		var v interface{}
-		if o.Get(field).IsValid() {
-			v = o.Get(field).Interface()
-		}
-		t := o.Type().FieldType(field)
-		if kind == reflect.Ptr {
-			t = reflected.Type{Type: t.Elem()}
-		}
-		var path string
-		if v != nil {
-			refNode := n.Root().FindPointer(v)
-			if refNode != nil {
-				path = refNode.Path()
+			if t.Kind() == reflect.Ptr {
+				t = t.Elem()
 			}
+			f.Type = fmt.Sprintf("reference:%s", t.Name())
+			f.Value = path
+			return f
 		}
-		return Field{
-			Path: fieldPath,
-			Name: field,
-			// Expression: expr,
-			Type:  fmt.Sprintf("reference:%s", t.Name()),
-			Value: path,
+		if v.IsNil() {
+			// pointers to structs that are not set yet
+			f.Type = "pointer"
+			return f
 		}
+		p := pointee{v.Pointer(), t}
+		if seenPath, ok := seen[p]; ok {
+			// cycles are exported as pointers to the path of the value
+			f.Type = "pointer"
+			f.Value = seenPath
+			return f
+		}
+		seen[p] = path
+		f = exportValue(v.Elem(), t.Elem(), name, path, n, seen)
+		delete(seen, p)
 	default:
-		panic(o.Type().FieldType(field).Kind())
+		// funcs, chans and complex numbers can't be edited
+		f.Type = "unsupported"
+		f.Value = t.String()
 	}
+	return f
 }
 
-type ButtonProvider interface {
-	InspectorButtons() []Button
+// setEditor sets the editor declared by the tag of the struct field sf. Invalid
+// tags are reported in the Error of the field.
+func setEditor(f *Field, sf reflect.StructField) {
+	e, err := ParseEditor(sf.Type, library.ParseTag(sf.Tag.Get(library.TagKey)))
+	if err != nil {
+		f.Error = fmt.Sprintf("invalid tag: %v", err)
+		return
+	}
+	e.apply(f)
 }
 
-func strInSlice(strs []string, str string) bool {
-	for _, s := range strs {
-		if s == str {
-			return true
-		}
+// isReference returns whether a pointer or interface refers to a component or
+// object, or holds a value of its own like a pointer to a struct.
+func isReference(v reflect.Value, t reflect.Type, n manifold.Object) bool {
+	if t.Kind() == reflect.Interface || t.Elem().Kind() != reflect.Struct {
+		return true
 	}
-	return false
+	if library.LookupType(t) != nil {
+		return true
+	}
+	return !v.IsNil() && n.Root().FindPointer(v.Interface()) != nil
+}
+
+// structField returns the named field of a struct or pointer to a struct type.
+func structField(t reflect.Type, name string) (reflect.StructField, bool) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return reflect.StructField{}, false
+	}
+	return t.FieldByName(name)
 }
 
+type ButtonProvider interface {
+	InspectorButtons() []Button
+}
+
+// Update rebuilds the state from all objects under root.
 func (s *State) Update(root manifold.Object) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.update(root)
+}
+
+func (s *State) update(root manifold.Object) {
 	s.Hierarchy = []string{}
 	s.Nodes = make(map[string]Node)
+	s.NodePaths = make(map[string]string)
 	manifold.Walk(root, func(n manifold.Object) {
 		s.Hierarchy = append(s.Hierarchy, n.Path())
-		node := Node{
-			Name:   n.Name(),
-			Active: true,
-			// Dir:        n.Dir,
-			Path:       n.Path(),
-			Index:      n.SiblingIndex(),
-			ID:         n.ID(),
-			Components: []Component{},
+		s.Nodes[n.ID()] = ExportNode(n)
+		s.NodePaths[n.Path()] = n.ID()
+	})
+	s.dirty = make(map[string]bool)
+	s.structural = false
+}
+
+// ExportNode returns the view of an object.
+func ExportNode(n manifold.Object) Node {
+	node := Node{
+		Name:   n.Name(),
+		Active: true,
+		// Dir:        n.Dir,
+		Path:       n.Path(),
+		Index:      n.SiblingIndex(),
+		ID:         n.ID(),
+		Components: []Component{},
+	}
+	for _, com := range n.Components() {
+		var rc *library.RegisteredComponent
+		if com.ID() != "" {
+			rc = library.LookupID(com.ID())
+		} else if rc = library.LookupType(com.Type()); rc == nil {
+			rc = library.Lookup(com.Name())
+		}
+		var meta library.Metadata
+		if rc != nil {
+			meta = rc.Metadata
+		} else {
+			meta.DisplayName = com.Name()
+		}
+
+		var fields []Field
+		c := reflected.ValueOf(com.Pointer())
+		path := n.Path() + "/" + com.Name()
+		for _, field := range c.Type().Fields() {
+			if field == "_" {
+				continue
+			}
+			if _, hidden := library.FieldTags(c.Type().Type, field)["hidden"]; hidden {
+				continue
+			}
+			f := exportField(c, field, path, n)
+			if sf, ok := structField(c.Type().Type, field); ok {
+				setEditor(&f, sf)
+			}
+			fm := meta.Field(field)
+			f.DisplayName = fm.DisplayName
+			f.Description = fm.Description
+			fields = append(fields, f)
 		}
-		for _, com := range n.Components() {
-			var fields []Field
-			c := reflected.ValueOf(com.Pointer())
-			path := n.Path() + "/" + com.Name()
-			hiddenFields := c.Type().FieldsTagged("tractor", "hidden")
-			for _, field := range c.Type().Fields() {
-				if strInSlice(hiddenFields, field) {
+		var buttons []Button
+		p, ok := com.Pointer().(ButtonProvider)
+		if ok {
+			buttons = p.InspectorButtons()
+			for idx, button := range buttons {
+				if button.OnClick != "" {
 					continue
 				}
-				fields = append(fields, exportField(c, field, path, n))
-			}
-			var buttons []Button
-			p, ok := com.Pointer().(ButtonProvider)
-			if ok {
-				buttons = p.InspectorButtons()
-				for idx, button := range buttons {
-					if button.OnClick != "" {
+				typ := reflect.ValueOf(com.Pointer()).Type()
+				for i := 0; i < typ.NumMethod(); i++ {
+					method := typ.Method(i)
+					if method.Name != button.Name {
 						continue
 					}
-					typ := reflect.ValueOf(com.Pointer()).Type()
-					for i := 0; i < typ.NumMethod(); i++ {
-						method := typ.Method(i)
-						if method.Name != button.Name {
-							continue
-						}
-						if method.Type.NumIn() == 1 {
-							buttons[idx].Path = path + "/" + method.Name
-							break
-						}
+					if method.Type.NumIn() == 1 {
+						buttons[idx].Path = path + "/" + method.Name
+						break
 					}
 				}
 			}
+		}
 
-			var filepath string
-			if com.ID() != "" {
-				filepath = library.LookupID(com.ID()).Filepath
-			} else {
-				filepath = library.Lookup(com.Name()).Filepath
-			}
-
-			var related []string
-			for _, rc := range library.Related(library.Lookup(com.Name())) {
-				related = append(related, rc.Type.Name())
-			}
+		var filepath string
+		if rc != nil {
+			filepath = rc.Filepath
+		}
 
-			node.Components = append(node.Components, Component{
-				Name:     com.Name(),
-				Filepath: filepath,
-				Fields:   fields,
-				Buttons:  buttons,
-				Related:  related,
-			})
+		var related []string
+		for _, rel := range library.Related(rc) {
+			related = append(related, rel.Alias())
 		}
-		s.mu.Lock()
-		s.Nodes[n.ID()] = node
-		s.NodePaths[n.Path()] = n.ID()
-		s.mu.Unlock()
-	})
+
+		node.Components = append(node.Components, Component{
+			Name:        com.Name(),
+			DisplayName: meta.DisplayName,
+			Description: meta.Description,
+			Icon:        meta.Icon,
+			Filepath:    filepath,
+			Fields:      fields,
+			Buttons:     buttons,
+			Related:     related,
+		})
+	}
+	return node
 }
 
 type ComponentType struct {
-	Filepath string `msgpack:"filepath"`
-	Name     string `msgpack:"name"`
+	Filepath    string   `msgpack:"filepath" json:"filepath"`
+	Name        string   `msgpack:"name" json:"name"`
+	Type        string   `msgpack:"type" json:"type"`
+	Package     string   `msgpack:"package" json:"package"`
+	Tags        []string `msgpack:"tags" json:"tags"`
+	DisplayName string   `msgpack:"displayName" json:"displayName"`
+	Description string   `msgpack:"description" json:"description"`
+	Category    string   `msgpack:"category" json:"category"`
+	Icon        string   `msgpack:"icon" json:"icon"`
 }
 
-func New(root manifold.Object) *State {
-	state := &State{
-		Projects:       []Project{},
-		CurrentProject: "dev",
-		Nodes:          make(map[string]Node),
-		NodePaths:      make(map[string]string),
-	}
+// RegisteredComponents returns the component types in the library.
+func RegisteredComponents() []ComponentType {
+	var types []ComponentType
 	for _, com := range library.Registered() {
-		state.Components = append(state.Components, ComponentType{
-			Name:     com.Type.Name(),
-			Filepath: com.Filepath,
+		types = append(types, ComponentType{
+			Name:        com.Alias(),
+			Type:        com.Name,
+			Package:     com.Package(),
+			Filepath:    com.Filepath,
+			Tags:        com.Tags,
+			DisplayName: com.Metadata.DisplayName,
+			Description: com.Metadata.Description,
+			Category:    com.Metadata.Category,
+			Icon:        com.Metadata.Icon,
 		})
 	}
+	return types
+}
+
+func New(root manifold.Object) *State {
+	state := &State{
+		Projects:  []Project{},
+		Nodes:     make(map[string]Node),
+		NodePaths: make(map[string]string),
+		dirty:     make(map[string]bool),
+	}
+	state.Components = RegisteredComponents()
 	state.Update(root)
+	notify.Observe(root, notify.Func(state.observe))
 	return state
 }
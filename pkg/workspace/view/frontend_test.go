@@ -0,0 +1,130 @@
+package view
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/manifold/tractor/pkg/manifold/library"
+	"github.com/manifold/tractor/pkg/manifold/object"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type level int
+
+const (
+	low level = iota
+	high
+)
+
+func (l level) String() string {
+	return [...]string{"low", "high"}[l]
+}
+
+func (l level) EnumValues() []interface{} {
+	return []interface{}{low, high}
+}
+
+type item struct {
+	Name  string
+	Count int
+}
+
+type kinds struct {
+	Time     time.Time
+	Timeout  time.Duration
+	Data     []byte
+	Level    level
+	IP       net.IP
+	Items    []item
+	ByName   map[string]item
+	Options  *item
+	Missing  *item
+	Callback func()
+	Updates  chan int
+	Complex  complex128
+}
+
+func TestExportFieldKinds(t *testing.T) {
+	root := object.New("::root")
+	node := object.New("Node")
+	root.AppendChild(node)
+	v := &kinds{
+		Time:    time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+		Timeout: 90 * time.Second,
+		Data:    []byte("hi"),
+		Level:   high,
+		IP:      net.IPv4(127, 0, 0, 1),
+		Items:   []item{{Name: "a", Count: 1}},
+		ByName:  map[string]item{"b": {Name: "b"}},
+		Options: &item{Name: "c"},
+	}
+	node.AppendComponent(library.NewComponent("Kinds", v, ""))
+
+	n := ExportNode(node)
+	require.Len(t, n.Components, 1)
+	fields := make(map[string]Field)
+	for _, f := range n.Components[0].Fields {
+		fields[f.Name] = f
+	}
+
+	assert.Equal(t, Field{Name: "Time", DisplayName: "Time", Path: "/Node/Kinds/Time", Type: "time", Value: "2020-01-02T03:04:05Z"}, fields["Time"])
+	assert.Equal(t, "1m30s", fields["Timeout"].Value)
+	assert.Equal(t, "bytes", fields["Data"].Type)
+	assert.Equal(t, "aGk=", fields["Data"].Value)
+	assert.Equal(t, "enum", fields["Level"].Type)
+	assert.Equal(t, high, fields["Level"].Value)
+	assert.Equal(t, []EnumValue{{Label: "low", Value: low}, {Label: "high", Value: high}}, fields["Level"].Enum)
+	assert.Equal(t, "text", fields["IP"].Type)
+	assert.Equal(t, "127.0.0.1", fields["IP"].Value)
+
+	items := fields["Items"]
+	assert.Equal(t, "array", items.Type)
+	require.Len(t, items.Fields, 1)
+	assert.Equal(t, "struct", items.Fields[0].Type)
+	assert.Equal(t, "/Node/Kinds/Items/0/Name", items.Fields[0].Fields[0].Path)
+	assert.Equal(t, "a", items.Fields[0].Fields[0].Value)
+
+	byName := fields["ByName"]
+	assert.Equal(t, "map", byName.Type)
+	require.Len(t, byName.Fields, 1)
+	assert.Equal(t, "/Node/Kinds/ByName/b/Name", byName.Fields[0].Fields[0].Path)
+
+	assert.Equal(t, "struct", fields["Options"].Type)
+	assert.Equal(t, "/Node/Kinds/Options/Name", fields["Options"].Fields[0].Path)
+	assert.Equal(t, "pointer", fields["Missing"].Type)
+
+	for _, name := range []string{"Callback", "Updates", "Complex"} {
+		assert.Equal(t, "unsupported", fields[name].Type, name)
+	}
+}
+
+type linked struct {
+	Name string
+	Next *linked
+}
+
+type list struct {
+	Head *linked
+}
+
+func TestExportFieldCycle(t *testing.T) {
+	root := object.New("::root")
+	node := object.New("Node")
+	root.AppendChild(node)
+	a := &linked{Name: "a"}
+	a.Next = &linked{Name: "b", Next: a}
+	node.AppendComponent(library.NewComponent("List", &list{Head: a}, ""))
+
+	n := ExportNode(node)
+	require.Len(t, n.Components, 1)
+	head := n.Components[0].Fields[0]
+	assert.Equal(t, "struct", head.Type)
+	next := head.Fields[1]
+	assert.Equal(t, "/Node/List/Head/Next", next.Path)
+	assert.Equal(t, "b", next.Fields[0].Value)
+	cycle := next.Fields[1]
+	assert.Equal(t, "pointer", cycle.Type)
+	assert.Equal(t, "/Node/List/Head", cycle.Value)
+}
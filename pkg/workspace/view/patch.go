@@ -0,0 +1,145 @@
+package view
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/manifold/tractor/pkg/manifold"
+)
+
+// Patch is a JSON Patch (RFC 6902) operation on a ClientState. Op is one of "add",
+// "remove" or "replace" and Path a JSON pointer, like /nodes/{id}.
+type Patch struct {
+	Op    string      `msgpack:"op"`
+	Path  string      `msgpack:"path"`
+	Value interface{} `msgpack:"value,omitempty"`
+}
+
+// Update is a set of patches that turns the ClientState of a session at
+// BaseVersion into the one at Version. Clients that are not at BaseVersion
+// missed an update and have to get the full ClientState again.
+type Update struct {
+	BaseVersion int     `msgpack:"baseVersion"`
+	Version     int     `msgpack:"version"`
+	Patches     []Patch `msgpack:"patches"`
+}
+
+var pointerEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+
+func pointer(parts ...string) string {
+	var b strings.Builder
+	for _, part := range parts {
+		b.WriteString("/")
+		b.WriteString(pointerEscaper.Replace(part))
+	}
+	return b.String()
+}
+
+func (s *State) observe(event interface{}) {
+	change, ok := event.(manifold.ObjectChange)
+	if !ok || change.Object == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch change.Path {
+	case "::Children", "::Parent", "::Name", "::SiblingIndex":
+		// paths and indexes of other nodes change as well
+		s.structural = true
+	default:
+		s.dirty[change.Object.ID()] = true
+	}
+}
+
+// Touch marks objects as changed. It is needed for changes made without an
+// ObjectChange notification, like changes by component methods.
+func (s *State) Touch(ids ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, id := range ids {
+		s.dirty[id] = true
+	}
+}
+
+// Invalidate marks all objects as changed.
+func (s *State) Invalidate() {
+	s.mu.Lock()
+	s.structural = true
+	s.mu.Unlock()
+}
+
+// Changes applies the changes observed since it was last called to the State
+// and returns them as patches. Only changed objects are exported again, unless
+// the hierarchy changed.
+func (s *State) Changes(root manifold.Object) []Patch {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var patches []Patch
+	if s.structural {
+		nodes, hierarchy, nodePaths := s.Nodes, s.Hierarchy, s.NodePaths
+		s.update(root)
+		patches = append(patches, diffNodes(nodes, s.Nodes)...)
+		if !reflect.DeepEqual(hierarchy, s.Hierarchy) {
+			patches = append(patches, Patch{Op: "replace", Path: pointer("hierarchy"), Value: s.Hierarchy})
+		}
+		return append(patches, diffNodePaths(nodePaths, s.NodePaths)...)
+	}
+	for id := range s.dirty {
+		n := root.FindID(id)
+		if n == nil {
+			continue
+		}
+		node := ExportNode(n)
+		old, exists := s.Nodes[id]
+		if exists && reflect.DeepEqual(old, node) {
+			continue
+		}
+		op := "replace"
+		if !exists {
+			op = "add"
+		}
+		s.Nodes[id] = node
+		patches = append(patches, Patch{Op: op, Path: pointer("nodes", id), Value: node})
+	}
+	s.dirty = make(map[string]bool)
+	return patches
+}
+
+func diffNodes(old, new map[string]Node) []Patch {
+	var patches []Patch
+	for id, node := range new {
+		prev, exists := old[id]
+		switch {
+		case !exists:
+			patches = append(patches, Patch{Op: "add", Path: pointer("nodes", id), Value: node})
+		case !reflect.DeepEqual(prev, node):
+			patches = append(patches, Patch{Op: "replace", Path: pointer("nodes", id), Value: node})
+		}
+	}
+	for id := range old {
+		if _, exists := new[id]; !exists {
+			patches = append(patches, Patch{Op: "remove", Path: pointer("nodes", id)})
+		}
+	}
+	return patches
+}
+
+func diffNodePaths(old, new map[string]string) []Patch {
+	var patches []Patch
+	for path, id := range new {
+		prev, exists := old[path]
+		switch {
+		case !exists:
+			patches = append(patches, Patch{Op: "add", Path: pointer("nodePaths", path), Value: id})
+		case prev != id:
+			patches = append(patches, Patch{Op: "replace", Path: pointer("nodePaths", path), Value: id})
+		}
+	}
+	for path := range old {
+		if _, exists := new[path]; !exists {
+			patches = append(patches, Patch{Op: "remove", Path: pointer("nodePaths", path)})
+		}
+	}
+	return patches
+}
@@ -0,0 +1,58 @@
+package view
+
+import (
+	"testing"
+
+	"github.com/manifold/tractor/pkg/manifold/library"
+	"github.com/manifold/tractor/pkg/manifold/object"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type counter struct {
+	Count int
+}
+
+func ops(patches []Patch) map[string]string {
+	m := make(map[string]string)
+	for _, p := range patches {
+		m[p.Path] = p.Op
+	}
+	return m
+}
+
+func TestChanges(t *testing.T) {
+	root := object.New("::root")
+	node := object.New("Node")
+	root.AppendChild(node)
+	node.AppendComponent(library.NewComponent("Counter", &counter{}, ""))
+	s := New(root)
+
+	require.NoError(t, node.SetField("Counter/Count", 1))
+	patches := s.Changes(root)
+	assert.Equal(t, map[string]string{"/nodes/" + node.ID(): "replace"}, ops(patches))
+	assert.Equal(t, 1, patches[0].Value.(Node).Components[0].Fields[0].Value)
+	assert.Empty(t, s.Changes(root))
+
+	// changes without notification
+	node.Component("Counter").Pointer().(*counter).Count = 2
+	assert.Empty(t, s.Changes(root))
+	s.Touch(node.ID())
+	assert.Len(t, s.Changes(root), 1)
+
+	child := object.New("Child")
+	node.AppendChild(child)
+	assert.Equal(t, map[string]string{
+		"/nodes/" + child.ID():     "add",
+		"/hierarchy":               "replace",
+		"/nodePaths/~1Node~1Child": "add",
+	}, ops(s.Changes(root)))
+
+	node.RemoveChild(child)
+	assert.Equal(t, map[string]string{
+		"/nodes/" + child.ID():     "remove",
+		"/hierarchy":               "replace",
+		"/nodePaths/~1Node~1Child": "remove",
+	}, ops(s.Changes(root)))
+	assert.NotContains(t, s.Nodes, child.ID())
+}
@@ -0,0 +1,136 @@
+package view
+
+import (
+	"sync"
+
+	"github.com/rs/xid"
+)
+
+// Session is the view state of a connected client. The object tree in State
+// is shared by all sessions while every session has its own selection and
+// current project, and its own version of the patches it was sent.
+type Session struct {
+	ID             string
+	Name           string
+	SelectedNode   string
+	CurrentProject string
+
+	version int
+	pending []Patch
+	mu      sync.Mutex
+}
+
+// Presence tells which node the client of a session has selected.
+type Presence struct {
+	Session      string `msgpack:"session"`
+	Name         string `msgpack:"name"`
+	SelectedNode string `msgpack:"selectedNode"`
+}
+
+// ClientState is the full view state sent to the client of a session.
+type ClientState struct {
+	Session        string            `msgpack:"session"`
+	Projects       []Project         `msgpack:"projects"`
+	CurrentProject string            `msgpack:"currentProject"`
+	Components     []ComponentType   `msgpack:"components"`
+	Hierarchy      []string          `msgpack:"hierarchy"`
+	Nodes          map[string]Node   `msgpack:"nodes"`
+	NodePaths      map[string]string `msgpack:"nodePaths"`
+	SelectedNode   string            `msgpack:"selectedNode"`
+	Presence       []Presence        `msgpack:"presence"`
+	Version        int               `msgpack:"version"`
+}
+
+func NewSession(name string) *Session {
+	return &Session{
+		ID:             xid.New().String(),
+		Name:           name,
+		CurrentProject: "dev",
+	}
+}
+
+// SetName sets the name of the session shown to other clients.
+func (s *Session) SetName(name string) {
+	s.mu.Lock()
+	s.Name = name
+	s.mu.Unlock()
+}
+
+// SelectNode sets the selected node of the session.
+func (s *Session) SelectNode(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.SelectedNode == id {
+		return
+	}
+	s.SelectedNode = id
+	s.pending = append(s.pending, Patch{Op: "replace", Path: pointer("selectedNode"), Value: id})
+}
+
+// SelectProject sets the current project of the session.
+func (s *Session) SelectProject(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.CurrentProject == name {
+		return
+	}
+	s.CurrentProject = name
+	s.pending = append(s.pending, Patch{Op: "replace", Path: pointer("currentProject"), Value: name})
+}
+
+// Presence returns the presence of the session.
+func (s *Session) Presence() Presence {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return Presence{
+		Session:      s.ID,
+		Name:         s.Name,
+		SelectedNode: s.SelectedNode,
+	}
+}
+
+// Update returns the shared patches together with the patches of the session
+// as the next update of the session. It returns false if there are none.
+func (s *Session) Update(patches []Patch) (Update, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u := Update{BaseVersion: s.version}
+	u.Patches = append(s.pending, patches...)
+	s.pending = nil
+	if len(u.Patches) == 0 {
+		return u, false
+	}
+	s.version++
+	u.Version = s.version
+	return u, true
+}
+
+// ClientState returns the full view state for a session. Pending patches of
+// the session are dropped since they are part of the full state.
+func (st *State) ClientState(sess *Session, presence []Presence) ClientState {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	sess.pending = nil
+	cs := ClientState{
+		Session:        sess.ID,
+		Projects:       st.Projects,
+		CurrentProject: sess.CurrentProject,
+		Components:     st.Components,
+		Hierarchy:      st.Hierarchy,
+		Nodes:          make(map[string]Node, len(st.Nodes)),
+		NodePaths:      make(map[string]string, len(st.NodePaths)),
+		SelectedNode:   sess.SelectedNode,
+		Presence:       presence,
+		Version:        sess.version,
+	}
+	// copies as Changes updates the maps in place
+	for id, node := range st.Nodes {
+		cs.Nodes[id] = node
+	}
+	for path, id := range st.NodePaths {
+		cs.NodePaths[path] = id
+	}
+	return cs
+}
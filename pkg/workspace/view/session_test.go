@@ -0,0 +1,49 @@
+package view
+
+import (
+	"testing"
+
+	"github.com/manifold/tractor/pkg/manifold/object"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSession(t *testing.T) {
+	root := object.New("::root")
+	node := object.New("Node")
+	root.AppendChild(node)
+	state := New(root)
+
+	a, b := NewSession("a"), NewSession("b")
+	assert.NotEqual(t, a.ID, b.ID)
+
+	a.SelectNode(node.ID())
+	u, ok := a.Update(nil)
+	assert.True(t, ok)
+	assert.Equal(t, 0, u.BaseVersion)
+	assert.Equal(t, 1, u.Version)
+	assert.Equal(t, map[string]string{"/selectedNode": "replace"}, ops(u.Patches))
+
+	// selection is per session
+	_, ok = b.Update(nil)
+	assert.False(t, ok)
+	assert.Equal(t, "", b.Presence().SelectedNode)
+	assert.Equal(t, Presence{Session: a.ID, Name: "a", SelectedNode: node.ID()}, a.Presence())
+
+	shared := []Patch{{Op: "replace", Path: "/hierarchy"}}
+	u, _ = a.Update(shared)
+	assert.Equal(t, 1, u.BaseVersion)
+	assert.Equal(t, 2, u.Version)
+	u, _ = b.Update(shared)
+	assert.Equal(t, 1, u.Version)
+
+	b.SelectProject("prod")
+	cs := state.ClientState(b, []Presence{a.Presence(), b.Presence()})
+	assert.Equal(t, b.ID, cs.Session)
+	assert.Equal(t, "prod", cs.CurrentProject)
+	assert.Equal(t, 1, cs.Version)
+	assert.Contains(t, cs.Nodes, node.ID())
+	assert.Len(t, cs.Presence, 2)
+	// pending patches are part of the full state
+	_, ok = b.Update(nil)
+	assert.False(t, ok)
+}
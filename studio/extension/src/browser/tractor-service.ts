@@ -22,6 +22,8 @@ import { Command, CommandRegistry } from '@theia/core/lib/common/command';
 import { Widget } from '@phosphor/widgets';
 import { WorkspaceService } from '@theia/workspace/lib/browser';
 import { ILogger, MessageService } from '@theia/core';
+import { ProblemManager } from '@theia/markers/lib/browser/problem/problem-manager';
+import { Diagnostic, DiagnosticSeverity } from 'vscode-languageserver-types';
 
 import { TractorTreeWidget, ObjectNode, TractorTreeWidgetFactory } from './tractor-tree-widget';
 import { TractorContextMenu, TRACTOR_CONTEXT_MENU } from './tractor-contribution';
@@ -31,10 +33,53 @@ import * as qrpc from 'qrpc';
 
 const RetryInterval = 500;
 
+// token issued by the agent, passed by the agent when it opens studio. The
+// agent passes a short-lived exchange token, which is exchanged for the token
+// kept here on the first connect.
+let token = new URLSearchParams(window.location.search).get("token") || "";
+let exchanged = false;
+
+// exchangeToken replaces the token from the URL by the one the agent gives for
+// it and drops it from the address bar. A token that is not an exchange token
+// is kept as it is.
+async function exchangeToken(client: any) {
+	if (exchanged || !token) {
+		return;
+	}
+	exchanged = true;
+	window.history.replaceState(null, "", window.location.pathname + window.location.hash);
+	try {
+		const resp = await client.call("exchangeToken", token);
+		if (resp && !resp.error && resp.reply) {
+			token = resp.reply;
+		}
+	} catch (e) {
+		// not an exchange token
+	}
+}
+
 function scheduleRetry(fn: any) {
 	setTimeout(fn, RetryInterval);
 }
 
+// applyPatches applies JSON Patch operations from a view update to state.
+function applyPatches(state: any, patches: any[]): any {
+	for (const patch of patches) {
+		const keys = patch.path.split("/").slice(1).map((k: string) => k.replace(/~1/g, "/").replace(/~0/g, "~"));
+		const last = keys.pop();
+		let target = state;
+		for (const key of keys) {
+			target = target[key];
+		}
+		if (patch.op === "remove") {
+			delete target[last];
+		} else {
+			target[last] = patch.value;
+		}
+	}
+	return state;
+}
+
 
 @injectable()
 export class TractorService implements WidgetFactory {
@@ -59,10 +104,15 @@ export class TractorService implements WidgetFactory {
     @inject(ILogger)
     protected readonly logger: ILogger;
 
+    @inject(ProblemManager)
+    protected readonly problems: ProblemManager;
+
     protected client: qrpc.Client;
     protected api: qrpc.API;
 
     public components: any[];
+    protected data: any;
+    protected diagnosticUris: URI[] = [];
 
     protected widget?: TractorTreeWidget;
     protected readonly onDidChangeEmitter = new Emitter<ObjectNode[]>();
@@ -101,8 +151,20 @@ export class TractorService implements WidgetFactory {
 			return;
 		}
         var session = new qmux.Session(conn);
-        var client = new qrpc.Client(session);
+        var api = new qrpc.API();
+        var client = new qrpc.Client(session, api);
+        api.handle("diagnostics", {
+            "serveRPC": async (r, c) => {
+                var update = await c.decode();
+                this.setDiagnostics(update.Diagnostics || []);
+                r.return();
+            }
+        });
+        client.serveAPI();
+        await exchangeToken(client);
+        await client.call("authenticate", token);
         var path = new URI(this.workspace.workspace.uri).path.toString()
+        await client.call("watchDiagnostics", path);
         var resp = await client.call("connect", path);
         this.connectWorkspace(resp.reply);
     }
@@ -129,16 +191,28 @@ export class TractorService implements WidgetFactory {
 			"serveRPC": async (r, c) => {
                 var data = await c.decode();
                 //this.logger.warn(data);
-                this.components = data.components;
-                this.refreshRegistries();
-                if (this.widget) {
-                    this.widget.setData(data);
-                    this.onDidChangeEmitter.fire(this.widget.rootObjects());
+                this.setData(data);
+                r.return();
+			}
+        });
+        this.api.handle("patch", {
+			"serveRPC": async (r, c) => {
+                var update = await c.decode();
+                if (!this.data || update.baseVersion !== this.data.version) {
+                    if (!this.data || update.version > this.data.version) {
+                        this.client.call("resync");
+                    }
+                    r.return();
+                    return;
                 }
+                var data = applyPatches(this.data, update.patches);
+                data.version = update.version;
+                this.setData(data);
                 r.return();
 			}
         });
         this.client.serveAPI();
+        await this.client.call("authenticate", token);
         if (this.widget) {
             this.widget.model.onSelectionChanged(event => {
                 const node = this.widget.model.selectedNodes[0];
@@ -146,7 +220,46 @@ export class TractorService implements WidgetFactory {
                 this.client.call("selectNode", node.id);
             });
         }
-		await this.client.call("subscribe");
+		await this.client.call("subscribe", {Patches: true});
+    }
+
+    setData(data: any) {
+        this.data = data;
+        this.components = data.components;
+        this.refreshRegistries();
+        if (this.widget) {
+            this.widget.setData(data);
+            this.onDidChangeEmitter.fire(this.widget.rootObjects());
+        }
+    }
+
+    // setDiagnostics shows the compiler errors and vet warnings of the
+    // workspace build as problem markers, so they show inline in the source
+    // files of components.
+    setDiagnostics(diagnostics: any[]) {
+        const markers = new Map<string, Diagnostic[]>();
+        diagnostics.forEach((d) => {
+            const pos = { line: Math.max(d.Line - 1, 0), character: Math.max(d.Column - 1, 0) };
+            const fileMarkers = markers.get(d.File) || [];
+            fileMarkers.push({
+                range: { start: pos, end: pos },
+                severity: d.Severity === "error" ? DiagnosticSeverity.Error : DiagnosticSeverity.Warning,
+                source: `go ${d.Source}`,
+                message: d.Message
+            });
+            markers.set(d.File, fileMarkers);
+        });
+        this.diagnosticUris.forEach((uri) => {
+            if (!markers.has(uri.path.toString())) {
+                this.problems.setMarkers(uri, "tractor", []);
+            }
+        });
+        this.diagnosticUris = [];
+        markers.forEach((fileMarkers, file) => {
+            const uri = new URI("file://" + file);
+            this.problems.setMarkers(uri, "tractor", fileMarkers);
+            this.diagnosticUris.push(uri);
+        });
     }
 
     buildContextMenus(node: ObjectNode) {
@@ -203,6 +316,10 @@ export class TractorService implements WidgetFactory {
 		this.client.call("deleteNode", id);
     }
 
+    moveNode(id: string, index: number, parentId?: string) {
+		this.client.call("moveNode", {"ID": id, "Index": index, "Parent": parentId});
+	}
+
     addComponent(component: string, nodeId: string) {
         this.client.call("appendComponent", {ID: nodeId, Name: component});
     }
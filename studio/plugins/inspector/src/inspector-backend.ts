@@ -1,3 +1,5 @@
+import * as fs from 'fs';
+import * as os from 'os';
 import * as path from 'path';
 import * as theia from '@theia/plugin';
 
@@ -135,6 +137,12 @@ class InspectorPanel {
         if (theia.workspace.workspaceFolders) {
             rootPath = theia.workspace.workspaceFolders[0].uri.path;
         }
+        let token = "";
+        try {
+            token = fs.readFileSync(path.join(os.homedir(), ".tractor", "token"), "utf8");
+        } catch (e) {
+            console.warn("unable to read tractor token:", e);
+        }
         return `<!DOCTYPE html>
         <html lang="en">
           <head>
@@ -163,6 +171,7 @@ class InspectorPanel {
             <div id="app"></div>
             <script type="text/babel">
               window.workspacePath = "${rootPath}";
+              window.tractorToken = "${token}";
               window.functionIcon = "${webviewUri('inspector/function-icon.png')}";
               window.rpc = undefined;
               window.theia = acquireTheiaApi();
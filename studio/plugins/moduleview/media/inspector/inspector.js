@@ -7,6 +7,24 @@ function scheduleRetry(fn) {
 	setTimeout(fn, RetryInterval);
 }
 
+// applyPatches applies JSON Patch operations from a view update to state.
+function applyPatches(state, patches) {
+	for (const patch of patches) {
+		const keys = patch.path.split("/").slice(1).map(k => k.replace(/~1/g, "/").replace(/~0/g, "~"));
+		const last = keys.pop();
+		let target = state;
+		for (const key of keys) {
+			target = target[key];
+		}
+		if (patch.op === "remove") {
+			delete target[last];
+		} else {
+			target[last] = patch.value;
+		}
+	}
+	return state;
+}
+
 class InspectorContainer extends React.Component {
     instance = null;
 
@@ -34,6 +52,7 @@ class InspectorContainer extends React.Component {
 		}
         var session = new qmux.Session(conn);
         var client = new qrpc.Client(session);
+        await client.call("authenticate", window.tractorToken);
         var resp = await client.call("connect", window.workspacePath);
         this.connectWorkspace(resp.reply);
     }
@@ -62,9 +81,27 @@ class InspectorContainer extends React.Component {
                 componentPaths = data.componentPaths;
                 r.return();
 			}
+        });
+        this.api.handle("patch", {
+			"serveRPC": async (r, c) => {
+                var update = await c.decode();
+                var remote = this.state.remote;
+                if (update.baseVersion !== remote.version) {
+                    if (update.version > remote.version) {
+                        this.client.call("resync");
+                    }
+                    r.return();
+                    return;
+                }
+                remote = applyPatches(Object.assign({}, remote), update.patches);
+                remote.version = update.version;
+                this.setState({"remote": remote});
+                r.return();
+			}
         });
 		this.client.serveAPI();
-		await this.client.call("subscribe");
+		await this.client.call("authenticate", window.tractorToken);
+		await this.client.call("subscribe", {Patches: true});
     }
 
     componentDidMount() {
@@ -113,21 +150,48 @@ function remoteAction(action, params) {
 function FieldControl(props) {
     const [exprMode, setExprMode] = React.useState(false);
     let onChange = (event) => remoteAction("setValue", { "Path": props.path, "Value": event.target.value });
-    let readOnly = (props.expression || "").length > 0;
+    let readOnly = props.readOnly || (props.expression || "").length > 0;
     function typedControl() {
         if (exprMode) {
             onChange = (event) => remoteAction("setExpression", { "Path": props.path, "Value": event.target.value });
             return <Input type="text" size="small" style={{ height: "22px", color: "white", backgroundColor: "#555", fontFamily: "monospace" }} onChange={onChange} value={props.expression||""} />
         }
+        if (props.enum && props.type !== "enum") {
+            // choices declared with the enum tag are sent as values
+            let onSelect = (e) => remoteAction("setValue", { "Path": props.path, "Value": e.value });
+            return <EnumSelect options={props.enum} value={props.value} readOnly={readOnly} onSelect={onSelect} />
+        }
         switch (props.type) {
             case "string":
-                return <Input type="text" readOnly={readOnly} size="small" onChange={onChange} value={props.value} />
+                switch (props.editor) {
+                    case "multiline":
+                        return <textarea className="textarea is-small" rows="3" readOnly={readOnly} placeholder={props.placeholder} onChange={onChange} value={props.value} />
+                    case "color":
+                        return <input type="color" disabled={readOnly} onChange={onChange} value={props.value || "#000000"} />
+                    default:
+                        // file paths are edited as text relative to the workspace
+                        return <Input type="text" readOnly={readOnly} size="small" placeholder={props.placeholder} onChange={onChange} value={props.value} />
+                }
             case "boolean":
                 onChange = (event) => remoteAction("setValue", { "Path": props.path, "Value": event.target.checked });
                 return <Checkbox onChange={onChange} readOnly={readOnly} checked={props.value} />
             case "number":
                 onChange = (event) => remoteAction("setValue", { "Path": props.path, "IntValue": event.target.valueAsNumber });
-                return <Input type="number" readOnly={readOnly} style={{ width: "100%" }} size="small" onChange={onChange} value={props.value} />
+                let range = props.range || {};
+                return <Input type="number" readOnly={readOnly} style={{ width: "100%" }} size="small" min={range.min} max={range.max} onChange={onChange} value={props.value} />
+            case "time":
+            case "duration":
+            case "text":
+            case "bytes":
+                // parsed into the field type by the workspace
+                return <Input type="text" readOnly={readOnly} size="small" onChange={onChange} value={props.value} />
+            case "enum":
+                let onSelect = (e) => remoteAction("setValue", { "Path": props.path, "Value": e.label });
+                return <EnumSelect options={props.enum} value={props.value} readOnly={readOnly} onSelect={onSelect} />
+            case "pointer":
+                return <Button size="small" onClick={() => remoteAction("setValue", { "Path": props.path, "JSONValue": "{}" })}>Create</Button>
+            case "unsupported":
+                return <Input type="text" readOnly={true} size="small" value={props.value} />
             default:
                 if (props.type.startsWith("reference:")) {
                     var refType = props.type.split(":")[1];
@@ -153,6 +217,19 @@ function FieldControl(props) {
     );
 }
 
+function EnumSelect(props) {
+    const options = props.options || [];
+    const selected = options.find((e) => e.value === props.value);
+    const onChange = (event) => props.onSelect(options.find((e) => e.label === event.target.value));
+    return (
+        <div className="select is-small">
+            <select disabled={props.readOnly} onChange={onChange} value={selected ? selected.label : ""}>
+                {options.map((e) => <option key={e.label} value={e.label}>{e.label}</option>)}
+            </select>
+        </div>
+    );
+}
+
 function FieldRow(props) {
     const children = props.children.slice(0);
     const label = children.shift();
@@ -206,6 +283,9 @@ function EmbeddedFields(props) {
 
 
 function ComponentField(props) {
+    if (props.error) {
+        return <LabeledField key={props.eventKey} label={props.name}><span className="has-text-danger" style={{fontSize: "smaller"}}>{props.error}</span></LabeledField>
+    }
     switch (props.type) {
         case "boolean":
         case "string":
@@ -227,7 +307,7 @@ function ComponentField(props) {
                         </LabeledField>
                     }
                     {fields.map((field) => {
-                        if (props.type == "map") {
+                        if (props.type == "map" && !field.fields) {
                             return <KeyedField key={field.name} name={field.name}><FieldControl {...field} /></KeyedField>;
                         } else {
                             return <ComponentField key={field.name} {...field} />;
@@ -243,9 +323,26 @@ function ComponentField(props) {
 
 function ComponentFields(props) {
     const addKey = (obj, key) => { obj.key = key; return obj};
-    return (props.fields || []).map((el, idx) => 
-        <ComponentField {...addKey(el, idx)} />
-    );
+    const fields = props.fields || [];
+    // fields with a section tag are grouped after the others
+    const sections = [];
+    fields.forEach((el) => {
+        if (el.section && !sections.includes(el.section)) {
+            sections.push(el.section);
+        }
+    });
+    return [
+        ...fields.filter((el) => !el.section).map((el, idx) =>
+            <ComponentField {...addKey(el, idx)} />
+        ),
+        ...sections.map((section) =>
+            <EmbeddedFields key={"section:"+section} name={section}>
+                {fields.filter((el) => el.section === section).map((el, idx) =>
+                    <ComponentField {...addKey(el, idx)} />
+                )}
+            </EmbeddedFields>
+        )
+    ];
 }
 
 function ComponentManageMenu(props) {